@@ -41,9 +41,19 @@ func startServer() {
 
 	taskService := service.NewTaskService()
 	authService := service.NewAuthService()
+	scheduleService := service.NewScheduleService(taskService)
+	workflowService := service.NewWorkflowService(taskService)
+	mapJobService := service.NewMapJobService(taskService)
+	taskGroupService := service.NewTaskGroupService(taskService)
+	deadLetterService := service.NewDeadLetterService(taskService)
 
 	taskHandler := handler.NewTaskHandler(taskService)
 	authHandler := handler.NewAuthHandler(authService)
+	scheduleHandler := handler.NewScheduleHandler(scheduleService)
+	workflowHandler := handler.NewWorkflowHandler(workflowService)
+	mapJobHandler := handler.NewMapJobHandler(mapJobService)
+	taskGroupHandler := handler.NewTaskGroupHandler(taskGroupService)
+	deadLetterHandler := handler.NewDeadLetterHandler(deadLetterService)
 	metricsHandler := handler.NewMetricsHandler()
 	healthHandler := handler.NewHealthHandler()
 	dashboardHandler := handler.NewDashboardHandler()
@@ -52,6 +62,13 @@ func startServer() {
 	defer cancel()
 	staleTaskService := service.NewStaleTaskService(taskService)
 	go staleTaskService.Start(ctx)
+	schedulerService := service.NewSchedulerService(scheduleService)
+	go schedulerService.Start(ctx)
+
+	// Long-polling requests are released when shutdown starts so they do not
+	// hold it up; everything else is left to finish.
+	pollCtx, stopPolling := context.WithCancel(context.Background())
+	defer stopPolling()
 
 	r := gin.Default()
 
@@ -84,10 +101,32 @@ func startServer() {
 	protected.Use(middleware.AuthMiddleware())
 	{
 		protected.POST("/tasks", taskHandler.PublishTask)
-		protected.GET("/tasks", taskHandler.ConsumeTask)
+		protected.GET("/tasks", middleware.CancelOnShutdown(pollCtx), taskHandler.ConsumeTask)
+		protected.POST("/tasks/:id/cancel", taskHandler.CancelTask)
+		protected.POST("/tasks/:id/heartbeat", taskHandler.Heartbeat)
+		protected.POST("/tasks/:id/release", taskHandler.ReleaseTask)
 		protected.POST("/results", taskHandler.PublishResult)
 		protected.POST("/failures", taskHandler.PublishFailure)
 		protected.GET("/results", taskHandler.ConsumeResult)
+		protected.POST("/results/:id/ack", taskHandler.AckResult)
+
+		protected.POST("/schedules", scheduleHandler.CreateSchedule)
+		protected.GET("/schedules", scheduleHandler.ListSchedules)
+		protected.POST("/schedules/:id/pause", scheduleHandler.PauseSchedule)
+		protected.POST("/schedules/:id/resume", scheduleHandler.ResumeSchedule)
+		protected.DELETE("/schedules/:id", scheduleHandler.DeleteSchedule)
+
+		protected.POST("/workflows", workflowHandler.CreateWorkflow)
+		protected.GET("/workflows/:id", workflowHandler.GetWorkflow)
+		protected.POST("/jobs/map", mapJobHandler.CreateMapJob)
+		protected.GET("/jobs/map/:id", mapJobHandler.GetMapJob)
+		protected.GET("/jobs/map/:id/results", mapJobHandler.GetMapJobResults)
+		protected.POST("/task-groups", taskGroupHandler.CreateTaskGroup)
+		protected.GET("/task-groups/:id", taskGroupHandler.GetTaskGroup)
+		protected.GET("/dead-letters", deadLetterHandler.ListDeadLetters)
+		protected.POST("/dead-letters/requeue", deadLetterHandler.RequeueDeadLetters)
+		protected.POST("/dead-letters/:id/requeue", deadLetterHandler.RequeueDeadLetter)
+		protected.DELETE("/dead-letters", deadLetterHandler.PurgeDeadLetters)
 	}
 
 	addr := fmt.Sprintf(":%d", config.App.Server.Port)
@@ -95,6 +134,7 @@ func startServer() {
 		Addr:    addr,
 		Handler: r,
 	}
+	srv.RegisterOnShutdown(stopPolling)
 
 	go func() {
 		log.Printf("Server started on port %d", config.App.Server.Port)
@@ -109,14 +149,13 @@ func startServer() {
 
 	fmt.Println("Shutting down...")
 
-	cancel()
-
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	cancel()
 
 	log.Println("Server exited gracefully")
 }
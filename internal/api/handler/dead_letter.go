@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"rainchanel.com/internal/api/request"
+	"rainchanel.com/internal/api/response"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/service"
+)
+
+type DeadLetterHandler interface {
+	ListDeadLetters(*gin.Context)
+	RequeueDeadLetter(*gin.Context)
+	RequeueDeadLetters(*gin.Context)
+	PurgeDeadLetters(*gin.Context)
+}
+
+type deadLetterHandler struct {
+	deadLetterService service.DeadLetterService
+}
+
+func NewDeadLetterHandler(deadLetterService service.DeadLetterService) DeadLetterHandler {
+	return &deadLetterHandler{
+		deadLetterService: deadLetterService,
+	}
+}
+
+func (h *deadLetterHandler) ListDeadLetters(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var filter dto.DeadLetterFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	offset, err := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	deadLetters, total, err := h.deadLetterService.ListDeadLetters(userID.(uint), filter, limit, offset)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.DeadLettersResponse{
+			DeadLetters: deadLetters,
+			Total:       total,
+			Limit:       limit,
+			Offset:      offset,
+		},
+	})
+}
+
+func (h *deadLetterHandler) RequeueDeadLetter(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	taskID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid task ID",
+			},
+		})
+		return
+	}
+
+	if err := h.deadLetterService.RequeueDeadLetter(uint(taskID), userID.(uint)); err != nil {
+		if errors.Is(err, service.ErrTaskNotFound) {
+			ctx.JSON(http.StatusNotFound, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusNotFound,
+					Message: "Task not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskAccessDenied) {
+			ctx.JSON(http.StatusForbidden, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusForbidden,
+					Message: "Access denied: task does not belong to user",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskNotFailed) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task has not failed and cannot be requeued",
+				},
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.RequeueDeadLettersResponse{
+			Requeued: 1,
+		},
+	})
+}
+
+func (h *deadLetterHandler) RequeueDeadLetters(ctx *gin.Context) {
+	var requeueRequest request.RequeueDeadLettersRequest
+
+	if err := ctx.ShouldBindJSON(&requeueRequest); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	requeued, err := h.deadLetterService.RequeueDeadLetters(userID.(uint), requeueRequest.DeadLetterFilter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.RequeueDeadLettersResponse{
+			Requeued: requeued,
+		},
+	})
+}
+
+func (h *deadLetterHandler) PurgeDeadLetters(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var filter dto.DeadLetterFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	olderThan, err := time.ParseDuration(ctx.Query("older_than"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "older_than must be a duration such as 72h",
+			},
+		})
+		return
+	}
+
+	purged, err := h.deadLetterService.PurgeDeadLetters(userID.(uint), filter, olderThan)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPurgeAge) {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.PurgeDeadLettersResponse{
+			Purged: purged,
+		},
+	})
+}
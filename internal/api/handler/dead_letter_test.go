@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/service"
+)
+
+type MockDeadLetterService struct {
+	ListDeadLettersFunc    func(userID uint, filter dto.DeadLetterFilter, limit, offset int) ([]dto.DeadLetter, int64, error)
+	RequeueDeadLetterFunc  func(taskID uint, userID uint) error
+	RequeueDeadLettersFunc func(userID uint, filter dto.DeadLetterFilter) (int, error)
+	PurgeDeadLettersFunc   func(userID uint, filter dto.DeadLetterFilter, olderThan time.Duration) (int64, error)
+}
+
+func (m *MockDeadLetterService) ListDeadLetters(userID uint, filter dto.DeadLetterFilter, limit, offset int) ([]dto.DeadLetter, int64, error) {
+	if m.ListDeadLettersFunc != nil {
+		return m.ListDeadLettersFunc(userID, filter, limit, offset)
+	}
+	return nil, 0, nil
+}
+
+func (m *MockDeadLetterService) RequeueDeadLetter(taskID uint, userID uint) error {
+	if m.RequeueDeadLetterFunc != nil {
+		return m.RequeueDeadLetterFunc(taskID, userID)
+	}
+	return nil
+}
+
+func (m *MockDeadLetterService) RequeueDeadLetters(userID uint, filter dto.DeadLetterFilter) (int, error) {
+	if m.RequeueDeadLettersFunc != nil {
+		return m.RequeueDeadLettersFunc(userID, filter)
+	}
+	return 0, nil
+}
+
+func (m *MockDeadLetterService) PurgeDeadLetters(userID uint, filter dto.DeadLetterFilter, olderThan time.Duration) (int64, error) {
+	if m.PurgeDeadLettersFunc != nil {
+		return m.PurgeDeadLettersFunc(userID, filter, olderThan)
+	}
+	return 0, nil
+}
+
+func newDeadLetterRouter(mockService *MockDeadLetterService) *gin.Engine {
+	handler := NewDeadLetterHandler(mockService)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+	})
+	router.GET("/dead-letters", handler.ListDeadLetters)
+	router.POST("/dead-letters/requeue", handler.RequeueDeadLetters)
+	router.POST("/dead-letters/:id/requeue", handler.RequeueDeadLetter)
+	router.DELETE("/dead-letters", handler.PurgeDeadLetters)
+	return router
+}
+
+func TestDeadLetterHandler_ListDeadLetters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := &MockDeadLetterService{
+		ListDeadLettersFunc: func(userID uint, filter dto.DeadLetterFilter, limit, offset int) ([]dto.DeadLetter, int64, error) {
+			assert.Equal(t, dto.DeadLetterFilter{Func: "add", ErrorContains: "trap"}, filter)
+			assert.Equal(t, 10, limit)
+			return []dto.DeadLetter{{Task: dto.Task{ID: 5, Func: "add"}, ErrorMsg: "trap"}}, 1, nil
+		},
+	}
+
+	req, _ := http.NewRequest("GET", "/dead-letters?func=add&error_contains=trap&limit=10", nil)
+	w := httptest.NewRecorder()
+	newDeadLetterRouter(mockService).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestDeadLetterHandler_RequeueDeadLetter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		taskID         string
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name:           "success",
+			taskID:         "5",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid id",
+			taskID:         "abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "not found",
+			taskID:         "5",
+			serviceError:   service.ErrTaskNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "access denied",
+			taskID:         "5",
+			serviceError:   service.ErrTaskAccessDenied,
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name:           "not failed",
+			taskID:         "5",
+			serviceError:   service.ErrTaskNotFailed,
+			wantStatusCode: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockDeadLetterService{
+				RequeueDeadLetterFunc: func(taskID uint, userID uint) error {
+					return tt.serviceError
+				},
+			}
+
+			req, _ := http.NewRequest("POST", "/dead-letters/"+tt.taskID+"/requeue", nil)
+			w := httptest.NewRecorder()
+			newDeadLetterRouter(mockService).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
+
+func TestDeadLetterHandler_RequeueDeadLetters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := &MockDeadLetterService{
+		RequeueDeadLettersFunc: func(userID uint, filter dto.DeadLetterFilter) (int, error) {
+			assert.Equal(t, dto.DeadLetterFilter{Func: "simulate", Queue: "gpu-sim"}, filter)
+			return 3, nil
+		},
+	}
+
+	body, _ := json.Marshal(map[string]any{"func": "simulate", "queue": "gpu-sim"})
+	req, _ := http.NewRequest("POST", "/dead-letters/requeue", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	newDeadLetterRouter(mockService).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"requeued":3`)
+}
+
+func TestDeadLetterHandler_PurgeDeadLetters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		query          string
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name:           "success",
+			query:          "?older_than=72h",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "missing older_than",
+			query:          "",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "non-positive older_than",
+			query:          "?older_than=0s",
+			serviceError:   service.ErrInvalidPurgeAge,
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockDeadLetterService{
+				PurgeDeadLettersFunc: func(userID uint, filter dto.DeadLetterFilter, olderThan time.Duration) (int64, error) {
+					if tt.serviceError != nil {
+						return 0, tt.serviceError
+					}
+					assert.Equal(t, 72*time.Hour, olderThan)
+					return 4, nil
+				},
+			}
+
+			req, _ := http.NewRequest("DELETE", "/dead-letters"+tt.query, nil)
+			w := httptest.NewRecorder()
+			newDeadLetterRouter(mockService).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
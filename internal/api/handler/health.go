@@ -46,13 +46,27 @@ func (h *HealthHandler) GetHealth(ctx *gin.Context) {
 		return
 	}
 
+	queueStats, err := h.auditRepo.GetQueueStatistics()
+	if err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "degraded",
+			"error":  "Failed to get per-queue statistics",
+		})
+		return
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"status": "healthy",
 		"queue": gin.H{
-			"pending":    stats["pending"],
-			"processing": stats["processing"],
-			"completed":  stats["completed"],
-			"failed":     stats["failed"],
+			"pending":           stats["pending"],
+			"processing":        stats["processing"],
+			"completed":         stats["completed"],
+			"failed":            stats["failed"],
+			"cancelled":         stats["cancelled"],
+			"blocked":           stats["blocked"],
+			"deadline_exceeded": stats["deadline_exceeded"],
+			"expired":           stats["expired"],
 		},
+		"queues": queueStats,
 	})
 }
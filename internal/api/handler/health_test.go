@@ -13,7 +13,8 @@ import (
 )
 
 type MockTaskAuditRepositoryForHealth struct {
-	GetTaskStatisticsFunc func() (map[string]int64, error)
+	GetTaskStatisticsFunc  func() (map[string]int64, error)
+	GetQueueStatisticsFunc func() (map[string]map[string]int64, error)
 }
 
 func (m *MockTaskAuditRepositoryForHealth) GetTaskStatistics() (map[string]int64, error) {
@@ -23,29 +24,71 @@ func (m *MockTaskAuditRepositoryForHealth) GetTaskStatistics() (map[string]int64
 	return nil, nil
 }
 
+func (m *MockTaskAuditRepositoryForHealth) GetQueueStatistics() (map[string]map[string]int64, error) {
+	if m.GetQueueStatisticsFunc != nil {
+		return m.GetQueueStatisticsFunc()
+	}
+	return nil, nil
+}
+
 func (m *MockTaskAuditRepositoryForHealth) CreateTaskAudit(audit *database.TaskAudit) error {
 	return nil
 }
+func (m *MockTaskAuditRepositoryForHealth) CreateTaskAudits(audits []*database.TaskAudit) error {
+	return nil
+}
 func (m *MockTaskAuditRepositoryForHealth) FindTaskAuditByTaskID(taskID uint) (*database.TaskAudit, error) {
 	return nil, nil
 }
-func (m *MockTaskAuditRepositoryForHealth) UpdateTaskAuditStatus(taskID uint, status database.TaskStatus) error {
+func (m *MockTaskAuditRepositoryForHealth) UpdateTaskAuditCompleted(taskID uint, processedBy uint, claimToken string) error {
 	return nil
 }
-func (m *MockTaskAuditRepositoryForHealth) UpdateTaskAuditConsumed(taskID uint) error { return nil }
-func (m *MockTaskAuditRepositoryForHealth) UpdateTaskAuditCompleted(taskID uint, processedBy uint) error {
-	return nil
-}
-func (m *MockTaskAuditRepositoryForHealth) FindAndClaimPendingTask() (*database.TaskAudit, error) {
+func (m *MockTaskAuditRepositoryForHealth) FindAndClaimPendingTasks(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
 	return nil, nil
 }
 func (m *MockTaskAuditRepositoryForHealth) FindStaleTasks(timeoutDuration time.Duration) ([]*database.TaskAudit, error) {
 	return nil, nil
 }
-func (m *MockTaskAuditRepositoryForHealth) ReclaimStaleTask(taskID uint, errorMsg string) error {
+func (m *MockTaskAuditRepositoryForHealth) FindOverdueTasks(now time.Time) ([]*database.TaskAudit, error) {
+	return nil, nil
+}
+func (m *MockTaskAuditRepositoryForHealth) MarkDeadlineExceeded(taskID uint, errorMsg string) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForHealth) FindExpiredTasks(now time.Time) ([]*database.TaskAudit, error) {
+	return nil, nil
+}
+func (m *MockTaskAuditRepositoryForHealth) ExpireTask(taskID uint, errorMsg string) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForHealth) ReclaimStaleTask(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+	return nil
+}
+func (m *MockTaskAuditRepositoryForHealth) ExtendLease(taskID uint, claimToken string, leaseExpiresAt time.Time) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForHealth) ReleaseTask(taskID uint, claimToken string, nextAttemptAt *time.Time, reason string) error {
+	return nil
+}
+func (m *MockTaskAuditRepositoryForHealth) UpdateTaskFailed(taskID uint, claimToken string, errorCode string, errorMsg string) error {
 	return nil
 }
-func (m *MockTaskAuditRepositoryForHealth) UpdateTaskFailed(taskID uint, errorMsg string) error {
+func (m *MockTaskAuditRepositoryForHealth) CancelTask(taskID uint) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForHealth) FindTaskAuditsByTaskIDs(taskIDs []uint) ([]*database.TaskAudit, error) {
+	return nil, nil
+}
+func (m *MockTaskAuditRepositoryForHealth) UnblockTask(taskID uint, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForHealth) FailBlockedTask(taskID uint, errorCode string, errorMsg string) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForHealth) RequeueFailedTask(taskID uint, status database.TaskStatus, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForHealth) CreateTaskFailure(failure *database.TaskFailure) error {
 	return nil
 }
 func (m *MockTaskAuditRepositoryForHealth) GetEnhancedStatistics() (map[string]interface{}, error) {
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rainchanel.com/internal/api/request"
+	"rainchanel.com/internal/api/response"
+	"rainchanel.com/internal/service"
+)
+
+type MapJobHandler interface {
+	CreateMapJob(*gin.Context)
+	GetMapJob(*gin.Context)
+	GetMapJobResults(*gin.Context)
+}
+
+type mapJobHandler struct {
+	mapJobService service.MapJobService
+}
+
+func NewMapJobHandler(mapJobService service.MapJobService) MapJobHandler {
+	return &mapJobHandler{
+		mapJobService: mapJobService,
+	}
+}
+
+func (h *mapJobHandler) CreateMapJob(ctx *gin.Context) {
+	var createMapJobRequest request.CreateMapJobRequest
+
+	if err := ctx.ShouldBindJSON(&createMapJobRequest); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	job, taskIDs, err := h.mapJobService.CreateMapJob(createMapJobRequest.Job, userID.(uint))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMapJob) ||
+			errors.Is(err, service.ErrInvalidQueue) ||
+			errors.Is(err, service.ErrInvalidPriority) ||
+			errors.Is(err, service.ErrInvalidRetryPolicy) ||
+			errors.Is(err, service.ErrInvalidTimeout) ||
+			errors.Is(err, service.ErrInvalidDeadline) ||
+			errors.Is(err, service.ErrInvalidTTL) {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.MapJobResponse{
+			Job:     *job,
+			TaskIDs: taskIDs,
+		},
+	})
+}
+
+func (h *mapJobHandler) GetMapJob(ctx *gin.Context) {
+	userID, jobID, ok := mapJobRequestIDs(ctx)
+	if !ok {
+		return
+	}
+
+	job, err := h.mapJobService.GetMapJob(jobID, userID)
+	if err != nil {
+		respondMapJobError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.MapJobResponse{
+			Job: *job,
+		},
+	})
+}
+
+func (h *mapJobHandler) GetMapJobResults(ctx *gin.Context) {
+	userID, jobID, ok := mapJobRequestIDs(ctx)
+	if !ok {
+		return
+	}
+
+	results, err := h.mapJobService.GetMapJobResults(jobID, userID)
+	if err != nil {
+		respondMapJobError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.MapJobResultsResponse{
+			JobID:   jobID,
+			Results: results,
+		},
+	})
+}
+
+func mapJobRequestIDs(ctx *gin.Context) (uint, uint, bool) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return 0, 0, false
+	}
+
+	jobID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid map job ID",
+			},
+		})
+		return 0, 0, false
+	}
+
+	return userID.(uint), uint(jobID), true
+}
+
+func respondMapJobError(ctx *gin.Context, err error) {
+	if errors.Is(err, service.ErrMapJobNotFound) {
+		ctx.JSON(http.StatusNotFound, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusNotFound,
+				Message: "Map job not found",
+			},
+		})
+		return
+	}
+	if errors.Is(err, service.ErrMapJobAccessDenied) {
+		ctx.JSON(http.StatusForbidden, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusForbidden,
+				Message: "Access denied: map job does not belong to user",
+			},
+		})
+		return
+	}
+	if errors.Is(err, service.ErrMapJobNotFinished) {
+		ctx.JSON(http.StatusConflict, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusConflict,
+				Message: "Map job has unfinished tasks",
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusInternalServerError, response.Response{
+		Error: &response.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		},
+	})
+}
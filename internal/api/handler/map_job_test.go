@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/service"
+)
+
+type MockMapJobService struct {
+	CreateMapJobFunc     func(job dto.MapJob, createdBy uint) (*dto.MapJob, []uint, error)
+	GetMapJobFunc        func(jobID uint, userID uint) (*dto.MapJob, error)
+	GetMapJobResultsFunc func(jobID uint, userID uint) ([]dto.MapJobResult, error)
+}
+
+func (m *MockMapJobService) CreateMapJob(job dto.MapJob, createdBy uint) (*dto.MapJob, []uint, error) {
+	if m.CreateMapJobFunc != nil {
+		return m.CreateMapJobFunc(job, createdBy)
+	}
+	return nil, nil, nil
+}
+
+func (m *MockMapJobService) GetMapJob(jobID uint, userID uint) (*dto.MapJob, error) {
+	if m.GetMapJobFunc != nil {
+		return m.GetMapJobFunc(jobID, userID)
+	}
+	return nil, nil
+}
+
+func (m *MockMapJobService) GetMapJobResults(jobID uint, userID uint) ([]dto.MapJobResult, error) {
+	if m.GetMapJobResultsFunc != nil {
+		return m.GetMapJobResultsFunc(jobID, userID)
+	}
+	return nil, nil
+}
+
+func TestMapJobHandler_CreateMapJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	validBody := map[string]any{
+		"job": map[string]any{
+			"wasm_module": "base64-module",
+			"func":        "add",
+			"args":        [][]int{{1, 2}, {3, 4}},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		requestBody    any
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name:           "success",
+			requestBody:    validBody,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid json",
+			requestBody:    "not an object",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid map job",
+			requestBody:    validBody,
+			serviceError:   service.ErrInvalidMapJob,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "service error",
+			requestBody:    validBody,
+			serviceError:   errors.New("database error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockMapJobService{
+				CreateMapJobFunc: func(job dto.MapJob, createdBy uint) (*dto.MapJob, []uint, error) {
+					if tt.serviceError != nil {
+						return nil, nil, tt.serviceError
+					}
+					assert.Len(t, job.Args, 2)
+					return &dto.MapJob{ID: 1, TaskCount: 2, Status: service.JobStatusRunning}, []uint{10, 11}, nil
+				},
+			}
+			handler := NewMapJobHandler(mockService)
+
+			router := gin.New()
+			router.POST("/jobs/map", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.CreateMapJob(c)
+			})
+
+			body, _ := json.Marshal(tt.requestBody)
+			req, _ := http.NewRequest("POST", "/jobs/map", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
+
+func TestMapJobHandler_GetMapJobResults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		jobID          string
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name:           "success",
+			jobID:          "3",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid id",
+			jobID:          "abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "not found",
+			jobID:          "3",
+			serviceError:   service.ErrMapJobNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "access denied",
+			jobID:          "3",
+			serviceError:   service.ErrMapJobAccessDenied,
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name:           "not finished",
+			jobID:          "3",
+			serviceError:   service.ErrMapJobNotFinished,
+			wantStatusCode: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockMapJobService{
+				GetMapJobResultsFunc: func(jobID uint, userID uint) ([]dto.MapJobResult, error) {
+					if tt.serviceError != nil {
+						return nil, tt.serviceError
+					}
+					return []dto.MapJobResult{{Index: 0, TaskID: 10, Status: "completed", Result: 3}}, nil
+				},
+			}
+			handler := NewMapJobHandler(mockService)
+
+			router := gin.New()
+			router.GET("/jobs/map/:id/results", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.GetMapJobResults(c)
+			})
+
+			req, _ := http.NewRequest("GET", "/jobs/map/"+tt.jobID+"/results", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
@@ -35,5 +35,22 @@ func (h *MetricsHandler) GetMetrics(ctx *gin.Context) {
 		metrics += `rainchanel_tasks_total{status="` + status + `"}` + " " + strconv.FormatInt(count, 10) + "\n"
 	}
 
+	queueStats, err := h.auditRepo.GetQueueStatistics()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get metrics",
+		})
+		return
+	}
+
+	metrics += "# HELP rainchanel_queue_tasks_total Number of tasks by queue and status\n"
+	metrics += "# TYPE rainchanel_queue_tasks_total gauge\n"
+
+	for queue, counts := range queueStats {
+		for status, count := range counts {
+			metrics += `rainchanel_queue_tasks_total{queue="` + queue + `",status="` + status + `"}` + " " + strconv.FormatInt(count, 10) + "\n"
+		}
+	}
+
 	ctx.String(http.StatusOK, metrics)
 }
@@ -13,7 +13,8 @@ import (
 )
 
 type MockTaskAuditRepositoryForMetrics struct {
-	GetTaskStatisticsFunc func() (map[string]int64, error)
+	GetTaskStatisticsFunc  func() (map[string]int64, error)
+	GetQueueStatisticsFunc func() (map[string]map[string]int64, error)
 }
 
 func (m *MockTaskAuditRepositoryForMetrics) GetTaskStatistics() (map[string]int64, error) {
@@ -23,29 +24,71 @@ func (m *MockTaskAuditRepositoryForMetrics) GetTaskStatistics() (map[string]int6
 	return nil, nil
 }
 
+func (m *MockTaskAuditRepositoryForMetrics) GetQueueStatistics() (map[string]map[string]int64, error) {
+	if m.GetQueueStatisticsFunc != nil {
+		return m.GetQueueStatisticsFunc()
+	}
+	return nil, nil
+}
+
 func (m *MockTaskAuditRepositoryForMetrics) CreateTaskAudit(audit *database.TaskAudit) error {
 	return nil
 }
+func (m *MockTaskAuditRepositoryForMetrics) CreateTaskAudits(audits []*database.TaskAudit) error {
+	return nil
+}
 func (m *MockTaskAuditRepositoryForMetrics) FindTaskAuditByTaskID(taskID uint) (*database.TaskAudit, error) {
 	return nil, nil
 }
-func (m *MockTaskAuditRepositoryForMetrics) UpdateTaskAuditStatus(taskID uint, status database.TaskStatus) error {
-	return nil
-}
-func (m *MockTaskAuditRepositoryForMetrics) UpdateTaskAuditConsumed(taskID uint) error { return nil }
-func (m *MockTaskAuditRepositoryForMetrics) UpdateTaskAuditCompleted(taskID uint, processedBy uint) error {
+func (m *MockTaskAuditRepositoryForMetrics) UpdateTaskAuditCompleted(taskID uint, processedBy uint, claimToken string) error {
 	return nil
 }
-func (m *MockTaskAuditRepositoryForMetrics) FindAndClaimPendingTask() (*database.TaskAudit, error) {
+func (m *MockTaskAuditRepositoryForMetrics) FindAndClaimPendingTasks(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
 	return nil, nil
 }
 func (m *MockTaskAuditRepositoryForMetrics) FindStaleTasks(timeoutDuration time.Duration) ([]*database.TaskAudit, error) {
 	return nil, nil
 }
-func (m *MockTaskAuditRepositoryForMetrics) ReclaimStaleTask(taskID uint, errorMsg string) error {
+func (m *MockTaskAuditRepositoryForMetrics) FindOverdueTasks(now time.Time) ([]*database.TaskAudit, error) {
+	return nil, nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) MarkDeadlineExceeded(taskID uint, errorMsg string) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) FindExpiredTasks(now time.Time) ([]*database.TaskAudit, error) {
+	return nil, nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) ExpireTask(taskID uint, errorMsg string) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) ReclaimStaleTask(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
 	return nil
 }
-func (m *MockTaskAuditRepositoryForMetrics) UpdateTaskFailed(taskID uint, errorMsg string) error {
+func (m *MockTaskAuditRepositoryForMetrics) ExtendLease(taskID uint, claimToken string, leaseExpiresAt time.Time) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) ReleaseTask(taskID uint, claimToken string, nextAttemptAt *time.Time, reason string) error {
+	return nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) UpdateTaskFailed(taskID uint, claimToken string, errorCode string, errorMsg string) error {
+	return nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) CancelTask(taskID uint) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) FindTaskAuditsByTaskIDs(taskIDs []uint) ([]*database.TaskAudit, error) {
+	return nil, nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) UnblockTask(taskID uint, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) FailBlockedTask(taskID uint, errorCode string, errorMsg string) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) RequeueFailedTask(taskID uint, status database.TaskStatus, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+	return false, nil
+}
+func (m *MockTaskAuditRepositoryForMetrics) CreateTaskFailure(failure *database.TaskFailure) error {
 	return nil
 }
 func (m *MockTaskAuditRepositoryForMetrics) GetEnhancedStatistics() (map[string]interface{}, error) {
@@ -166,6 +209,11 @@ func TestMetricsHandler_GetMetrics_PrometheusFormat(t *testing.T) {
 					"failed":     2,
 				}, nil
 			},
+			GetQueueStatisticsFunc: func() (map[string]map[string]int64, error) {
+				return map[string]map[string]int64{
+					"gpu-sim": {"pending": 7},
+				}, nil
+			},
 		},
 	}
 
@@ -184,6 +232,8 @@ func TestMetricsHandler_GetMetrics_PrometheusFormat(t *testing.T) {
 	assert.Contains(t, body, `rainchanel_tasks_total{status="processing"} 5`)
 	assert.Contains(t, body, `rainchanel_tasks_total{status="completed"} 50`)
 	assert.Contains(t, body, `rainchanel_tasks_total{status="failed"} 2`)
+	assert.Contains(t, body, "# TYPE rainchanel_queue_tasks_total gauge")
+	assert.Contains(t, body, `rainchanel_queue_tasks_total{queue="gpu-sim",status="pending"} 7`)
 
 	lines := strings.Split(strings.TrimSpace(body), "\n")
 	assert.Greater(t, len(lines), 4)
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rainchanel.com/internal/api/request"
+	"rainchanel.com/internal/api/response"
+	"rainchanel.com/internal/service"
+)
+
+type ScheduleHandler interface {
+	CreateSchedule(*gin.Context)
+	ListSchedules(*gin.Context)
+	PauseSchedule(*gin.Context)
+	ResumeSchedule(*gin.Context)
+	DeleteSchedule(*gin.Context)
+}
+
+type scheduleHandler struct {
+	scheduleService service.ScheduleService
+}
+
+func NewScheduleHandler(scheduleService service.ScheduleService) ScheduleHandler {
+	return &scheduleHandler{
+		scheduleService: scheduleService,
+	}
+}
+
+func (h *scheduleHandler) CreateSchedule(ctx *gin.Context) {
+	var createScheduleRequest request.CreateScheduleRequest
+
+	if err := ctx.ShouldBindJSON(&createScheduleRequest); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	schedule, err := h.scheduleService.CreateSchedule(createScheduleRequest.Schedule, userID.(uint))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCronExpression) ||
+			errors.Is(err, service.ErrInvalidMissedTickPolicy) ||
+			errors.Is(err, service.ErrInvalidQueue) ||
+			errors.Is(err, service.ErrInvalidPriority) {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.CreateScheduleResponse{
+			Schedule: *schedule,
+		},
+	})
+}
+
+func (h *scheduleHandler) ListSchedules(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	schedules, err := h.scheduleService.ListSchedules(userID.(uint))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.ListSchedulesResponse{
+			Schedules: schedules,
+		},
+	})
+}
+
+func (h *scheduleHandler) PauseSchedule(ctx *gin.Context) {
+	h.handleScheduleAction(ctx, h.scheduleService.PauseSchedule, "Schedule paused")
+}
+
+func (h *scheduleHandler) ResumeSchedule(ctx *gin.Context) {
+	h.handleScheduleAction(ctx, h.scheduleService.ResumeSchedule, "Schedule resumed")
+}
+
+func (h *scheduleHandler) DeleteSchedule(ctx *gin.Context) {
+	h.handleScheduleAction(ctx, h.scheduleService.DeleteSchedule, "Schedule deleted")
+}
+
+func (h *scheduleHandler) handleScheduleAction(ctx *gin.Context, action func(scheduleID uint, userID uint) error, message string) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	scheduleID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid schedule ID",
+			},
+		})
+		return
+	}
+
+	if err := action(uint(scheduleID), userID.(uint)); err != nil {
+		if errors.Is(err, service.ErrScheduleNotFound) {
+			ctx.JSON(http.StatusNotFound, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusNotFound,
+					Message: "Schedule not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrScheduleAccessDenied) {
+			ctx.JSON(http.StatusForbidden, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusForbidden,
+					Message: "Access denied: schedule does not belong to user",
+				},
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.ScheduleActionResponse{
+			Message: message,
+		},
+	})
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/service"
+)
+
+type MockScheduleService struct {
+	CreateScheduleFunc  func(schedule dto.Schedule, createdBy uint) (*dto.Schedule, error)
+	ListSchedulesFunc   func(userID uint) ([]dto.Schedule, error)
+	PauseScheduleFunc   func(scheduleID uint, userID uint) error
+	ResumeScheduleFunc  func(scheduleID uint, userID uint) error
+	DeleteScheduleFunc  func(scheduleID uint, userID uint) error
+	RunDueSchedulesFunc func() (int, error)
+}
+
+func (m *MockScheduleService) CreateSchedule(schedule dto.Schedule, createdBy uint) (*dto.Schedule, error) {
+	if m.CreateScheduleFunc != nil {
+		return m.CreateScheduleFunc(schedule, createdBy)
+	}
+	return nil, nil
+}
+
+func (m *MockScheduleService) ListSchedules(userID uint) ([]dto.Schedule, error) {
+	if m.ListSchedulesFunc != nil {
+		return m.ListSchedulesFunc(userID)
+	}
+	return nil, nil
+}
+
+func (m *MockScheduleService) PauseSchedule(scheduleID uint, userID uint) error {
+	if m.PauseScheduleFunc != nil {
+		return m.PauseScheduleFunc(scheduleID, userID)
+	}
+	return nil
+}
+
+func (m *MockScheduleService) ResumeSchedule(scheduleID uint, userID uint) error {
+	if m.ResumeScheduleFunc != nil {
+		return m.ResumeScheduleFunc(scheduleID, userID)
+	}
+	return nil
+}
+
+func (m *MockScheduleService) DeleteSchedule(scheduleID uint, userID uint) error {
+	if m.DeleteScheduleFunc != nil {
+		return m.DeleteScheduleFunc(scheduleID, userID)
+	}
+	return nil
+}
+
+func (m *MockScheduleService) RunDueSchedules() (int, error) {
+	if m.RunDueSchedulesFunc != nil {
+		return m.RunDueSchedulesFunc()
+	}
+	return 0, nil
+}
+
+func TestScheduleHandler_CreateSchedule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		requestBody    any
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name: "success",
+			requestBody: map[string]any{
+				"schedule": map[string]any{
+					"wasm_module": "base64-module",
+					"func":        "aggregate",
+					"cron":        "0 * * * *",
+				},
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid JSON",
+			requestBody:    "invalid json",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "invalid cron expression",
+			requestBody: map[string]any{
+				"schedule": map[string]any{
+					"wasm_module": "base64-module",
+					"func":        "aggregate",
+					"cron":        "never",
+				},
+			},
+			serviceError:   service.ErrInvalidCronExpression,
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockScheduleService{
+				CreateScheduleFunc: func(schedule dto.Schedule, createdBy uint) (*dto.Schedule, error) {
+					if tt.serviceError != nil {
+						return nil, tt.serviceError
+					}
+					schedule.ID = 7
+					return &schedule, nil
+				},
+			}
+
+			handler := NewScheduleHandler(mockService)
+
+			router := gin.New()
+			router.POST("/schedules", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.CreateSchedule(c)
+			})
+
+			bodyBytes, err := json.Marshal(tt.requestBody)
+			if err != nil {
+				t.Fatalf("Failed to marshal request body: %v", err)
+			}
+
+			req, _ := http.NewRequest("POST", "/schedules", bytes.NewBuffer(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
+
+func TestScheduleHandler_PauseSchedule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		scheduleID     string
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name:           "success",
+			scheduleID:     "3",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid schedule ID",
+			scheduleID:     "abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "not found",
+			scheduleID:     "3",
+			serviceError:   service.ErrScheduleNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "access denied",
+			scheduleID:     "3",
+			serviceError:   service.ErrScheduleAccessDenied,
+			wantStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockScheduleService{
+				PauseScheduleFunc: func(scheduleID uint, userID uint) error {
+					assert.Equal(t, uint(3), scheduleID)
+					return tt.serviceError
+				},
+			}
+
+			handler := NewScheduleHandler(mockService)
+
+			router := gin.New()
+			router.POST("/schedules/:id/pause", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.PauseSchedule(c)
+			})
+
+			req, _ := http.NewRequest("POST", "/schedules/"+tt.scheduleID+"/pause", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
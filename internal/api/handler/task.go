@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"rainchanel.com/internal/api/request"
 	"rainchanel.com/internal/api/response"
+	"rainchanel.com/internal/dto"
 	"rainchanel.com/internal/service"
 )
 
@@ -17,6 +21,10 @@ type TaskHandler interface {
 	PublishResult(*gin.Context)
 	PublishFailure(*gin.Context)
 	ConsumeResult(*gin.Context)
+	AckResult(*gin.Context)
+	CancelTask(*gin.Context)
+	Heartbeat(*gin.Context)
+	ReleaseTask(*gin.Context)
 }
 
 type taskHandler struct {
@@ -53,9 +61,73 @@ func (h *taskHandler) PublishTask(ctx *gin.Context) {
 		return
 	}
 
-	taskID, err := h.taskService.PublishTask(createTaskRequest.Task, userID.(uint))
+	if createTaskRequest.RunAt != nil && createTaskRequest.DelaySeconds > 0 {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Only one of run_at and delay_seconds may be set",
+			},
+		})
+		return
+	}
+
+	idempotencyKey := ctx.GetHeader("Idempotency-Key")
+	if idempotencyKey != "" && createTaskRequest.IdempotencyKey != "" && idempotencyKey != createTaskRequest.IdempotencyKey {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Idempotency-Key header and idempotency_key field do not match",
+			},
+		})
+		return
+	}
+	if idempotencyKey == "" {
+		idempotencyKey = createTaskRequest.IdempotencyKey
+	}
+	if len(idempotencyKey) > 255 {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Idempotency key must be at most 255 characters",
+			},
+		})
+		return
+	}
+
+	opts := dto.PublishOptions{
+		RunAt:          createTaskRequest.RunAt,
+		Delay:          time.Duration(createTaskRequest.DelaySeconds) * time.Second,
+		IdempotencyKey: idempotencyKey,
+	}
+
+	taskID, err := h.taskService.PublishTask(createTaskRequest.Task, userID.(uint), opts)
 
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidQueue) ||
+			errors.Is(err, service.ErrInvalidPriority) ||
+			errors.Is(err, service.ErrInvalidRetryPolicy) ||
+			errors.Is(err, service.ErrInvalidTimeout) ||
+			errors.Is(err, service.ErrInvalidDeadline) ||
+			errors.Is(err, service.ErrInvalidTTL) ||
+			errors.Is(err, service.ErrInvalidResultReference) {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrIdempotencyKeyConflict) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+
 		ctx.JSON(500, response.Response{
 			Error: &response.Error{
 				Code:    http.StatusInternalServerError,
@@ -73,10 +145,77 @@ func (h *taskHandler) PublishTask(ctx *gin.Context) {
 }
 
 func (h *taskHandler) ConsumeTask(ctx *gin.Context) {
-	task, err := h.taskService.ConsumeTask()
+	workerID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var queues []string
+	for _, queue := range strings.Split(ctx.Query("queue"), ",") {
+		if queue = strings.TrimSpace(queue); queue != "" {
+			queues = append(queues, queue)
+		}
+	}
+
+	var maxTasks int
+	batch := ctx.Query("max") != ""
+	if batch {
+		parsed, err := strconv.Atoi(ctx.Query("max"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: "Invalid max - must be an integer",
+				},
+			})
+			return
+		}
+		maxTasks = parsed
+	}
+
+	var wait time.Duration
+	if ctx.Query("wait") != "" {
+		parsed, err := time.ParseDuration(ctx.Query("wait"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: "Invalid wait - must be a duration such as 30s",
+				},
+			})
+			return
+		}
+		wait = parsed
+	}
+
+	var claimed []*dto.ClaimedTask
+	var err error
+	switch {
+	case wait != 0:
+		if !batch {
+			maxTasks = 1
+		}
+		claimed, err = h.taskService.ConsumeTasksWait(ctx.Request.Context(), workerID.(uint), queues, maxTasks, wait)
+	case batch:
+		claimed, err = h.taskService.ConsumeTasks(workerID.(uint), queues, maxTasks)
+	default:
+		var claimedTask *dto.ClaimedTask
+		claimedTask, err = h.taskService.ConsumeTask(workerID.(uint), queues)
+		claimed = []*dto.ClaimedTask{claimedTask}
+	}
 
 	if err != nil {
 		if errors.Is(err, service.ErrNoTasksAvailable) {
+			if wait != 0 {
+				ctx.Status(http.StatusNoContent)
+				return
+			}
 			ctx.JSON(http.StatusNotFound, response.Response{
 				Error: &response.Error{
 					Code:    http.StatusNotFound,
@@ -85,6 +224,15 @@ func (h *taskHandler) ConsumeTask(ctx *gin.Context) {
 			})
 			return
 		}
+		if errors.Is(err, service.ErrInvalidBatchSize) || errors.Is(err, service.ErrInvalidWait) {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
 
 		ctx.JSON(500, response.Response{
 			Error: &response.Error{
@@ -95,9 +243,25 @@ func (h *taskHandler) ConsumeTask(ctx *gin.Context) {
 		return
 	}
 
+	tasks := make([]response.ConsumeTaskResponse, 0, len(claimed))
+	for _, claimedTask := range claimed {
+		tasks = append(tasks, response.ConsumeTaskResponse{
+			Task:           claimedTask.Task,
+			ClaimToken:     claimedTask.ClaimToken,
+			LeaseExpiresAt: claimedTask.LeaseExpiresAt,
+		})
+	}
+
+	if !batch {
+		ctx.JSON(200, response.Response{
+			Data: tasks[0],
+		})
+		return
+	}
+
 	ctx.JSON(200, response.Response{
-		Data: response.ConsumeTaskResponse{
-			Task: *task,
+		Data: response.ConsumeTasksResponse{
+			Tasks: tasks,
 		},
 	})
 }
@@ -141,6 +305,7 @@ func (h *taskHandler) PublishResult(ctx *gin.Context) {
 		publishResultRequest.TaskID,
 		publishResultRequest.CreatedBy,
 		processedBy.(uint),
+		publishResultRequest.ClaimToken,
 		string(resultJSON),
 	)
 
@@ -163,6 +328,51 @@ func (h *taskHandler) PublishResult(ctx *gin.Context) {
 			})
 			return
 		}
+		if errors.Is(err, service.ErrTaskCancelled) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task cancelled",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrDeadlineExceeded) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task deadline exceeded",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrDuplicateResult) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task already has a result",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskNotClaimed) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task is no longer claimed by this worker",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidTransition) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
 
 		ctx.JSON(500, response.Response{
 			Error: &response.Error{
@@ -208,7 +418,14 @@ func (h *taskHandler) PublishFailure(ctx *gin.Context) {
 		publishFailureRequest.TaskID,
 		publishFailureRequest.CreatedBy,
 		processedBy.(uint),
-		publishFailureRequest.ErrorMsg,
+		publishFailureRequest.ClaimToken,
+		dto.Failure{
+			Code:      publishFailureRequest.Code,
+			Message:   publishFailureRequest.ErrorMsg,
+			Retryable: publishFailureRequest.Retryable,
+			TrapKind:  publishFailureRequest.TrapKind,
+			Details:   publishFailureRequest.Details,
+		},
 	)
 
 	if err != nil {
@@ -230,6 +447,42 @@ func (h *taskHandler) PublishFailure(ctx *gin.Context) {
 			})
 			return
 		}
+		if errors.Is(err, service.ErrTaskCancelled) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task cancelled",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrDeadlineExceeded) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task deadline exceeded",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskNotClaimed) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task is no longer claimed by this worker",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidTransition) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
 
 		ctx.JSON(500, response.Response{
 			Error: &response.Error{
@@ -259,7 +512,32 @@ func (h *taskHandler) ConsumeResult(ctx *gin.Context) {
 		return
 	}
 
-	result, err := h.taskService.ConsumeResult(userID.(uint))
+	var maxResults int
+	batch := ctx.Query("max") != ""
+	if batch {
+		parsed, err := strconv.Atoi(ctx.Query("max"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: "Invalid max - must be an integer",
+				},
+			})
+			return
+		}
+		maxResults = parsed
+	}
+
+	var leased []*dto.LeasedResult
+	var err error
+	if batch {
+		leased, err = h.taskService.ConsumeResults(userID.(uint), maxResults)
+	} else {
+		var leasedResult *dto.LeasedResult
+		leasedResult, err = h.taskService.ConsumeResult(userID.(uint))
+		leased = []*dto.LeasedResult{leasedResult}
+	}
+
 	if err != nil {
 		if errors.Is(err, service.ErrNoTasksAvailable) {
 			ctx.JSON(http.StatusNotFound, response.Response{
@@ -270,6 +548,370 @@ func (h *taskHandler) ConsumeResult(ctx *gin.Context) {
 			})
 			return
 		}
+		if errors.Is(err, service.ErrInvalidBatchSize) {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+
+		ctx.JSON(500, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	results := make([]response.ConsumeResultResponse, 0, len(leased))
+	for _, leasedResult := range leased {
+		results = append(results, response.ConsumeResultResponse{
+			Result:         leasedResult.Result,
+			Receipt:        leasedResult.Receipt,
+			LeaseExpiresAt: leasedResult.LeaseExpiresAt,
+		})
+	}
+
+	if !batch {
+		ctx.JSON(200, response.Response{
+			Data: results[0],
+		})
+		return
+	}
+
+	ctx.JSON(200, response.Response{
+		Data: response.ConsumeResultsResponse{
+			Results: results,
+		},
+	})
+}
+
+func (h *taskHandler) AckResult(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var ackRequest request.AckResultRequest
+
+	if err := ctx.ShouldBindJSON(&ackRequest); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	resultID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid result ID",
+			},
+		})
+		return
+	}
+
+	err = h.taskService.AckResult(uint(resultID), userID.(uint), ackRequest.Receipt)
+	if err != nil {
+		if errors.Is(err, service.ErrResultNotFound) {
+			ctx.JSON(http.StatusNotFound, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusNotFound,
+					Message: "Result not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrResultAccessDenied) {
+			ctx.JSON(http.StatusForbidden, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusForbidden,
+					Message: "Access denied: result does not belong to user",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrResultNotLeased) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Receipt has expired or been superseded by a newer delivery",
+				},
+			})
+			return
+		}
+
+		ctx.JSON(500, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, response.Response{
+		Data: response.PublishResultResponse{
+			Message: "Result acknowledged",
+		},
+	})
+}
+
+func (h *taskHandler) CancelTask(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	taskID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid task ID",
+			},
+		})
+		return
+	}
+
+	err = h.taskService.CancelTask(uint(taskID), userID.(uint))
+	if err != nil {
+		if errors.Is(err, service.ErrTaskNotFound) {
+			ctx.JSON(http.StatusNotFound, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusNotFound,
+					Message: "Task not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskAccessDenied) {
+			ctx.JSON(http.StatusForbidden, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusForbidden,
+					Message: "Access denied: task does not belong to user",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskNotCancellable) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task is already finished and cannot be cancelled",
+				},
+			})
+			return
+		}
+
+		ctx.JSON(500, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, response.Response{
+		Data: response.PublishResultResponse{
+			Message: "Task cancelled",
+		},
+	})
+}
+
+func (h *taskHandler) Heartbeat(ctx *gin.Context) {
+	var heartbeatRequest request.HeartbeatRequest
+
+	if err := ctx.ShouldBindJSON(&heartbeatRequest); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	taskID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid task ID",
+			},
+		})
+		return
+	}
+
+	leaseExpiresAt, err := h.taskService.Heartbeat(uint(taskID), heartbeatRequest.ClaimToken)
+	if err != nil {
+		if errors.Is(err, service.ErrTaskNotFound) {
+			ctx.JSON(http.StatusNotFound, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusNotFound,
+					Message: "Task not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskCancelled) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task cancelled",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrDeadlineExceeded) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task deadline exceeded",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskNotClaimed) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task is no longer claimed by this worker",
+				},
+			})
+			return
+		}
+
+		ctx.JSON(500, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(200, response.Response{
+		Data: response.HeartbeatResponse{
+			TaskID:         uint(taskID),
+			LeaseExpiresAt: leaseExpiresAt,
+		},
+	})
+}
+
+func (h *taskHandler) ReleaseTask(ctx *gin.Context) {
+	workerID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	var releaseRequest request.ReleaseTaskRequest
+
+	if err := ctx.ShouldBindJSON(&releaseRequest); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	taskID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid task ID",
+			},
+		})
+		return
+	}
+
+	delay := time.Duration(releaseRequest.DelaySeconds) * time.Second
+	availableAt, err := h.taskService.ReleaseTask(uint(taskID), workerID.(uint), releaseRequest.ClaimToken, delay, releaseRequest.Reason)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidReleaseDelay) {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskNotFound) {
+			ctx.JSON(http.StatusNotFound, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusNotFound,
+					Message: "Task not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskCancelled) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task cancelled",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrDeadlineExceeded) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task deadline exceeded",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskNotClaimed) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: "Task is no longer claimed by this worker",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidTransition) {
+			ctx.JSON(http.StatusConflict, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusConflict,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
 
 		ctx.JSON(500, response.Response{
 			Error: &response.Error{
@@ -281,8 +923,9 @@ func (h *taskHandler) ConsumeResult(ctx *gin.Context) {
 	}
 
 	ctx.JSON(200, response.Response{
-		Data: response.ConsumeResultResponse{
-			Result: *result,
+		Data: response.ReleaseTaskResponse{
+			TaskID:      uint(taskID),
+			AvailableAt: availableAt,
 		},
 	})
 }
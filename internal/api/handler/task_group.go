@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rainchanel.com/internal/api/request"
+	"rainchanel.com/internal/api/response"
+	"rainchanel.com/internal/service"
+)
+
+type TaskGroupHandler interface {
+	CreateTaskGroup(*gin.Context)
+	GetTaskGroup(*gin.Context)
+}
+
+type taskGroupHandler struct {
+	taskGroupService service.TaskGroupService
+}
+
+func NewTaskGroupHandler(taskGroupService service.TaskGroupService) TaskGroupHandler {
+	return &taskGroupHandler{
+		taskGroupService: taskGroupService,
+	}
+}
+
+func (h *taskGroupHandler) CreateTaskGroup(ctx *gin.Context) {
+	var createTaskGroupRequest request.CreateTaskGroupRequest
+
+	if err := ctx.ShouldBindJSON(&createTaskGroupRequest); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	group, err := h.taskGroupService.CreateTaskGroup(
+		createTaskGroupRequest.Tasks,
+		createTaskGroupRequest.Reduce,
+		createTaskGroupRequest.OnFailure,
+		userID.(uint),
+	)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTaskGroup) ||
+			errors.Is(err, service.ErrInvalidQueue) ||
+			errors.Is(err, service.ErrInvalidPriority) ||
+			errors.Is(err, service.ErrInvalidRetryPolicy) ||
+			errors.Is(err, service.ErrInvalidTimeout) ||
+			errors.Is(err, service.ErrInvalidDeadline) ||
+			errors.Is(err, service.ErrInvalidTTL) ||
+			errors.Is(err, service.ErrInvalidResultReference) {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.TaskGroupResponse{
+			Group: *group,
+		},
+	})
+}
+
+func (h *taskGroupHandler) GetTaskGroup(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	groupID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid task group ID",
+			},
+		})
+		return
+	}
+
+	group, err := h.taskGroupService.GetTaskGroup(uint(groupID), userID.(uint))
+	if err != nil {
+		if errors.Is(err, service.ErrTaskGroupNotFound) {
+			ctx.JSON(http.StatusNotFound, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusNotFound,
+					Message: "Task group not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrTaskGroupAccessDenied) {
+			ctx.JSON(http.StatusForbidden, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusForbidden,
+					Message: "Access denied: task group does not belong to user",
+				},
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.TaskGroupResponse{
+			Group: *group,
+		},
+	})
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/service"
+)
+
+type MockTaskGroupService struct {
+	CreateTaskGroupFunc func(tasks []dto.Task, reduce dto.TaskGroupReduce, onFailure string, createdBy uint) (*dto.TaskGroup, error)
+	GetTaskGroupFunc    func(groupID uint, userID uint) (*dto.TaskGroup, error)
+}
+
+func (m *MockTaskGroupService) CreateTaskGroup(tasks []dto.Task, reduce dto.TaskGroupReduce, onFailure string, createdBy uint) (*dto.TaskGroup, error) {
+	if m.CreateTaskGroupFunc != nil {
+		return m.CreateTaskGroupFunc(tasks, reduce, onFailure, createdBy)
+	}
+	return nil, nil
+}
+
+func (m *MockTaskGroupService) GetTaskGroup(groupID uint, userID uint) (*dto.TaskGroup, error) {
+	if m.GetTaskGroupFunc != nil {
+		return m.GetTaskGroupFunc(groupID, userID)
+	}
+	return nil, nil
+}
+
+func TestTaskGroupHandler_CreateTaskGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	validBody := map[string]any{
+		"tasks": []map[string]any{
+			{"wasm_module": "base64-module", "func": "square", "args": []int{2}},
+			{"wasm_module": "base64-module", "func": "square", "args": []int{3}},
+		},
+		"reduce":     map[string]any{"wasm_module": "base64-module", "func": "sum"},
+		"on_failure": "reduce_successes",
+	}
+
+	tests := []struct {
+		name           string
+		requestBody    any
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name:           "success",
+			requestBody:    validBody,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "missing reduce func",
+			requestBody: map[string]any{
+				"tasks":  validBody["tasks"],
+				"reduce": map[string]any{"wasm_module": "base64-module"},
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "no tasks",
+			requestBody:    map[string]any{"tasks": []any{}, "reduce": validBody["reduce"]},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid policy",
+			requestBody:    validBody,
+			serviceError:   service.ErrInvalidTaskGroup,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "service error",
+			requestBody:    validBody,
+			serviceError:   errors.New("database error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockTaskGroupService{
+				CreateTaskGroupFunc: func(tasks []dto.Task, reduce dto.TaskGroupReduce, onFailure string, createdBy uint) (*dto.TaskGroup, error) {
+					if tt.serviceError != nil {
+						return nil, tt.serviceError
+					}
+					assert.Len(t, tasks, 2)
+					assert.Equal(t, "sum", reduce.Func)
+					assert.Equal(t, "reduce_successes", onFailure)
+					return &dto.TaskGroup{ID: 1, Status: service.JobStatusRunning, TaskIDs: []uint{2, 3}, ReduceTaskID: 4}, nil
+				},
+			}
+			handler := NewTaskGroupHandler(mockService)
+
+			router := gin.New()
+			router.POST("/task-groups", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.CreateTaskGroup(c)
+			})
+
+			body, _ := json.Marshal(tt.requestBody)
+			req, _ := http.NewRequest("POST", "/task-groups", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
+
+func TestTaskGroupHandler_GetTaskGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		groupID        string
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name:           "success",
+			groupID:        "3",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid id",
+			groupID:        "abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "not found",
+			groupID:        "3",
+			serviceError:   service.ErrTaskGroupNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "access denied",
+			groupID:        "3",
+			serviceError:   service.ErrTaskGroupAccessDenied,
+			wantStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockTaskGroupService{
+				GetTaskGroupFunc: func(groupID uint, userID uint) (*dto.TaskGroup, error) {
+					if tt.serviceError != nil {
+						return nil, tt.serviceError
+					}
+					return &dto.TaskGroup{ID: groupID, Status: service.JobStatusCompleted}, nil
+				},
+			}
+			handler := NewTaskGroupHandler(mockService)
+
+			router := gin.New()
+			router.GET("/task-groups/:id", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.GetTaskGroup(c)
+			})
+
+			req, _ := http.NewRequest("GET", "/task-groups/"+tt.groupID, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
@@ -2,60 +2,105 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"rainchanel.com/internal/api/request"
 	"rainchanel.com/internal/api/response"
 	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
 	"rainchanel.com/internal/service"
 )
 
 type MockTaskService struct {
-	PublishTaskFunc       func(task dto.Task, createdBy uint) (uint, error)
-	ConsumeTaskFunc       func() (*dto.Task, error)
-	PublishResultFunc     func(taskID uint, createdBy uint, processedBy uint, result string) error
-	PublishFailureFunc    func(taskID uint, createdBy uint, processedBy uint, errorMsg string) error
-	ConsumeResultFunc     func(userID uint) (*dto.Result, error)
+	PublishTaskFunc       func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error)
+	ConsumeTaskFunc       func(workerID uint, queues []string) (*dto.ClaimedTask, error)
+	ConsumeTasksFunc      func(workerID uint, queues []string, maxTasks int) ([]*dto.ClaimedTask, error)
+	ConsumeTasksWaitFunc  func(ctx context.Context, workerID uint, queues []string, maxTasks int, wait time.Duration) ([]*dto.ClaimedTask, error)
+	PublishResultFunc     func(taskID uint, createdBy uint, processedBy uint, claimToken string, result string) error
+	PublishFailureFunc    func(taskID uint, createdBy uint, processedBy uint, claimToken string, failure dto.Failure) error
+	ConsumeResultFunc     func(userID uint) (*dto.LeasedResult, error)
+	ConsumeResultsFunc    func(userID uint, maxResults int) ([]*dto.LeasedResult, error)
+	AckResultFunc         func(resultID uint, userID uint, receipt string) error
 	ReclaimStaleTasksFunc func() (int, error)
+	FailOverdueTasksFunc  func() (int, error)
+	ExpireTasksFunc       func() (int, error)
+	CancelTaskFunc        func(taskID uint, userID uint) error
+	RequeueTaskFunc       func(taskID uint, userID uint) error
+	HeartbeatFunc         func(taskID uint, claimToken string) (time.Time, error)
+	ReleaseTaskFunc       func(taskID uint, workerID uint, claimToken string, delay time.Duration, reason string) (time.Time, error)
 }
 
-func (m *MockTaskService) PublishTask(task dto.Task, createdBy uint) (uint, error) {
+func (m *MockTaskService) PublishTask(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
 	if m.PublishTaskFunc != nil {
-		return m.PublishTaskFunc(task, createdBy)
+		return m.PublishTaskFunc(task, createdBy, opts)
 	}
 	return 0, nil
 }
 
-func (m *MockTaskService) ConsumeTask() (*dto.Task, error) {
+func (m *MockTaskService) PublishTaskBatch(createdBy uint, build func(repos repository.Repositories, publish service.TaskPublisher) error) error {
+	return nil
+}
+
+func (m *MockTaskService) ConsumeTask(workerID uint, queues []string) (*dto.ClaimedTask, error) {
 	if m.ConsumeTaskFunc != nil {
-		return m.ConsumeTaskFunc()
+		return m.ConsumeTaskFunc(workerID, queues)
+	}
+	return nil, nil
+}
+
+func (m *MockTaskService) ConsumeTasks(workerID uint, queues []string, maxTasks int) ([]*dto.ClaimedTask, error) {
+	if m.ConsumeTasksFunc != nil {
+		return m.ConsumeTasksFunc(workerID, queues, maxTasks)
+	}
+	return nil, nil
+}
+
+func (m *MockTaskService) ConsumeTasksWait(ctx context.Context, workerID uint, queues []string, maxTasks int, wait time.Duration) ([]*dto.ClaimedTask, error) {
+	if m.ConsumeTasksWaitFunc != nil {
+		return m.ConsumeTasksWaitFunc(ctx, workerID, queues, maxTasks, wait)
 	}
 	return nil, nil
 }
 
-func (m *MockTaskService) PublishResult(taskID uint, createdBy uint, processedBy uint, result string) error {
+func (m *MockTaskService) PublishResult(taskID uint, createdBy uint, processedBy uint, claimToken string, result string) error {
 	if m.PublishResultFunc != nil {
-		return m.PublishResultFunc(taskID, createdBy, processedBy, result)
+		return m.PublishResultFunc(taskID, createdBy, processedBy, claimToken, result)
 	}
 	return nil
 }
 
-func (m *MockTaskService) ConsumeResult(userID uint) (*dto.Result, error) {
+func (m *MockTaskService) ConsumeResult(userID uint) (*dto.LeasedResult, error) {
 	if m.ConsumeResultFunc != nil {
 		return m.ConsumeResultFunc(userID)
 	}
 	return nil, nil
 }
 
-func (m *MockTaskService) PublishFailure(taskID uint, createdBy uint, processedBy uint, errorMsg string) error {
+func (m *MockTaskService) ConsumeResults(userID uint, maxResults int) ([]*dto.LeasedResult, error) {
+	if m.ConsumeResultsFunc != nil {
+		return m.ConsumeResultsFunc(userID, maxResults)
+	}
+	return nil, nil
+}
+
+func (m *MockTaskService) AckResult(resultID uint, userID uint, receipt string) error {
+	if m.AckResultFunc != nil {
+		return m.AckResultFunc(resultID, userID, receipt)
+	}
+	return nil
+}
+
+func (m *MockTaskService) PublishFailure(taskID uint, createdBy uint, processedBy uint, claimToken string, failure dto.Failure) error {
 	if m.PublishFailureFunc != nil {
-		return m.PublishFailureFunc(taskID, createdBy, processedBy, errorMsg)
+		return m.PublishFailureFunc(taskID, createdBy, processedBy, claimToken, failure)
 	}
 	return nil
 }
@@ -67,6 +112,47 @@ func (m *MockTaskService) ReclaimStaleTasks() (int, error) {
 	return 0, nil
 }
 
+func (m *MockTaskService) FailOverdueTasks() (int, error) {
+	if m.FailOverdueTasksFunc != nil {
+		return m.FailOverdueTasksFunc()
+	}
+	return 0, nil
+}
+func (m *MockTaskService) ExpireTasks() (int, error) {
+	if m.ExpireTasksFunc != nil {
+		return m.ExpireTasksFunc()
+	}
+	return 0, nil
+}
+
+func (m *MockTaskService) CancelTask(taskID uint, userID uint) error {
+	if m.CancelTaskFunc != nil {
+		return m.CancelTaskFunc(taskID, userID)
+	}
+	return nil
+}
+
+func (m *MockTaskService) RequeueTask(taskID uint, userID uint) error {
+	if m.RequeueTaskFunc != nil {
+		return m.RequeueTaskFunc(taskID, userID)
+	}
+	return nil
+}
+
+func (m *MockTaskService) Heartbeat(taskID uint, claimToken string) (time.Time, error) {
+	if m.HeartbeatFunc != nil {
+		return m.HeartbeatFunc(taskID, claimToken)
+	}
+	return time.Time{}, nil
+}
+
+func (m *MockTaskService) ReleaseTask(taskID uint, workerID uint, claimToken string, delay time.Duration, reason string) (time.Time, error) {
+	if m.ReleaseTaskFunc != nil {
+		return m.ReleaseTaskFunc(taskID, workerID, claimToken, delay, reason)
+	}
+	return time.Time{}, nil
+}
+
 func TestNewTaskHandler(t *testing.T) {
 	mockService := &MockTaskService{}
 	handler := NewTaskHandler(mockService)
@@ -130,12 +216,39 @@ func TestTaskHandler_PublishTask(t *testing.T) {
 			serviceTaskID:  0,
 			wantStatusCode: http.StatusInternalServerError,
 		},
+		{
+			name: "reference to another user's task",
+			requestBody: request.PublishTaskRequest{
+				Task: dto.Task{
+					WasmModule: "base64-module",
+					Func:       "testFunc",
+					Args:       []any{map[string]any{"$from_task": 99}},
+				},
+			},
+			serviceError:   service.ErrInvalidResultReference,
+			serviceTaskID:  0,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "retry policy over server limit",
+			requestBody: request.PublishTaskRequest{
+				Task: dto.Task{
+					WasmModule: "base64-module",
+					Func:       "testFunc",
+					Args:       []any{1, 2},
+					Retry:      &dto.RetryPolicy{Backoff: "random"},
+				},
+			},
+			serviceError:   service.ErrInvalidRetryPolicy,
+			serviceTaskID:  0,
+			wantStatusCode: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockService := &MockTaskService{
-				PublishTaskFunc: func(task dto.Task, createdBy uint) (uint, error) {
+				PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
 					return tt.serviceTaskID, tt.serviceError
 				},
 			}
@@ -202,22 +315,205 @@ func TestTaskHandler_PublishTask(t *testing.T) {
 	}
 }
 
+func TestTaskHandler_PublishTask_Schedule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	runAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name           string
+		requestBody    map[string]any
+		wantStatusCode int
+		checkOpts      func(t *testing.T, opts dto.PublishOptions)
+	}{
+		{
+			name: "immediate",
+			requestBody: map[string]any{
+				"task": map[string]any{"wasm_module": "base64-module", "func": "testFunc"},
+			},
+			wantStatusCode: http.StatusOK,
+			checkOpts: func(t *testing.T, opts dto.PublishOptions) {
+				assert.Nil(t, opts.RunAt)
+			},
+		},
+		{
+			name: "run_at",
+			requestBody: map[string]any{
+				"task":   map[string]any{"wasm_module": "base64-module", "func": "testFunc"},
+				"run_at": runAt.Format(time.RFC3339),
+			},
+			wantStatusCode: http.StatusOK,
+			checkOpts: func(t *testing.T, opts dto.PublishOptions) {
+				if assert.NotNil(t, opts.RunAt) {
+					assert.True(t, runAt.Equal(*opts.RunAt))
+				}
+			},
+		},
+		{
+			name: "delay_seconds",
+			requestBody: map[string]any{
+				"task":          map[string]any{"wasm_module": "base64-module", "func": "testFunc"},
+				"delay_seconds": 120,
+			},
+			wantStatusCode: http.StatusOK,
+			checkOpts: func(t *testing.T, opts dto.PublishOptions) {
+				assert.Nil(t, opts.RunAt)
+				assert.Equal(t, 120*time.Second, opts.Delay)
+			},
+		},
+		{
+			name: "both run_at and delay_seconds",
+			requestBody: map[string]any{
+				"task":          map[string]any{"wasm_module": "base64-module", "func": "testFunc"},
+				"run_at":        runAt.Format(time.RFC3339),
+				"delay_seconds": 120,
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "negative delay_seconds",
+			requestBody: map[string]any{
+				"task":          map[string]any{"wasm_module": "base64-module", "func": "testFunc"},
+				"delay_seconds": -5,
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotOpts dto.PublishOptions
+			mockService := &MockTaskService{
+				PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+					gotOpts = opts
+					return 1, nil
+				},
+			}
+
+			handler := NewTaskHandler(mockService)
+
+			router := gin.New()
+			router.POST("/tasks", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.PublishTask(c)
+			})
+
+			bodyBytes, err := json.Marshal(tt.requestBody)
+			if err != nil {
+				t.Fatalf("Failed to marshal request body: %v", err)
+			}
+
+			req, _ := http.NewRequest("POST", "/tasks", bytes.NewBuffer(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			if tt.checkOpts != nil {
+				tt.checkOpts(t, gotOpts)
+			}
+		})
+	}
+}
+
+func TestTaskHandler_PublishTask_IdempotencyKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		header         string
+		field          string
+		serviceError   error
+		wantKey        string
+		wantStatusCode int
+	}{
+		{
+			name:           "key from header",
+			header:         "retry-1",
+			wantKey:        "retry-1",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "key from body",
+			field:          "retry-1",
+			wantKey:        "retry-1",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "header and body disagree",
+			header:         "retry-1",
+			field:          "retry-2",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "key reused for a different task",
+			header:         "retry-1",
+			serviceError:   service.ErrIdempotencyKeyConflict,
+			wantKey:        "retry-1",
+			wantStatusCode: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotKey string
+			mockService := &MockTaskService{
+				PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+					gotKey = opts.IdempotencyKey
+					return 123, tt.serviceError
+				},
+			}
+			handler := NewTaskHandler(mockService)
+
+			body, err := json.Marshal(request.PublishTaskRequest{
+				Task: dto.Task{
+					WasmModule: "base64-module",
+					Func:       "testFunc",
+				},
+				IdempotencyKey: tt.field,
+			})
+			assert.NoError(t, err)
+
+			router := gin.New()
+			router.POST("/tasks", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.PublishTask(c)
+			})
+
+			req, _ := http.NewRequest("POST", "/tasks", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.header != "" {
+				req.Header.Set("Idempotency-Key", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantKey, gotKey)
+		})
+	}
+}
+
 func TestTaskHandler_ConsumeTask(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	tests := []struct {
 		name           string
-		serviceTask    *dto.Task
+		serviceTask    *dto.ClaimedTask
 		serviceError   error
 		wantStatusCode int
 	}{
 		{
 			name: "success",
-			serviceTask: &dto.Task{
-				ID:         123,
-				WasmModule: "base64-module",
-				Func:       "testFunc",
-				Args:       []string{"arg1"},
+			serviceTask: &dto.ClaimedTask{
+				Task: dto.Task{
+					ID:         123,
+					WasmModule: "base64-module",
+					Func:       "testFunc",
+					Args:       []string{"arg1"},
+				},
+				LeaseExpiresAt: time.Now().Add(5 * time.Minute),
 			},
 			serviceError:   nil,
 			wantStatusCode: http.StatusOK,
@@ -239,7 +535,8 @@ func TestTaskHandler_ConsumeTask(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockService := &MockTaskService{
-				ConsumeTaskFunc: func() (*dto.Task, error) {
+				ConsumeTaskFunc: func(workerID uint, queues []string) (*dto.ClaimedTask, error) {
+					assert.Equal(t, uint(2), workerID)
 					return tt.serviceTask, tt.serviceError
 				},
 			}
@@ -247,7 +544,10 @@ func TestTaskHandler_ConsumeTask(t *testing.T) {
 			handler := NewTaskHandler(mockService)
 
 			router := gin.New()
-			router.GET("/tasks", handler.ConsumeTask)
+			router.GET("/tasks", func(c *gin.Context) {
+				c.Set("user_id", uint(2))
+				handler.ConsumeTask(c)
+			})
 
 			req, _ := http.NewRequest("GET", "/tasks", nil)
 			w := httptest.NewRecorder()
@@ -283,82 +583,348 @@ func TestTaskHandler_ConsumeTask(t *testing.T) {
 							t.Errorf("task.id is not a number, got %T", v)
 							return
 						}
-						if id != tt.serviceTask.ID {
-							t.Errorf("task.id = %v, want %v", id, tt.serviceTask.ID)
+						if id != tt.serviceTask.Task.ID {
+							t.Errorf("task.id = %v, want %v", id, tt.serviceTask.Task.ID)
 						}
 					}
+					assert.NotEmpty(t, consumeResp["lease_expires_at"])
 				}
 			}
 		})
 	}
 }
 
-func TestTaskHandler_PublishResult(t *testing.T) {
+func TestTaskHandler_ConsumeTask_QueueFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantQueues []string
+	}{
+		{
+			name:       "no queue filter",
+			query:      "",
+			wantQueues: nil,
+		},
+		{
+			name:       "single queue",
+			query:      "?queue=gpu-sim",
+			wantQueues: []string{"gpu-sim"},
+		},
+		{
+			name:       "multiple queues with whitespace",
+			query:      "?queue=gpu-sim,%20default,",
+			wantQueues: []string{"gpu-sim", "default"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotQueues []string
+			mockService := &MockTaskService{
+				ConsumeTaskFunc: func(workerID uint, queues []string) (*dto.ClaimedTask, error) {
+					gotQueues = queues
+					return nil, service.ErrNoTasksAvailable
+				},
+			}
+
+			handler := NewTaskHandler(mockService)
+
+			router := gin.New()
+			router.GET("/tasks", func(c *gin.Context) {
+				c.Set("user_id", uint(2))
+				handler.ConsumeTask(c)
+			})
+
+			req, _ := http.NewRequest("GET", "/tasks"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantQueues, gotQueues)
+		})
+	}
+}
+
+func TestTaskHandler_ConsumeTask_Batch(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	tests := []struct {
 		name           string
-		requestBody    interface{}
+		query          string
 		serviceError   error
+		wantMaxTasks   int
 		wantStatusCode int
 	}{
 		{
-			name: "success",
-			requestBody: map[string]interface{}{
-				"task_id":    123,
-				"created_by": 1,
-				"result":     "success",
-			},
-			serviceError:   nil,
+			name:           "batch claim",
+			query:          "?max=16",
+			wantMaxTasks:   16,
 			wantStatusCode: http.StatusOK,
 		},
 		{
-			name: "missing task_id in body",
-			requestBody: map[string]interface{}{
-				"result": "success",
-			},
-			serviceError:   nil,
+			name:           "non-numeric max",
+			query:          "?max=lots",
 			wantStatusCode: http.StatusBadRequest,
 		},
 		{
-			name: "missing result in body",
-			requestBody: map[string]interface{}{
-				"task_id":    123,
-				"created_by": 1,
-			},
-			serviceError:   nil,
+			name:           "max out of range",
+			query:          "?max=1000",
+			serviceError:   service.ErrInvalidBatchSize,
+			wantMaxTasks:   1000,
 			wantStatusCode: http.StatusBadRequest,
 		},
 		{
-			name: "service error",
-			requestBody: map[string]interface{}{
-				"task_id":    123,
-				"created_by": 1,
-				"result":     "success",
-			},
-			serviceError:   errors.New("service error"),
-			wantStatusCode: http.StatusInternalServerError,
+			name:           "no tasks available",
+			query:          "?max=4",
+			serviceError:   service.ErrNoTasksAvailable,
+			wantMaxTasks:   4,
+			wantStatusCode: http.StatusNotFound,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			var gotMaxTasks int
 			mockService := &MockTaskService{
-				PublishResultFunc: func(taskID uint, createdBy uint, processedBy uint, result string) error {
-					return tt.serviceError
+				ConsumeTasksFunc: func(workerID uint, queues []string, maxTasks int) ([]*dto.ClaimedTask, error) {
+					gotMaxTasks = maxTasks
+					if tt.serviceError != nil {
+						return nil, tt.serviceError
+					}
+					return []*dto.ClaimedTask{
+						{Task: dto.Task{ID: 1}, ClaimToken: "token-1"},
+						{Task: dto.Task{ID: 2}, ClaimToken: "token-2"},
+					}, nil
 				},
 			}
 
 			handler := NewTaskHandler(mockService)
 
 			router := gin.New()
-			router.POST("/results", func(c *gin.Context) {
-				c.Set("user_id", uint(1))
-				c.Set("username", "testuser")
-				handler.PublishResult(c)
+			router.GET("/tasks", func(c *gin.Context) {
+				c.Set("user_id", uint(2))
+				handler.ConsumeTask(c)
 			})
 
-			bodyBytes, err := json.Marshal(tt.requestBody)
+			req, _ := http.NewRequest("GET", "/tasks"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantMaxTasks, gotMaxTasks)
+
+			if tt.wantStatusCode == http.StatusOK {
+				var resp struct {
+					Data response.ConsumeTasksResponse `json:"data"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Len(t, resp.Data.Tasks, 2)
+				assert.Equal(t, "token-2", resp.Data.Tasks[1].ClaimToken)
+			}
+		})
+	}
+}
+
+func TestTaskHandler_ConsumeTask_Wait(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		query          string
+		serviceError   error
+		wantWait       time.Duration
+		wantMaxTasks   int
+		wantStatusCode int
+	}{
+		{
+			name:           "task arrives while waiting",
+			query:          "?wait=30s",
+			wantWait:       30 * time.Second,
+			wantMaxTasks:   1,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "batch with wait",
+			query:          "?wait=10s&max=8",
+			wantWait:       10 * time.Second,
+			wantMaxTasks:   8,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "timed out",
+			query:          "?wait=30s",
+			serviceError:   service.ErrNoTasksAvailable,
+			wantWait:       30 * time.Second,
+			wantMaxTasks:   1,
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "unparseable wait",
+			query:          "?wait=soon",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "wait above the limit",
+			query:          "?wait=10m",
+			serviceError:   service.ErrInvalidWait,
+			wantWait:       10 * time.Minute,
+			wantMaxTasks:   1,
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotWait time.Duration
+			var gotMaxTasks int
+			mockService := &MockTaskService{
+				ConsumeTasksWaitFunc: func(ctx context.Context, workerID uint, queues []string, maxTasks int, wait time.Duration) ([]*dto.ClaimedTask, error) {
+					gotWait = wait
+					gotMaxTasks = maxTasks
+					if tt.serviceError != nil {
+						return nil, tt.serviceError
+					}
+					return []*dto.ClaimedTask{{Task: dto.Task{ID: 1}, ClaimToken: "token-1"}}, nil
+				},
+			}
+
+			handler := NewTaskHandler(mockService)
+
+			router := gin.New()
+			router.GET("/tasks", func(c *gin.Context) {
+				c.Set("user_id", uint(2))
+				handler.ConsumeTask(c)
+			})
+
+			req, _ := http.NewRequest("GET", "/tasks"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantWait, gotWait)
+			assert.Equal(t, tt.wantMaxTasks, gotMaxTasks)
+			if tt.wantStatusCode == http.StatusNoContent {
+				assert.Empty(t, w.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestTaskHandler_PublishResult(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name: "success",
+			requestBody: map[string]interface{}{
+				"task_id":     123,
+				"claim_token": "claim-token",
+				"created_by":  1,
+				"result":      "success",
+			},
+			serviceError:   nil,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "missing claim_token in body",
+			requestBody: map[string]interface{}{
+				"task_id":    123,
+				"created_by": 1,
+				"result":     "success",
+			},
+			serviceError:   nil,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "missing task_id in body",
+			requestBody: map[string]interface{}{
+				"result": "success",
+			},
+			serviceError:   nil,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "missing result in body",
+			requestBody: map[string]interface{}{
+				"task_id":    123,
+				"created_by": 1,
+			},
+			serviceError:   nil,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "stale claim token",
+			requestBody: map[string]interface{}{
+				"task_id":     123,
+				"claim_token": "claim-token",
+				"created_by":  1,
+				"result":      "success",
+			},
+			serviceError:   service.ErrTaskNotClaimed,
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name: "invalid transition",
+			requestBody: map[string]interface{}{
+				"task_id":     123,
+				"claim_token": "claim-token",
+				"created_by":  1,
+				"result":      "success",
+			},
+			serviceError:   service.ErrInvalidTransition,
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name: "duplicate result",
+			requestBody: map[string]interface{}{
+				"task_id":     123,
+				"claim_token": "claim-token",
+				"created_by":  1,
+				"result":      "success",
+			},
+			serviceError:   service.ErrDuplicateResult,
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name: "service error",
+			requestBody: map[string]interface{}{
+				"task_id":     123,
+				"claim_token": "claim-token",
+				"created_by":  1,
+				"result":      "success",
+			},
+			serviceError:   errors.New("service error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockTaskService{
+				PublishResultFunc: func(taskID uint, createdBy uint, processedBy uint, claimToken string, result string) error {
+					return tt.serviceError
+				},
+			}
+
+			handler := NewTaskHandler(mockService)
+
+			router := gin.New()
+			router.POST("/results", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				c.Set("username", "testuser")
+				handler.PublishResult(c)
+			})
+
+			bodyBytes, err := json.Marshal(tt.requestBody)
 			if err != nil {
 				t.Fatalf("Failed to marshal request body: %v", err)
 			}
@@ -446,9 +1012,10 @@ func TestTaskHandler_PublishFailure(t *testing.T) {
 		{
 			name: "success",
 			requestBody: request.PublishFailureRequest{
-				TaskID:    123,
-				CreatedBy: 1,
-				ErrorMsg:  "execution failed",
+				TaskID:     123,
+				ClaimToken: "claim-token",
+				CreatedBy:  1,
+				ErrorMsg:   "execution failed",
 			},
 			serviceError:   nil,
 			wantStatusCode: http.StatusOK,
@@ -460,9 +1027,10 @@ func TestTaskHandler_PublishFailure(t *testing.T) {
 		{
 			name: "task not found",
 			requestBody: request.PublishFailureRequest{
-				TaskID:    999,
-				CreatedBy: 1,
-				ErrorMsg:  "execution failed",
+				TaskID:     999,
+				ClaimToken: "claim-token",
+				CreatedBy:  1,
+				ErrorMsg:   "execution failed",
 			},
 			serviceError:   service.ErrTaskNotFound,
 			wantStatusCode: http.StatusNotFound,
@@ -474,9 +1042,10 @@ func TestTaskHandler_PublishFailure(t *testing.T) {
 		{
 			name: "invalid created_by",
 			requestBody: request.PublishFailureRequest{
-				TaskID:    123,
-				CreatedBy: 2,
-				ErrorMsg:  "execution failed",
+				TaskID:     123,
+				ClaimToken: "claim-token",
+				CreatedBy:  2,
+				ErrorMsg:   "execution failed",
 			},
 			serviceError:   service.ErrInvalidCreatedBy,
 			wantStatusCode: http.StatusForbidden,
@@ -485,12 +1054,28 @@ func TestTaskHandler_PublishFailure(t *testing.T) {
 				c.Set("username", "worker")
 			},
 		},
+		{
+			name: "task cancelled",
+			requestBody: request.PublishFailureRequest{
+				TaskID:     123,
+				ClaimToken: "claim-token",
+				CreatedBy:  1,
+				ErrorMsg:   "execution failed",
+			},
+			serviceError:   service.ErrTaskCancelled,
+			wantStatusCode: http.StatusConflict,
+			setupAuth: func(c *gin.Context) {
+				c.Set("user_id", uint(2))
+				c.Set("username", "worker")
+			},
+		},
 		{
 			name: "unauthorized - no user_id",
 			requestBody: request.PublishFailureRequest{
-				TaskID:    123,
-				CreatedBy: 1,
-				ErrorMsg:  "execution failed",
+				TaskID:     123,
+				ClaimToken: "claim-token",
+				CreatedBy:  1,
+				ErrorMsg:   "execution failed",
 			},
 			wantStatusCode: http.StatusUnauthorized,
 			setupAuth: func(c *gin.Context) {
@@ -511,7 +1096,7 @@ func TestTaskHandler_PublishFailure(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockService := &MockTaskService{
-				PublishFailureFunc: func(taskID uint, createdBy uint, processedBy uint, errorMsg string) error {
+				PublishFailureFunc: func(taskID uint, createdBy uint, processedBy uint, claimToken string, failure dto.Failure) error {
 					return tt.serviceError
 				},
 			}
@@ -550,3 +1135,509 @@ func TestTaskHandler_PublishFailure(t *testing.T) {
 		})
 	}
 }
+
+func TestTaskHandler_PublishFailure_StructuredFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var received dto.Failure
+	mockService := &MockTaskService{
+		PublishFailureFunc: func(taskID uint, createdBy uint, processedBy uint, claimToken string, failure dto.Failure) error {
+			received = failure
+			return nil
+		},
+	}
+	handler := NewTaskHandler(mockService)
+
+	router := gin.New()
+	router.POST("/failures", func(c *gin.Context) {
+		c.Set("user_id", uint(2))
+		handler.PublishFailure(c)
+	})
+
+	reqBody := []byte(`{"task_id": 123, "claim_token": "claim-token", "created_by": 1,
+		"code": "div_by_zero", "error_msg": "integer divide by zero", "retryable": false,
+		"trap_kind": "integer_divide_by_zero", "details": "at divide (wasm-function[3])"}`)
+	req, _ := http.NewRequest("POST", "/failures", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	notRetryable := false
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, dto.Failure{
+		Code:      "div_by_zero",
+		Message:   "integer divide by zero",
+		Retryable: &notRetryable,
+		TrapKind:  "integer_divide_by_zero",
+		Details:   "at divide (wasm-function[3])",
+	}, received)
+}
+func TestTaskHandler_CancelTask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		taskID         string
+		serviceError   error
+		wantStatusCode int
+		setupAuth      func(*gin.Context)
+	}{
+		{
+			name:           "success",
+			taskID:         "123",
+			wantStatusCode: http.StatusOK,
+			setupAuth: func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+			},
+		},
+		{
+			name:           "invalid task ID",
+			taskID:         "abc",
+			wantStatusCode: http.StatusBadRequest,
+			setupAuth: func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+			},
+		},
+		{
+			name:           "task not found",
+			taskID:         "999",
+			serviceError:   service.ErrTaskNotFound,
+			wantStatusCode: http.StatusNotFound,
+			setupAuth: func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+			},
+		},
+		{
+			name:           "not the owner",
+			taskID:         "123",
+			serviceError:   service.ErrTaskAccessDenied,
+			wantStatusCode: http.StatusForbidden,
+			setupAuth: func(c *gin.Context) {
+				c.Set("user_id", uint(2))
+			},
+		},
+		{
+			name:           "already finished",
+			taskID:         "123",
+			serviceError:   service.ErrTaskNotCancellable,
+			wantStatusCode: http.StatusConflict,
+			setupAuth: func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+			},
+		},
+		{
+			name:           "unauthorized - no user_id",
+			taskID:         "123",
+			wantStatusCode: http.StatusUnauthorized,
+			setupAuth: func(c *gin.Context) {
+
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockTaskService{
+				CancelTaskFunc: func(taskID uint, userID uint) error {
+					return tt.serviceError
+				},
+			}
+			handler := NewTaskHandler(mockService)
+
+			router := gin.New()
+			router.POST("/tasks/:id/cancel", func(c *gin.Context) {
+				tt.setupAuth(c)
+				handler.CancelTask(c)
+			})
+
+			req, _ := http.NewRequest("POST", "/tasks/"+tt.taskID+"/cancel", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
+
+func TestTaskHandler_Heartbeat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		taskID         string
+		serviceError   error
+		wantStatusCode int
+		wantMessage    string
+	}{
+		{
+			name:           "success",
+			taskID:         "123",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid task ID",
+			taskID:         "abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "task not found",
+			taskID:         "999",
+			serviceError:   service.ErrTaskNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "task cancelled",
+			taskID:         "123",
+			serviceError:   service.ErrTaskCancelled,
+			wantStatusCode: http.StatusConflict,
+			wantMessage:    "Task cancelled",
+		},
+		{
+			name:           "deadline exceeded",
+			taskID:         "123",
+			serviceError:   service.ErrDeadlineExceeded,
+			wantStatusCode: http.StatusConflict,
+			wantMessage:    "Task deadline exceeded",
+		},
+		{
+			name:           "task reassigned",
+			taskID:         "123",
+			serviceError:   service.ErrTaskNotClaimed,
+			wantStatusCode: http.StatusConflict,
+			wantMessage:    "Task is no longer claimed by this worker",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockTaskService{
+				HeartbeatFunc: func(taskID uint, claimToken string) (time.Time, error) {
+					return time.Now().Add(5 * time.Minute), tt.serviceError
+				},
+			}
+			handler := NewTaskHandler(mockService)
+
+			router := gin.New()
+			router.POST("/tasks/:id/heartbeat", func(c *gin.Context) {
+				c.Set("user_id", uint(2))
+				handler.Heartbeat(c)
+			})
+
+			body, _ := json.Marshal(request.HeartbeatRequest{ClaimToken: "claim-token"})
+			req, _ := http.NewRequest("POST", "/tasks/"+tt.taskID+"/heartbeat", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+
+			var resp response.Response
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			if tt.wantMessage != "" {
+				assert.Equal(t, tt.wantMessage, resp.Error.Message)
+			}
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Nil(t, resp.Error)
+				assert.NotNil(t, resp.Data)
+			}
+		})
+	}
+}
+
+func TestTaskHandler_ReleaseTask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		taskID         string
+		body           string
+		serviceError   error
+		wantStatusCode int
+		wantDelay      time.Duration
+		wantMessage    string
+	}{
+		{
+			name:           "success",
+			taskID:         "123",
+			body:           `{"claim_token":"claim-token"}`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "success with delay and reason",
+			taskID:         "123",
+			body:           `{"claim_token":"claim-token","delay_seconds":30,"reason":"out of memory"}`,
+			wantStatusCode: http.StatusOK,
+			wantDelay:      30 * time.Second,
+		},
+		{
+			name:           "missing claim token",
+			taskID:         "123",
+			body:           `{}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "negative delay",
+			taskID:         "123",
+			body:           `{"claim_token":"claim-token","delay_seconds":-1}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid task ID",
+			taskID:         "abc",
+			body:           `{"claim_token":"claim-token"}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "delay above max",
+			taskID:         "123",
+			body:           `{"claim_token":"claim-token","delay_seconds":100000}`,
+			serviceError:   service.ErrInvalidReleaseDelay,
+			wantStatusCode: http.StatusBadRequest,
+			wantDelay:      100000 * time.Second,
+		},
+		{
+			name:           "task not found",
+			taskID:         "999",
+			body:           `{"claim_token":"claim-token"}`,
+			serviceError:   service.ErrTaskNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "not the claimant",
+			taskID:         "123",
+			body:           `{"claim_token":"claim-token"}`,
+			serviceError:   service.ErrTaskNotClaimed,
+			wantStatusCode: http.StatusConflict,
+			wantMessage:    "Task is no longer claimed by this worker",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockTaskService{
+				ReleaseTaskFunc: func(taskID uint, workerID uint, claimToken string, delay time.Duration, reason string) (time.Time, error) {
+					assert.Equal(t, uint(2), workerID)
+					assert.Equal(t, "claim-token", claimToken)
+					assert.Equal(t, tt.wantDelay, delay)
+					return time.Now().Add(delay), tt.serviceError
+				},
+			}
+			handler := NewTaskHandler(mockService)
+
+			router := gin.New()
+			router.POST("/tasks/:id/release", func(c *gin.Context) {
+				c.Set("user_id", uint(2))
+				handler.ReleaseTask(c)
+			})
+
+			req, _ := http.NewRequest("POST", "/tasks/"+tt.taskID+"/release", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+
+			var resp response.Response
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			if tt.wantMessage != "" {
+				assert.Equal(t, tt.wantMessage, resp.Error.Message)
+			}
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Nil(t, resp.Error)
+				assert.NotNil(t, resp.Data)
+			}
+		})
+	}
+}
+
+func TestTaskHandler_ConsumeResult(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		query          string
+		serviceError   error
+		wantMaxResults int
+		wantStatusCode int
+	}{
+		{
+			name:           "single result",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "no results available",
+			serviceError:   service.ErrNoTasksAvailable,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "batch",
+			query:          "?max=10",
+			wantMaxResults: 10,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "non-numeric max",
+			query:          "?max=all",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "max out of range",
+			query:          "?max=1000",
+			serviceError:   service.ErrInvalidBatchSize,
+			wantMaxResults: 1000,
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotMaxResults int
+			leased := []*dto.LeasedResult{
+				{Result: dto.Result{ID: 1, TaskID: 10, Status: "completed"}, Receipt: "receipt-1", LeaseExpiresAt: time.Now().Add(time.Minute)},
+				{Result: dto.Result{ID: 2, TaskID: 11, Status: "completed"}, Receipt: "receipt-2", LeaseExpiresAt: time.Now().Add(time.Minute)},
+			}
+			mockService := &MockTaskService{
+				ConsumeResultFunc: func(userID uint) (*dto.LeasedResult, error) {
+					if tt.serviceError != nil {
+						return nil, tt.serviceError
+					}
+					return leased[0], nil
+				},
+				ConsumeResultsFunc: func(userID uint, maxResults int) ([]*dto.LeasedResult, error) {
+					gotMaxResults = maxResults
+					if tt.serviceError != nil {
+						return nil, tt.serviceError
+					}
+					return leased, nil
+				},
+			}
+
+			handler := NewTaskHandler(mockService)
+
+			router := gin.New()
+			router.GET("/results", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.ConsumeResult(c)
+			})
+
+			req, _ := http.NewRequest("GET", "/results"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantMaxResults, gotMaxResults)
+
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+			if tt.query == "" {
+				var resp struct {
+					Data response.ConsumeResultResponse `json:"data"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, uint(1), resp.Data.Result.ID)
+				assert.Equal(t, "receipt-1", resp.Data.Receipt)
+			} else {
+				var resp struct {
+					Data response.ConsumeResultsResponse `json:"data"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Len(t, resp.Data.Results, 2)
+				assert.Equal(t, "receipt-2", resp.Data.Results[1].Receipt)
+			}
+		})
+	}
+}
+
+func TestTaskHandler_AckResult(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		resultID       string
+		body           string
+		serviceError   error
+		wantStatusCode int
+		wantMessage    string
+	}{
+		{
+			name:           "success",
+			resultID:       "7",
+			body:           `{"receipt":"receipt"}`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "missing receipt",
+			resultID:       "7",
+			body:           `{}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid result id",
+			resultID:       "abc",
+			body:           `{"receipt":"receipt"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantMessage:    "Invalid result ID",
+		},
+		{
+			name:           "result not found",
+			resultID:       "7",
+			body:           `{"receipt":"receipt"}`,
+			serviceError:   service.ErrResultNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "another user's result",
+			resultID:       "7",
+			body:           `{"receipt":"receipt"}`,
+			serviceError:   service.ErrResultAccessDenied,
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name:           "superseded receipt",
+			resultID:       "7",
+			body:           `{"receipt":"receipt"}`,
+			serviceError:   service.ErrResultNotLeased,
+			wantStatusCode: http.StatusConflict,
+			wantMessage:    "Receipt has expired or been superseded by a newer delivery",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockTaskService{
+				AckResultFunc: func(resultID uint, userID uint, receipt string) error {
+					assert.Equal(t, uint(7), resultID)
+					assert.Equal(t, uint(1), userID)
+					assert.Equal(t, "receipt", receipt)
+					return tt.serviceError
+				},
+			}
+			handler := NewTaskHandler(mockService)
+
+			router := gin.New()
+			router.POST("/results/:id/ack", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.AckResult(c)
+			})
+
+			req, _ := http.NewRequest("POST", "/results/"+tt.resultID+"/ack", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+
+			var resp response.Response
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			if tt.wantMessage != "" {
+				assert.Equal(t, tt.wantMessage, resp.Error.Message)
+			}
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Nil(t, resp.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rainchanel.com/internal/api/request"
+	"rainchanel.com/internal/api/response"
+	"rainchanel.com/internal/service"
+)
+
+type WorkflowHandler interface {
+	CreateWorkflow(*gin.Context)
+	GetWorkflow(*gin.Context)
+}
+
+type workflowHandler struct {
+	workflowService service.WorkflowService
+}
+
+func NewWorkflowHandler(workflowService service.WorkflowService) WorkflowHandler {
+	return &workflowHandler{
+		workflowService: workflowService,
+	}
+}
+
+func (h *workflowHandler) CreateWorkflow(ctx *gin.Context) {
+	var createWorkflowRequest request.CreateWorkflowRequest
+
+	if err := ctx.ShouldBindJSON(&createWorkflowRequest); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	workflow, err := h.workflowService.CreateWorkflow(createWorkflowRequest.Tasks, userID.(uint))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWorkflow) ||
+			errors.Is(err, service.ErrInvalidQueue) ||
+			errors.Is(err, service.ErrInvalidPriority) ||
+			errors.Is(err, service.ErrInvalidRetryPolicy) ||
+			errors.Is(err, service.ErrInvalidTimeout) ||
+			errors.Is(err, service.ErrInvalidDeadline) ||
+			errors.Is(err, service.ErrInvalidTTL) ||
+			errors.Is(err, service.ErrInvalidResultReference) {
+			ctx.JSON(http.StatusBadRequest, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.WorkflowResponse{
+			Workflow: *workflow,
+		},
+	})
+}
+
+func (h *workflowHandler) GetWorkflow(ctx *gin.Context) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusUnauthorized,
+				Message: "User not authenticated",
+			},
+		})
+		return
+	}
+
+	workflowID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid workflow ID",
+			},
+		})
+		return
+	}
+
+	workflow, err := h.workflowService.GetWorkflow(uint(workflowID), userID.(uint))
+	if err != nil {
+		if errors.Is(err, service.ErrWorkflowNotFound) {
+			ctx.JSON(http.StatusNotFound, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusNotFound,
+					Message: "Workflow not found",
+				},
+			})
+			return
+		}
+		if errors.Is(err, service.ErrWorkflowAccessDenied) {
+			ctx.JSON(http.StatusForbidden, response.Response{
+				Error: &response.Error{
+					Code:    http.StatusForbidden,
+					Message: "Access denied: workflow does not belong to user",
+				},
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, response.Response{
+			Error: &response.Error{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.Response{
+		Data: response.WorkflowResponse{
+			Workflow: *workflow,
+		},
+	})
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/service"
+)
+
+type MockWorkflowService struct {
+	CreateWorkflowFunc func(tasks []dto.WorkflowTask, createdBy uint) (*dto.Workflow, error)
+	GetWorkflowFunc    func(workflowID uint, userID uint) (*dto.Workflow, error)
+}
+
+func (m *MockWorkflowService) CreateWorkflow(tasks []dto.WorkflowTask, createdBy uint) (*dto.Workflow, error) {
+	if m.CreateWorkflowFunc != nil {
+		return m.CreateWorkflowFunc(tasks, createdBy)
+	}
+	return nil, nil
+}
+
+func (m *MockWorkflowService) GetWorkflow(workflowID uint, userID uint) (*dto.Workflow, error) {
+	if m.GetWorkflowFunc != nil {
+		return m.GetWorkflowFunc(workflowID, userID)
+	}
+	return nil, nil
+}
+
+func TestWorkflowHandler_CreateWorkflow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	validBody := map[string]any{
+		"tasks": []map[string]any{
+			{
+				"key":  "fetch",
+				"task": map[string]any{"wasm_module": "base64-module", "func": "fetch"},
+			},
+			{
+				"key":        "report",
+				"task":       map[string]any{"wasm_module": "base64-module", "func": "report"},
+				"depends_on": []string{"fetch"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		requestBody    any
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name:           "success",
+			requestBody:    validBody,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "no tasks",
+			requestBody:    map[string]any{"tasks": []any{}},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "task without key",
+			requestBody: map[string]any{
+				"tasks": []map[string]any{
+					{"task": map[string]any{"wasm_module": "base64-module", "func": "fetch"}},
+				},
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "dependency cycle",
+			requestBody:    validBody,
+			serviceError:   service.ErrInvalidWorkflow,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "service error",
+			requestBody:    validBody,
+			serviceError:   errors.New("database error"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockWorkflowService{
+				CreateWorkflowFunc: func(tasks []dto.WorkflowTask, createdBy uint) (*dto.Workflow, error) {
+					if tt.serviceError != nil {
+						return nil, tt.serviceError
+					}
+					assert.Equal(t, []string{"fetch"}, tasks[1].DependsOn)
+					return &dto.Workflow{ID: 1, Status: service.JobStatusRunning}, nil
+				},
+			}
+			handler := NewWorkflowHandler(mockService)
+
+			router := gin.New()
+			router.POST("/workflows", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.CreateWorkflow(c)
+			})
+
+			body, _ := json.Marshal(tt.requestBody)
+			req, _ := http.NewRequest("POST", "/workflows", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
+
+func TestWorkflowHandler_GetWorkflow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		workflowID     string
+		serviceError   error
+		wantStatusCode int
+	}{
+		{
+			name:           "success",
+			workflowID:     "3",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid id",
+			workflowID:     "abc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "not found",
+			workflowID:     "3",
+			serviceError:   service.ErrWorkflowNotFound,
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "access denied",
+			workflowID:     "3",
+			serviceError:   service.ErrWorkflowAccessDenied,
+			wantStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockWorkflowService{
+				GetWorkflowFunc: func(workflowID uint, userID uint) (*dto.Workflow, error) {
+					if tt.serviceError != nil {
+						return nil, tt.serviceError
+					}
+					return &dto.Workflow{ID: workflowID, Status: service.JobStatusCompleted}, nil
+				},
+			}
+			handler := NewWorkflowHandler(mockService)
+
+			router := gin.New()
+			router.GET("/workflows/:id", func(c *gin.Context) {
+				c.Set("user_id", uint(1))
+				handler.GetWorkflow(c)
+			})
+
+			req, _ := http.NewRequest("GET", "/workflows/"+tt.workflowID, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package request
+
+import "rainchanel.com/internal/dto"
+
+type RequeueDeadLettersRequest struct {
+	dto.DeadLetterFilter
+}
@@ -0,0 +1,7 @@
+package request
+
+import "rainchanel.com/internal/dto"
+
+type CreateMapJobRequest struct {
+	Job dto.MapJob `json:"job" binding:"required"`
+}
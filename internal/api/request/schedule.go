@@ -0,0 +1,7 @@
+package request
+
+import "rainchanel.com/internal/dto"
+
+type CreateScheduleRequest struct {
+	Schedule dto.Schedule `json:"schedule" binding:"required"`
+}
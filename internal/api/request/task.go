@@ -1,19 +1,46 @@
 package request
 
-import "rainchanel.com/internal/dto"
+import (
+	"time"
+
+	"rainchanel.com/internal/dto"
+)
 
 type PublishTaskRequest struct {
-	Task dto.Task `json:"task" binding:"required"`
+	Task           dto.Task   `json:"task" binding:"required"`
+	RunAt          *time.Time `json:"run_at,omitempty"`
+	DelaySeconds   int        `json:"delay_seconds,omitempty" binding:"min=0"`
+	IdempotencyKey string     `json:"idempotency_key,omitempty" binding:"max=255"`
 }
 
 type PublishResultRequest struct {
-	TaskID    uint   `json:"task_id" binding:"required"`
-	Result    any    `json:"result" binding:"required"`
-	CreatedBy uint   `json:"created_by" binding:"required"`
+	TaskID     uint   `json:"task_id" binding:"required"`
+	ClaimToken string `json:"claim_token" binding:"required"`
+	Result     any    `json:"result" binding:"required"`
+	CreatedBy  uint   `json:"created_by" binding:"required"`
 }
 
 type PublishFailureRequest struct {
-	TaskID    uint   `json:"task_id" binding:"required"`
-	ErrorMsg  string `json:"error_msg" binding:"required"`
-	CreatedBy uint   `json:"created_by" binding:"required"`
+	TaskID     uint   `json:"task_id" binding:"required"`
+	ClaimToken string `json:"claim_token" binding:"required"`
+	Code       string `json:"code,omitempty" binding:"max=100"`
+	ErrorMsg   string `json:"error_msg" binding:"required"`
+	Retryable  *bool  `json:"retryable,omitempty"`
+	TrapKind   string `json:"trap_kind,omitempty" binding:"max=100"`
+	Details    string `json:"details,omitempty" binding:"max=65535"`
+	CreatedBy  uint   `json:"created_by" binding:"required"`
+}
+
+type HeartbeatRequest struct {
+	ClaimToken string `json:"claim_token" binding:"required"`
+}
+
+type ReleaseTaskRequest struct {
+	ClaimToken   string `json:"claim_token" binding:"required"`
+	DelaySeconds int    `json:"delay_seconds,omitempty" binding:"min=0"`
+	Reason       string `json:"reason,omitempty" binding:"max=255"`
+}
+
+type AckResultRequest struct {
+	Receipt string `json:"receipt" binding:"required"`
 }
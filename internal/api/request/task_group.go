@@ -0,0 +1,9 @@
+package request
+
+import "rainchanel.com/internal/dto"
+
+type CreateTaskGroupRequest struct {
+	Tasks     []dto.Task          `json:"tasks" binding:"required,min=1"`
+	Reduce    dto.TaskGroupReduce `json:"reduce" binding:"required"`
+	OnFailure string              `json:"on_failure,omitempty"`
+}
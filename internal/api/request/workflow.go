@@ -0,0 +1,7 @@
+package request
+
+import "rainchanel.com/internal/dto"
+
+type CreateWorkflowRequest struct {
+	Tasks []dto.WorkflowTask `json:"tasks" binding:"required,min=1,dive"`
+}
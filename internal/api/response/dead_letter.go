@@ -0,0 +1,18 @@
+package response
+
+import "rainchanel.com/internal/dto"
+
+type DeadLettersResponse struct {
+	DeadLetters []dto.DeadLetter `json:"dead_letters"`
+	Total       int64            `json:"total"`
+	Limit       int              `json:"limit"`
+	Offset      int              `json:"offset"`
+}
+
+type RequeueDeadLettersResponse struct {
+	Requeued int `json:"requeued"`
+}
+
+type PurgeDeadLettersResponse struct {
+	Purged int64 `json:"purged"`
+}
@@ -0,0 +1,13 @@
+package response
+
+import "rainchanel.com/internal/dto"
+
+type MapJobResponse struct {
+	Job     dto.MapJob `json:"job"`
+	TaskIDs []uint     `json:"task_ids,omitempty"`
+}
+
+type MapJobResultsResponse struct {
+	JobID   uint               `json:"job_id"`
+	Results []dto.MapJobResult `json:"results"`
+}
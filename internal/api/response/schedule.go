@@ -0,0 +1,15 @@
+package response
+
+import "rainchanel.com/internal/dto"
+
+type CreateScheduleResponse struct {
+	Schedule dto.Schedule `json:"schedule"`
+}
+
+type ListSchedulesResponse struct {
+	Schedules []dto.Schedule `json:"schedules"`
+}
+
+type ScheduleActionResponse struct {
+	Message string `json:"message"`
+}
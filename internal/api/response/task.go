@@ -1,13 +1,33 @@
 package response
 
-import "rainchanel.com/internal/dto"
+import (
+	"time"
+
+	"rainchanel.com/internal/dto"
+)
 
 type PublishTaskResponse struct {
 	TaskID uint `json:"task_id"`
 }
 
 type ConsumeTaskResponse struct {
-	Task dto.Task `json:"task"`
+	Task           dto.Task  `json:"task"`
+	ClaimToken     string    `json:"claim_token"`
+	LeaseExpiresAt time.Time `json:"lease_expires_at"`
+}
+
+type ConsumeTasksResponse struct {
+	Tasks []ConsumeTaskResponse `json:"tasks"`
+}
+
+type HeartbeatResponse struct {
+	TaskID         uint      `json:"task_id"`
+	LeaseExpiresAt time.Time `json:"lease_expires_at"`
+}
+
+type ReleaseTaskResponse struct {
+	TaskID      uint      `json:"task_id"`
+	AvailableAt time.Time `json:"available_at"`
 }
 
 type PublishResultResponse struct {
@@ -15,5 +35,11 @@ type PublishResultResponse struct {
 }
 
 type ConsumeResultResponse struct {
-	Result dto.Result `json:"result"`
+	Result         dto.Result `json:"result"`
+	Receipt        string     `json:"receipt"`
+	LeaseExpiresAt time.Time  `json:"lease_expires_at"`
+}
+
+type ConsumeResultsResponse struct {
+	Results []ConsumeResultResponse `json:"results"`
 }
@@ -0,0 +1,7 @@
+package response
+
+import "rainchanel.com/internal/dto"
+
+type TaskGroupResponse struct {
+	Group dto.TaskGroup `json:"group"`
+}
@@ -0,0 +1,7 @@
+package response
+
+import "rainchanel.com/internal/dto"
+
+type WorkflowResponse struct {
+	Workflow dto.Workflow `json:"workflow"`
+}
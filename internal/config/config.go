@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,6 +15,7 @@ type Config struct {
 	Database DatabaseConfig `yaml:"database"`
 	JWT      JWTConfig      `yaml:"jwt"`
 	Task     TaskConfig     `yaml:"task"`
+	Schedule ScheduleConfig `yaml:"schedule"`
 }
 
 type ServerConfig struct {
@@ -33,9 +35,24 @@ type JWTConfig struct {
 }
 
 type TaskConfig struct {
-	TimeoutSeconds            int `yaml:"timeout_seconds"`
-	MaxRetries                int `yaml:"max_retries"`
-	StaleCheckIntervalSeconds int `yaml:"stale_check_interval_seconds"`
+	TimeoutSeconds            int            `yaml:"timeout_seconds"`
+	MaxRetries                int            `yaml:"max_retries"`
+	StaleCheckIntervalSeconds int            `yaml:"stale_check_interval_seconds"`
+	RetryBackoffBaseSeconds   int            `yaml:"retry_backoff_base_seconds"`
+	RetryBackoffMaxSeconds    int            `yaml:"retry_backoff_max_seconds"`
+	RetryBackoffJitter        float64        `yaml:"retry_backoff_jitter"`
+	PriorityAgingSeconds      int            `yaml:"priority_aging_seconds"`
+	IdempotencyWindowSeconds  int            `yaml:"idempotency_window_seconds"`
+	MaxRetriesLimit           int            `yaml:"max_retries_limit"`
+	RetryBackoffLimitSeconds  int            `yaml:"retry_backoff_limit_seconds"`
+	MaxTimeoutSeconds         int            `yaml:"max_timeout_seconds"`
+	QueueTTLSeconds           map[string]int `yaml:"queue_ttl_seconds"`
+	ResultLeaseSeconds        int            `yaml:"result_lease_seconds"`
+}
+
+type ScheduleConfig struct {
+	CheckIntervalSeconds int `yaml:"check_interval_seconds"`
+	MisfireGraceSeconds  int `yaml:"misfire_grace_seconds"`
 }
 
 var (
@@ -62,6 +79,19 @@ func Load() error {
 			TimeoutSeconds:            300,
 			MaxRetries:                3,
 			StaleCheckIntervalSeconds: 30,
+			RetryBackoffBaseSeconds:   1,
+			RetryBackoffMaxSeconds:    300,
+			RetryBackoffJitter:        0.2,
+			PriorityAgingSeconds:      60,
+			IdempotencyWindowSeconds:  86400,
+			MaxRetriesLimit:           20,
+			RetryBackoffLimitSeconds:  3600,
+			MaxTimeoutSeconds:         86400,
+			ResultLeaseSeconds:        60,
+		},
+		Schedule: ScheduleConfig{
+			CheckIntervalSeconds: 10,
+			MisfireGraceSeconds:  60,
 		},
 	}
 
@@ -76,6 +106,12 @@ func Load() error {
 
 	loadFromEnv()
 
+	// A result lease of zero or less would hand every result out again on
+	// the next poll, before the producer had a chance to ack it.
+	if App.Task.ResultLeaseSeconds <= 0 {
+		return fmt.Errorf("result_lease_seconds must be positive, got %d", App.Task.ResultLeaseSeconds)
+	}
+
 	return nil
 }
 
@@ -132,4 +168,73 @@ func loadFromEnv() {
 			App.Task.StaleCheckIntervalSeconds = staleCheck
 		}
 	}
+	if baseStr := os.Getenv("TASK_RETRY_BACKOFF_BASE_SECONDS"); baseStr != "" {
+		if base, err := strconv.Atoi(baseStr); err == nil {
+			App.Task.RetryBackoffBaseSeconds = base
+		}
+	}
+	if maxStr := os.Getenv("TASK_RETRY_BACKOFF_MAX_SECONDS"); maxStr != "" {
+		if maxSeconds, err := strconv.Atoi(maxStr); err == nil {
+			App.Task.RetryBackoffMaxSeconds = maxSeconds
+		}
+	}
+	if jitterStr := os.Getenv("TASK_RETRY_BACKOFF_JITTER"); jitterStr != "" {
+		if jitter, err := strconv.ParseFloat(jitterStr, 64); err == nil {
+			App.Task.RetryBackoffJitter = jitter
+		}
+	}
+	if agingStr := os.Getenv("TASK_PRIORITY_AGING_SECONDS"); agingStr != "" {
+		if aging, err := strconv.Atoi(agingStr); err == nil {
+			App.Task.PriorityAgingSeconds = aging
+		}
+	}
+	if windowStr := os.Getenv("TASK_IDEMPOTENCY_WINDOW_SECONDS"); windowStr != "" {
+		if window, err := strconv.Atoi(windowStr); err == nil {
+			App.Task.IdempotencyWindowSeconds = window
+		}
+	}
+	if limitStr := os.Getenv("TASK_MAX_RETRIES_LIMIT"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil {
+			App.Task.MaxRetriesLimit = limit
+		}
+	}
+	if limitStr := os.Getenv("TASK_RETRY_BACKOFF_LIMIT_SECONDS"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil {
+			App.Task.RetryBackoffLimitSeconds = limit
+		}
+	}
+	if maxTimeoutStr := os.Getenv("TASK_MAX_TIMEOUT_SECONDS"); maxTimeoutStr != "" {
+		if maxTimeout, err := strconv.Atoi(maxTimeoutStr); err == nil {
+			App.Task.MaxTimeoutSeconds = maxTimeout
+		}
+	}
+	if queueTTLStr := os.Getenv("TASK_QUEUE_TTL_SECONDS"); queueTTLStr != "" {
+		queueTTLs := make(map[string]int)
+		for _, entry := range strings.Split(queueTTLStr, ",") {
+			queue, ttlStr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				continue
+			}
+			if ttl, err := strconv.Atoi(ttlStr); err == nil {
+				queueTTLs[queue] = ttl
+			}
+		}
+		App.Task.QueueTTLSeconds = queueTTLs
+	}
+	if leaseStr := os.Getenv("TASK_RESULT_LEASE_SECONDS"); leaseStr != "" {
+		if lease, err := strconv.Atoi(leaseStr); err == nil {
+			App.Task.ResultLeaseSeconds = lease
+		}
+	}
+
+	if intervalStr := os.Getenv("SCHEDULE_CHECK_INTERVAL_SECONDS"); intervalStr != "" {
+		if interval, err := strconv.Atoi(intervalStr); err == nil {
+			App.Schedule.CheckIntervalSeconds = interval
+		}
+	}
+	if graceStr := os.Getenv("SCHEDULE_MISFIRE_GRACE_SECONDS"); graceStr != "" {
+		if grace, err := strconv.Atoi(graceStr); err == nil {
+			App.Schedule.MisfireGraceSeconds = grace
+		}
+	}
 }
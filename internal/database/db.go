@@ -22,7 +22,9 @@ func Init(config config.DatabaseConfig) error {
 	)
 
 	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
+		TranslateError: true,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -37,7 +39,11 @@ func Init(config config.DatabaseConfig) error {
 	sqlDB.SetConnMaxLifetime(time.Hour)
 	sqlDB.SetConnMaxIdleTime(10 * time.Minute)
 
-	if err := DB.AutoMigrate(&User{}, &Task{}, &TaskAudit{}, &Result{}); err != nil {
+	if err := migrateResultTaskIndex(DB); err != nil {
+		return fmt.Errorf("failed to make results.task_id unique: %w", err)
+	}
+
+	if err := DB.AutoMigrate(&User{}, &Task{}, &TaskAudit{}, &Result{}, &Schedule{}, &IdempotencyKey{}, &Workflow{}, &TaskDependency{}, &MapJob{}, &TaskGroup{}, &TaskFailure{}); err != nil {
 		return fmt.Errorf("failed to auto-migrate database: %w", err)
 	}
 
@@ -55,3 +61,53 @@ func Close() error {
 	}
 	return sqlDB.Close()
 }
+
+// duplicateResultsTable holds the results that migrateResultTaskIndex moved
+// out of the way, so nothing is lost when a task had more than one result.
+const duplicateResultsTable = "results_duplicates"
+
+// migrateResultTaskIndex replaces the plain idx_task_id index on
+// results.task_id with the unique idx_results_task_id on databases that
+// predate it. Every result but the first of a task is moved to
+// duplicateResultsTable first. The old index is only dropped once the new one
+// exists, because the results.task_id foreign key needs one of them.
+func migrateResultTaskIndex(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&Result{}) || migrator.HasIndex(&Result{}, "idx_results_task_id") {
+		return nil
+	}
+
+	var taskIDs []uint
+	if err := db.Raw("SELECT DISTINCT r1.task_id FROM results r1 JOIN results r2 ON r1.task_id = r2.task_id AND r1.id > r2.id").
+		Scan(&taskIDs).Error; err != nil {
+		return fmt.Errorf("failed to find duplicate results: %w", err)
+	}
+	if len(taskIDs) > 0 {
+		if err := db.Exec("CREATE TABLE IF NOT EXISTS " + duplicateResultsTable + " LIKE results").Error; err != nil {
+			return fmt.Errorf("failed to create %s: %w", duplicateResultsTable, err)
+		}
+		var moved int64
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("INSERT INTO " + duplicateResultsTable + " SELECT r1.* FROM results r1 JOIN results r2 ON r1.task_id = r2.task_id AND r1.id > r2.id").Error; err != nil {
+				return err
+			}
+			result := tx.Exec("DELETE r1 FROM results r1 JOIN results r2 ON r1.task_id = r2.task_id AND r1.id > r2.id")
+			moved = result.RowsAffected
+			return result.Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to move duplicate results: %w", err)
+		}
+		log.Printf("Moved %d duplicate results of tasks %v to %s", moved, taskIDs, duplicateResultsTable)
+	}
+
+	if err := migrator.CreateIndex(&Result{}, "idx_results_task_id"); err != nil {
+		return fmt.Errorf("failed to create unique index: %w", err)
+	}
+	if migrator.HasIndex(&Result{}, "idx_task_id") {
+		if err := migrator.DropIndex(&Result{}, "idx_task_id"); err != nil {
+			return fmt.Errorf("failed to drop old index: %w", err)
+		}
+	}
+	return nil
+}
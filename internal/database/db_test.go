@@ -129,3 +129,63 @@ func TestClose_WithoutInit(t *testing.T) {
 		t.Errorf("Close() should not error when DB is nil, got %v", err)
 	}
 }
+
+func TestMigrateResultTaskIndex(t *testing.T) {
+	config := getTestDBConfig()
+	if err := Init(config); err != nil {
+		t.Skipf("Skipping test - could not connect to MySQL: %v", err)
+	}
+	defer Close()
+
+	// Put results back the way older databases have them: a plain index on
+	// task_id backing the foreign key, and no unique index.
+	migrator := DB.Migrator()
+	if err := DB.Exec("CREATE INDEX idx_task_id ON results (task_id)").Error; err != nil {
+		t.Fatalf("Failed to create old index: %v", err)
+	}
+	if err := migrator.DropIndex(&Result{}, "idx_results_task_id"); err != nil {
+		t.Fatalf("Failed to drop unique index: %v", err)
+	}
+	defer DB.Exec("DROP TABLE IF EXISTS " + duplicateResultsTable)
+
+	user := User{Username: "migrate-results", Password: "hashedpassword"}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	defer DB.Delete(&user)
+	task := Task{WasmModule: "module", Func: "func", CreatedBy: user.ID}
+	if err := DB.Create(&task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	defer DB.Delete(&task)
+	for _, value := range []string{"1", "2"} {
+		if err := DB.Create(&Result{TaskID: task.ID, CreatedBy: user.ID, Result: value}).Error; err != nil {
+			t.Fatalf("Failed to create result: %v", err)
+		}
+	}
+
+	if err := migrateResultTaskIndex(DB); err != nil {
+		t.Fatalf("migrateResultTaskIndex() error = %v", err)
+	}
+
+	if !migrator.HasIndex(&Result{}, "idx_results_task_id") {
+		t.Error("unique index should have been created")
+	}
+	if migrator.HasIndex(&Result{}, "idx_task_id") {
+		t.Error("old index should have been dropped")
+	}
+	var kept []Result
+	if err := DB.Where("task_id = ?", task.ID).Find(&kept).Error; err != nil {
+		t.Fatalf("Failed to find results: %v", err)
+	}
+	if len(kept) != 1 || kept[0].Result != "1" {
+		t.Errorf("expected only the first result to be kept, got %+v", kept)
+	}
+	var moved int64
+	if err := DB.Table(duplicateResultsTable).Where("task_id = ?", task.ID).Count(&moved).Error; err != nil {
+		t.Fatalf("Failed to count moved results: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("expected 1 moved result, got %d", moved)
+	}
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"slices"
 	"time"
 )
 
@@ -13,15 +14,48 @@ type User struct {
 }
 
 type Task struct {
-	ID         uint      `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
-	WasmModule string    `gorm:"type:text;not null" json:"wasm_module"`
-	Func       string    `gorm:"type:varchar(255);not null" json:"func"`
-	Args       string    `gorm:"type:text" json:"args"`
-	CreatedBy  uint      `gorm:"type:bigint unsigned;not null;index" json:"created_by"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID             uint        `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
+	WasmModule     string      `gorm:"type:text;not null" json:"wasm_module"`
+	Func           string      `gorm:"type:varchar(255);not null" json:"func"`
+	Args           string      `gorm:"type:text" json:"args"`
+	Queue          string      `gorm:"type:varchar(100);not null;default:'default'" json:"queue"`
+	Priority       int         `gorm:"type:int;not null;default:0" json:"priority"`
+	WorkflowID     *uint       `gorm:"type:bigint unsigned;index" json:"workflow_id,omitempty"`
+	WorkflowKey    string      `gorm:"type:varchar(100)" json:"workflow_key,omitempty"`
+	MapJobID       *uint       `gorm:"type:bigint unsigned;index:idx_map_job_index" json:"map_job_id,omitempty"`
+	MapIndex       int         `gorm:"type:int;not null;default:0;index:idx_map_job_index" json:"map_index,omitempty"`
+	TaskGroupID    *uint       `gorm:"type:bigint unsigned;index" json:"task_group_id,omitempty"`
+	ReducesGroupID *uint       `gorm:"type:bigint unsigned;index" json:"reduces_group_id,omitempty"`
+	Retry          RetryPolicy `gorm:"embedded;embeddedPrefix:retry_" json:"retry"`
+	CreatedBy      uint        `gorm:"type:bigint unsigned;not null;index" json:"created_by"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
 
-	Creator User `gorm:"foreignKey:CreatedBy;references:ID;constraint:OnDelete:RESTRICT;OnUpdate:CASCADE" json:"creator,omitempty"`
+	Creator      User       `gorm:"foreignKey:CreatedBy;references:ID;constraint:OnDelete:RESTRICT;OnUpdate:CASCADE" json:"creator,omitempty"`
+	Workflow     *Workflow  `gorm:"foreignKey:WorkflowID;references:ID;constraint:OnDelete:SET NULL;OnUpdate:CASCADE" json:"-"`
+	MapJob       *MapJob    `gorm:"foreignKey:MapJobID;references:ID;constraint:OnDelete:CASCADE;OnUpdate:CASCADE" json:"-"`
+	TaskGroup    *TaskGroup `gorm:"foreignKey:TaskGroupID;references:ID;constraint:OnDelete:SET NULL;OnUpdate:CASCADE" json:"-"`
+	ReducesGroup *TaskGroup `gorm:"foreignKey:ReducesGroupID;references:ID;constraint:OnDelete:SET NULL;OnUpdate:CASCADE" json:"-"`
+}
+
+const DefaultQueue = "default"
+
+type RetryBackoff string
+
+const (
+	RetryBackoffFixed       RetryBackoff = "fixed"
+	RetryBackoffLinear      RetryBackoff = "linear"
+	RetryBackoffExponential RetryBackoff = "exponential"
+)
+
+// RetryPolicy holds a task's own retry settings. Nil and empty fields fall
+// back to the server config. NonRetryableCodes is a JSON array of strings.
+type RetryPolicy struct {
+	MaxRetries        *int         `gorm:"type:int" json:"max_retries,omitempty"`
+	Backoff           RetryBackoff `gorm:"type:varchar(20)" json:"backoff,omitempty"`
+	BaseSeconds       *int         `gorm:"type:int" json:"base_seconds,omitempty"`
+	MaxSeconds        *int         `gorm:"type:int" json:"max_seconds,omitempty"`
+	NonRetryableCodes string       `gorm:"type:text" json:"non_retryable_codes,omitempty"`
 }
 
 type TaskStatus string
@@ -31,20 +65,63 @@ const (
 	TaskStatusProcessing TaskStatus = "processing"
 	TaskStatusCompleted  TaskStatus = "completed"
 	TaskStatusFailed     TaskStatus = "failed"
+	TaskStatusCancelled  TaskStatus = "cancelled"
+	TaskStatusBlocked    TaskStatus = "blocked"
+
+	// TaskStatusDeadlineExceeded is final, like failed, but is never retried
+	// or requeued.
+	TaskStatusDeadlineExceeded TaskStatus = "deadline_exceeded"
+
+	// TaskStatusExpired is final: the task stayed pending past its TTL
+	// without being claimed.
+	TaskStatusExpired TaskStatus = "expired"
+
+	// TaskStatusScheduled is never stored; it selects pending tasks whose
+	// run_at, as requested at publish time, is still in the future.
+	TaskStatusScheduled TaskStatus = "scheduled"
 )
 
+// taskTransitions is the task status state machine: the statuses a task may
+// move to from each status. Completed, cancelled, deadline_exceeded and
+// expired are final.
+var taskTransitions = map[TaskStatus][]TaskStatus{
+	TaskStatusPending:    {TaskStatusProcessing, TaskStatusCancelled, TaskStatusDeadlineExceeded, TaskStatusExpired},
+	TaskStatusBlocked:    {TaskStatusPending, TaskStatusFailed, TaskStatusCancelled, TaskStatusDeadlineExceeded},
+	TaskStatusProcessing: {TaskStatusCompleted, TaskStatusFailed, TaskStatusPending, TaskStatusCancelled, TaskStatusDeadlineExceeded},
+	// Dead letters can be requeued.
+	TaskStatusFailed: {TaskStatusPending, TaskStatusBlocked},
+}
+
+// CanTransition reports whether the state machine allows a task to move from
+// one status to another.
+func CanTransition(from, to TaskStatus) bool {
+	return slices.Contains(taskTransitions[from], to)
+}
+
 type TaskAudit struct {
-	ID          uint       `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
-	TaskID      uint       `gorm:"type:bigint unsigned;not null;uniqueIndex" json:"task_id"`
-	Status      TaskStatus `gorm:"type:varchar(50);default:'pending';not null;index:idx_status_published" json:"status"`
-	ProcessedBy *uint      `gorm:"type:bigint unsigned;index:idx_task_processed_by" json:"processed_by,omitempty"`
-	RetryCount  int        `gorm:"type:int;default:0;not null" json:"retry_count"`
-	ErrorMsg    string     `gorm:"type:text" json:"error_msg,omitempty"`
-	PublishedAt time.Time  `gorm:"type:datetime;not null;index:idx_status_published" json:"published_at"`
-	ConsumedAt  *time.Time `gorm:"type:datetime" json:"consumed_at,omitempty"`
-	CompletedAt *time.Time `gorm:"type:datetime" json:"completed_at,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID             uint       `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
+	TaskID         uint       `gorm:"type:bigint unsigned;not null;uniqueIndex" json:"task_id"`
+	Queue          string     `gorm:"type:varchar(100);not null;default:'default';index:idx_queue_status" json:"queue"`
+	Status         TaskStatus `gorm:"type:varchar(50);default:'pending';not null;index:idx_status_published;index:idx_status_effective;index:idx_queue_status;index:idx_status_lease" json:"status"`
+	ProcessedBy    *uint      `gorm:"type:bigint unsigned;index:idx_task_processed_by" json:"processed_by,omitempty"`
+	RetryCount     int        `gorm:"type:int;default:0;not null" json:"retry_count"`
+	ErrorCode      string     `gorm:"type:varchar(100);index" json:"error_code,omitempty"`
+	ErrorMsg       string     `gorm:"type:text" json:"error_msg,omitempty"`
+	PublishedAt    time.Time  `gorm:"type:datetime;not null;index:idx_status_published" json:"published_at"`
+	EffectiveAt    time.Time  `gorm:"type:datetime;not null;default:CURRENT_TIMESTAMP;index:idx_status_effective;index:idx_queue_status" json:"effective_at"`
+	NextAttemptAt  *time.Time `gorm:"type:datetime;index" json:"next_attempt_at,omitempty"`
+	RunAt          *time.Time `gorm:"type:datetime;index" json:"run_at,omitempty"`
+	ConsumedAt     *time.Time `gorm:"type:datetime" json:"consumed_at,omitempty"`
+	ClaimToken     string     `gorm:"type:varchar(64)" json:"-"`
+	LeaseExpiresAt *time.Time `gorm:"type:datetime;index:idx_status_lease" json:"lease_expires_at,omitempty"`
+	TimeoutSeconds int        `gorm:"type:int;not null;default:0" json:"timeout_seconds,omitempty"`
+	Deadline       *time.Time `gorm:"type:datetime;index" json:"deadline,omitempty"`
+	TTLSeconds     int        `gorm:"type:int;not null;default:0" json:"ttl_seconds,omitempty"`
+	ExpiresAt      *time.Time `gorm:"type:datetime;index" json:"expires_at,omitempty"`
+	ReleaseReason  string     `gorm:"type:varchar(255)" json:"release_reason,omitempty"`
+	CompletedAt    *time.Time `gorm:"type:datetime" json:"completed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 
 	Task   Task `gorm:"foreignKey:TaskID;references:ID;constraint:OnDelete:CASCADE;OnUpdate:CASCADE" json:"task,omitempty"`
 	Worker User `gorm:"foreignKey:ProcessedBy;references:ID;constraint:OnDelete:SET NULL;OnUpdate:CASCADE" json:"worker,omitempty"`
@@ -54,15 +131,146 @@ func (TaskAudit) TableName() string {
 	return "task_audit"
 }
 
-type Result struct {
+// TaskFailure records one failed attempt of a task. The audit row only keeps
+// the latest error, so this is the history shown for dead letters.
+type TaskFailure struct {
+	ID        uint      `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
+	TaskID    uint      `gorm:"type:bigint unsigned;not null;index" json:"task_id"`
+	Attempt   int       `gorm:"type:int;not null" json:"attempt"`
+	Code      string    `gorm:"type:varchar(100)" json:"code,omitempty"`
+	ErrorMsg  string    `gorm:"type:text" json:"error_msg"`
+	Retryable *bool     `gorm:"not null;default:true" json:"retryable"`
+	TrapKind  string    `gorm:"type:varchar(100)" json:"trap_kind,omitempty"`
+	Details   string    `gorm:"type:text" json:"details,omitempty"`
+	WorkerID  *uint     `gorm:"type:bigint unsigned" json:"worker_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Task Task `gorm:"foreignKey:TaskID;references:ID;constraint:OnDelete:CASCADE;OnUpdate:CASCADE" json:"-"`
+}
+
+type Workflow struct {
+	ID        uint      `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
+	CreatedBy uint      `gorm:"type:bigint unsigned;not null;index" json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Creator User `gorm:"foreignKey:CreatedBy;references:ID;constraint:OnDelete:RESTRICT;OnUpdate:CASCADE" json:"creator,omitempty"`
+}
+
+// MapJob holds the module shared by every task of a parameter sweep, so it is
+// stored once instead of on each task.
+type MapJob struct {
+	ID         uint      `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
+	WasmModule string    `gorm:"type:text;not null" json:"wasm_module"`
+	Func       string    `gorm:"type:varchar(255);not null" json:"func"`
+	Queue      string    `gorm:"type:varchar(100);not null;default:'default'" json:"queue"`
+	Priority   int       `gorm:"type:int;not null;default:0" json:"priority"`
+	TaskCount  int       `gorm:"type:int;not null" json:"task_count"`
+	CreatedBy  uint      `gorm:"type:bigint unsigned;not null;index" json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	Creator User `gorm:"foreignKey:CreatedBy;references:ID;constraint:OnDelete:RESTRICT;OnUpdate:CASCADE" json:"creator,omitempty"`
+}
+
+type GroupFailurePolicy string
+
+const (
+	GroupFailurePolicyAbort           GroupFailurePolicy = "abort"
+	GroupFailurePolicyReduceSuccesses GroupFailurePolicy = "reduce_successes"
+	GroupFailurePolicyWaitForRetries  GroupFailurePolicy = "wait_for_retries"
+)
+
+// TaskGroup is a set of tasks published together. Its reduce task is the
+// task whose ReducesGroupID points at the group; it waits for every member
+// and receives their results as arguments.
+type TaskGroup struct {
+	ID        uint               `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
+	OnFailure GroupFailurePolicy `gorm:"type:varchar(20);not null;default:'abort'" json:"on_failure"`
+	TaskCount int                `gorm:"type:int;not null" json:"task_count"`
+	CreatedBy uint               `gorm:"type:bigint unsigned;not null;index" json:"created_by"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+
+	Creator User `gorm:"foreignKey:CreatedBy;references:ID;constraint:OnDelete:RESTRICT;OnUpdate:CASCADE" json:"creator,omitempty"`
+}
+
+type TaskDependency struct {
+	ID              uint `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
+	TaskID          uint `gorm:"type:bigint unsigned;not null;uniqueIndex:idx_task_depends_on" json:"task_id"`
+	DependsOnTaskID uint `gorm:"type:bigint unsigned;not null;uniqueIndex:idx_task_depends_on;index" json:"depends_on_task_id"`
+
+	Task      Task `gorm:"foreignKey:TaskID;references:ID;constraint:OnDelete:CASCADE;OnUpdate:CASCADE" json:"-"`
+	DependsOn Task `gorm:"foreignKey:DependsOnTaskID;references:ID;constraint:OnDelete:CASCADE;OnUpdate:CASCADE" json:"-"`
+}
+
+type ScheduleStatus string
+
+const (
+	ScheduleStatusActive ScheduleStatus = "active"
+	ScheduleStatusPaused ScheduleStatus = "paused"
+)
+
+type MissedTickPolicy string
+
+const (
+	MissedTickPolicySkip    MissedTickPolicy = "skip"
+	MissedTickPolicyCatchUp MissedTickPolicy = "catch_up"
+)
+
+type Schedule struct {
+	ID          uint             `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
+	WasmModule  string           `gorm:"type:text;not null" json:"wasm_module"`
+	Func        string           `gorm:"type:varchar(255);not null" json:"func"`
+	Args        string           `gorm:"type:text" json:"args"`
+	Queue       string           `gorm:"type:varchar(100);not null;default:'default'" json:"queue"`
+	Priority    int              `gorm:"type:int;not null;default:0" json:"priority"`
+	CronExpr    string           `gorm:"type:varchar(255);not null" json:"cron"`
+	MissedTicks MissedTickPolicy `gorm:"type:varchar(20);not null;default:'skip'" json:"missed_ticks"`
+	Status      ScheduleStatus   `gorm:"type:varchar(20);not null;default:'active';index:idx_schedule_status_next" json:"status"`
+	NextRunAt   time.Time        `gorm:"type:datetime;not null;index:idx_schedule_status_next" json:"next_run_at"`
+	LastRunAt   *time.Time       `gorm:"type:datetime" json:"last_run_at,omitempty"`
+	CreatedBy   uint             `gorm:"type:bigint unsigned;not null;index" json:"created_by"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+
+	Creator User `gorm:"foreignKey:CreatedBy;references:ID;constraint:OnDelete:RESTRICT;OnUpdate:CASCADE" json:"creator,omitempty"`
+}
+
+type IdempotencyKey struct {
 	ID          uint      `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
-	TaskID      uint      `gorm:"type:bigint unsigned;not null;index:idx_task_id" json:"task_id"`
-	CreatedBy   uint      `gorm:"type:bigint unsigned;not null;index:idx_created_by_consumed" json:"created_by"`
-	ProcessedBy uint      `gorm:"type:bigint unsigned;not null;index" json:"processed_by"`
-	Result      string    `gorm:"type:text;not null" json:"result"`
-	Consumed    bool      `gorm:"type:boolean;default:false;not null;index:idx_created_by_consumed" json:"consumed"`
+	UserID      uint      `gorm:"type:bigint unsigned;not null;uniqueIndex:idx_user_idempotency_key" json:"user_id"`
+	Key         string    `gorm:"column:idempotency_key;type:varchar(255);not null;uniqueIndex:idx_user_idempotency_key" json:"key"`
+	RequestHash string    `gorm:"type:char(64);not null" json:"request_hash"`
+	TaskID      *uint     `gorm:"type:bigint unsigned" json:"task_id,omitempty"`
+	ExpiresAt   time.Time `gorm:"type:datetime;not null;index" json:"expires_at"`
 	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+
+	User User  `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;OnUpdate:CASCADE" json:"user,omitempty"`
+	Task *Task `gorm:"foreignKey:TaskID;references:ID;constraint:OnDelete:CASCADE;OnUpdate:CASCADE" json:"task,omitempty"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+// Result is handed to the task's producer by GET /results. A task that
+// expires unclaimed gets a result with status expired and no worker.
+// Delivering a result leases it under a new receipt; it is consumed once the
+// producer acks that receipt, and is delivered again if the lease runs out
+// first.
+type Result struct {
+	ID             uint       `gorm:"type:bigint unsigned;primarykey;autoIncrement;not null" json:"id"`
+	TaskID         uint       `gorm:"type:bigint unsigned;not null;uniqueIndex:idx_results_task_id" json:"task_id"`
+	CreatedBy      uint       `gorm:"type:bigint unsigned;not null;index:idx_created_by_consumed" json:"created_by"`
+	ProcessedBy    *uint      `gorm:"type:bigint unsigned;index" json:"processed_by,omitempty"`
+	Status         TaskStatus `gorm:"type:varchar(50);not null;default:'completed'" json:"status"`
+	Result         string     `gorm:"type:text;not null" json:"result"`
+	Consumed       bool       `gorm:"type:boolean;default:false;not null;index:idx_created_by_consumed" json:"consumed"`
+	Receipt        string     `gorm:"type:varchar(64)" json:"-"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 
 	Task      Task `gorm:"foreignKey:TaskID;references:ID;constraint:OnDelete:CASCADE;OnUpdate:CASCADE" json:"task,omitempty"`
 	Creator   User `gorm:"foreignKey:CreatedBy;references:ID;constraint:OnDelete:RESTRICT;OnUpdate:CASCADE" json:"creator,omitempty"`
@@ -0,0 +1,34 @@
+package database
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from TaskStatus
+		to   TaskStatus
+		want bool
+	}{
+		{TaskStatusPending, TaskStatusProcessing, true},
+		{TaskStatusPending, TaskStatusExpired, true},
+		{TaskStatusPending, TaskStatusCompleted, false},
+		{TaskStatusBlocked, TaskStatusPending, true},
+		{TaskStatusBlocked, TaskStatusProcessing, false},
+		{TaskStatusProcessing, TaskStatusCompleted, true},
+		{TaskStatusProcessing, TaskStatusPending, true},
+		{TaskStatusCompleted, TaskStatusFailed, false},
+		{TaskStatusCompleted, TaskStatusPending, false},
+		{TaskStatusFailed, TaskStatusPending, true},
+		{TaskStatusFailed, TaskStatusCompleted, false},
+		{TaskStatusCancelled, TaskStatusPending, false},
+		{TaskStatusExpired, TaskStatusProcessing, false},
+		{TaskStatusDeadlineExceeded, TaskStatusPending, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
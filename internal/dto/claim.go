@@ -0,0 +1,15 @@
+package dto
+
+import "time"
+
+type ClaimedTask struct {
+	Task           Task
+	ClaimToken     string
+	LeaseExpiresAt time.Time
+}
+
+type LeasedResult struct {
+	Result         Result
+	Receipt        string
+	LeaseExpiresAt time.Time
+}
@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+type DeadLetterFilter struct {
+	Func          string `json:"func,omitempty" form:"func"`
+	Queue         string `json:"queue,omitempty" form:"queue"`
+	ErrorCode     string `json:"error_code,omitempty" form:"error_code"`
+	ErrorContains string `json:"error_contains,omitempty" form:"error_contains"`
+}
+
+type TaskFailure struct {
+	Attempt   int       `json:"attempt"`
+	Code      string    `json:"code,omitempty"`
+	ErrorMsg  string    `json:"error_msg"`
+	Retryable bool      `json:"retryable"`
+	TrapKind  string    `json:"trap_kind,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	WorkerID  *uint     `json:"worker_id,omitempty"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+type DeadLetter struct {
+	Task       Task          `json:"task"`
+	ErrorCode  string        `json:"error_code,omitempty"`
+	ErrorMsg   string        `json:"error_msg"`
+	RetryCount int           `json:"retry_count"`
+	FailedAt   time.Time     `json:"failed_at"`
+	Failures   []TaskFailure `json:"failures"`
+}
@@ -0,0 +1,11 @@
+package dto
+
+// Failure is a worker's report of a failed attempt. Retryable defaults to
+// true when it is left out.
+type Failure struct {
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"error_msg"`
+	Retryable *bool  `json:"retryable,omitempty"`
+	TrapKind  string `json:"trap_kind,omitempty"`
+	Details   string `json:"details,omitempty"`
+}
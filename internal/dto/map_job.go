@@ -0,0 +1,29 @@
+package dto
+
+import "time"
+
+type MapJob struct {
+	ID             uint           `json:"id"`
+	WasmModule     string         `json:"wasm_module,omitempty"`
+	Func           string         `json:"func"`
+	Args           []any          `json:"args,omitempty"`
+	Queue          string         `json:"queue,omitempty"`
+	Priority       int            `json:"priority,omitempty"`
+	Retry          *RetryPolicy   `json:"retry,omitempty"`
+	TimeoutSeconds int            `json:"timeout_seconds,omitempty"`
+	Deadline       *time.Time     `json:"deadline,omitempty"`
+	TTLSeconds     int            `json:"ttl_seconds,omitempty"`
+	TaskCount      int            `json:"task_count"`
+	Status         string         `json:"status,omitempty"`
+	Counts         map[string]int `json:"counts,omitempty"`
+	CreatedBy      uint           `json:"created_by,omitempty"`
+	CreatedAt      *time.Time     `json:"created_at,omitempty"`
+}
+
+type MapJobResult struct {
+	Index    int    `json:"index"`
+	TaskID   uint   `json:"task_id"`
+	Status   string `json:"status"`
+	Result   any    `json:"result,omitempty"`
+	ErrorMsg string `json:"error_msg,omitempty"`
+}
@@ -0,0 +1,16 @@
+package dto
+
+import "time"
+
+type PublishOptions struct {
+	RunAt          *time.Time
+	Delay          time.Duration
+	IdempotencyKey string
+	WorkflowID     *uint
+	WorkflowKey    string
+	DependsOn      []uint
+	MapJobID       *uint
+	MapIndex       int
+	TaskGroupID    *uint
+	ReducesGroupID *uint
+}
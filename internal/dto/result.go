@@ -1,8 +1,13 @@
 package dto
 
 type Result struct {
+	ID        uint   `json:"id"`
 	TaskID    uint   `json:"task_id"`
 	CreatedBy uint   `json:"created_by"`
+	Status    string `json:"status"`
 	Result    any    `json:"result"`
+	// DecodeError is set when the stored result is not valid JSON; Result
+	// then holds it as a string, so it can still be acked.
+	DecodeError string `json:"decode_error,omitempty"`
 }
 
@@ -0,0 +1,18 @@
+package dto
+
+import "time"
+
+type Schedule struct {
+	ID          uint       `json:"id"`
+	WasmModule  string     `json:"wasm_module,omitempty"`
+	Func        string     `json:"func"`
+	Args        any        `json:"args"`
+	Queue       string     `json:"queue,omitempty"`
+	Priority    int        `json:"priority,omitempty"`
+	Cron        string     `json:"cron"`
+	MissedTicks string     `json:"missed_ticks,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	NextRunAt   *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt   *time.Time `json:"last_run_at,omitempty"`
+	CreatedBy   uint       `json:"created_by,omitempty"`
+}
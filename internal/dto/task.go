@@ -1,9 +1,27 @@
 package dto
 
+import "time"
+
 type Task struct {
-	ID         uint   `json:"id"`
-	WasmModule string `json:"wasm_module"`
-	Func       string `json:"func"`
-	Args       any    `json:"args"`
-	CreatedBy  uint   `json:"created_by,omitempty"`
+	ID             uint         `json:"id"`
+	WasmModule     string       `json:"wasm_module"`
+	Func           string       `json:"func"`
+	Args           any          `json:"args"`
+	Queue          string       `json:"queue,omitempty"`
+	Priority       int          `json:"priority,omitempty"`
+	Retry          *RetryPolicy `json:"retry,omitempty"`
+	TimeoutSeconds int          `json:"timeout_seconds,omitempty"`
+	Deadline       *time.Time   `json:"deadline,omitempty"`
+	TTLSeconds     int          `json:"ttl_seconds,omitempty"`
+	CreatedBy      uint         `json:"created_by,omitempty"`
+}
+
+// RetryPolicy overrides the server's retry settings for one task. Unset
+// fields fall back to the server defaults.
+type RetryPolicy struct {
+	MaxRetries        *int     `json:"max_retries,omitempty"`
+	Backoff           string   `json:"backoff,omitempty"`
+	BaseSeconds       *int     `json:"base_seconds,omitempty"`
+	MaxSeconds        *int     `json:"max_seconds,omitempty"`
+	NonRetryableCodes []string `json:"non_retryable_codes,omitempty"`
 }
@@ -0,0 +1,27 @@
+package dto
+
+import "time"
+
+type TaskGroupReduce struct {
+	WasmModule     string       `json:"wasm_module" binding:"required"`
+	Func           string       `json:"func" binding:"required"`
+	Path           string       `json:"path,omitempty"`
+	Queue          string       `json:"queue,omitempty"`
+	Priority       int          `json:"priority,omitempty"`
+	Retry          *RetryPolicy `json:"retry,omitempty"`
+	TimeoutSeconds int          `json:"timeout_seconds,omitempty"`
+	Deadline       *time.Time   `json:"deadline,omitempty"`
+	TTLSeconds     int          `json:"ttl_seconds,omitempty"`
+}
+
+type TaskGroup struct {
+	ID           uint           `json:"id"`
+	OnFailure    string         `json:"on_failure"`
+	Status       string         `json:"status"`
+	Counts       map[string]int `json:"counts,omitempty"`
+	TaskIDs      []uint         `json:"task_ids"`
+	ReduceTaskID uint           `json:"reduce_task_id"`
+	ReduceStatus string         `json:"reduce_status,omitempty"`
+	CreatedBy    uint           `json:"created_by,omitempty"`
+	CreatedAt    *time.Time     `json:"created_at,omitempty"`
+}
@@ -0,0 +1,21 @@
+package dto
+
+import "time"
+
+type WorkflowTask struct {
+	Key       string   `json:"key" binding:"required"`
+	Task      Task     `json:"task" binding:"required"`
+	DependsOn []string `json:"depends_on,omitempty"`
+	TaskID    uint     `json:"task_id,omitempty"`
+	Status    string   `json:"status,omitempty"`
+	ErrorMsg  string   `json:"error_msg,omitempty"`
+}
+
+type Workflow struct {
+	ID        uint           `json:"id"`
+	Status    string         `json:"status"`
+	Counts    map[string]int `json:"counts,omitempty"`
+	Tasks     []WorkflowTask `json:"tasks"`
+	CreatedBy uint           `json:"created_by,omitempty"`
+	CreatedAt *time.Time     `json:"created_at,omitempty"`
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CancelOnShutdown cancels the request context when shutdown is cancelled. It is
+// meant for long-polling routes, which would otherwise hold up a graceful
+// shutdown until they time out; other requests are left to finish.
+func CancelOnShutdown(shutdown context.Context) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestCtx, cancel := context.WithCancel(ctx.Request.Context())
+		defer cancel()
+		stop := context.AfterFunc(shutdown, cancel)
+		defer stop()
+
+		ctx.Request = ctx.Request.WithContext(requestCtx)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelOnShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	shutdown, stop := context.WithCancel(context.Background())
+	router := gin.New()
+	router.GET("/wait", CancelOnShutdown(shutdown), func(ctx *gin.Context) {
+		select {
+		case <-ctx.Request.Context().Done():
+			ctx.Status(http.StatusNoContent)
+		case <-time.After(5 * time.Second):
+			ctx.Status(http.StatusOK)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/wait", nil)
+	time.AfterFunc(10*time.Millisecond, stop)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestCancelOnShutdown_NotShuttingDown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/wait", CancelOnShutdown(context.Background()), func(ctx *gin.Context) {
+		assert.NoError(t, ctx.Request.Context().Err())
+		ctx.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/wait", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
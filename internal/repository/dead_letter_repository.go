@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+)
+
+const purgeBatchSize = 1000
+
+type DeadLetterFilter struct {
+	Func          string
+	Queue         string
+	ErrorCode     string
+	ErrorContains string
+}
+
+type DeadLetterRepository interface {
+	FindDeadLetters(userID uint, filter DeadLetterFilter, limit, offset int) ([]*database.TaskAudit, int64, error)
+	FindDeadLetterTaskIDs(userID uint, filter DeadLetterFilter, limit int) ([]uint, error)
+	FindTaskFailures(taskIDs []uint) ([]database.TaskFailure, error)
+	PurgeDeadLetters(userID uint, filter DeadLetterFilter, failedBefore time.Time) (int64, error)
+}
+
+type deadLetterRepository struct{}
+
+func NewDeadLetterRepository() DeadLetterRepository {
+	return &deadLetterRepository{}
+}
+
+func deadLetterQuery(userID uint, filter DeadLetterFilter) *gorm.DB {
+	query := database.DB.Model(&database.TaskAudit{}).
+		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
+		Where("tasks.created_by = ? AND task_audit.status = ?", userID, database.TaskStatusFailed)
+	if filter.Func != "" {
+		query = query.Where("tasks.func = ?", filter.Func)
+	}
+	if filter.Queue != "" {
+		query = query.Where("task_audit.queue = ?", filter.Queue)
+	}
+	if filter.ErrorCode != "" {
+		query = query.Where("task_audit.error_code = ?", filter.ErrorCode)
+	}
+	if filter.ErrorContains != "" {
+		query = query.Where("task_audit.error_msg LIKE ?", "%"+escapeLike(filter.ErrorContains)+"%")
+	}
+	return query
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (r *deadLetterRepository) FindDeadLetters(userID uint, filter DeadLetterFilter, limit, offset int) ([]*database.TaskAudit, int64, error) {
+	if database.DB == nil {
+		return nil, 0, errors.New("database not initialized")
+	}
+
+	var audits []*database.TaskAudit
+	var total int64
+
+	query := deadLetterQuery(userID, filter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Preload("Task").
+		Order("task_audit.updated_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&audits).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return audits, total, nil
+}
+
+func (r *deadLetterRepository) FindDeadLetterTaskIDs(userID uint, filter DeadLetterFilter, limit int) ([]uint, error) {
+	if database.DB == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var taskIDs []uint
+	err := deadLetterQuery(userID, filter).
+		Order("task_audit.task_id ASC").
+		Limit(limit).
+		Pluck("task_audit.task_id", &taskIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return taskIDs, nil
+}
+
+func (r *deadLetterRepository) FindTaskFailures(taskIDs []uint) ([]database.TaskFailure, error) {
+	if database.DB == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var failures []database.TaskFailure
+	if len(taskIDs) == 0 {
+		return failures, nil
+	}
+	err := database.DB.Where("task_id IN ?", taskIDs).
+		Order("task_id ASC, id ASC").
+		Find(&failures).Error
+	if err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// PurgeDeadLetters deletes the matching tasks together with their audit rows,
+// results and failure history. Tasks that blocked dependents are still waiting
+// on, or that a live idempotency key points at, are kept: deleting them would
+// strand the dependents or let a retried request publish the task again.
+func (r *deadLetterRepository) PurgeDeadLetters(userID uint, filter DeadLetterFilter, failedBefore time.Time) (int64, error) {
+	if database.DB == nil {
+		return 0, errors.New("database not initialized")
+	}
+
+	// MySQL cannot delete from tasks with a subquery that reads tasks, so
+	// collect the ids first.
+	var taskIDs []uint
+	if err := deadLetterQuery(userID, filter).
+		Where("task_audit.updated_at < ?", failedBefore).
+		Where(`NOT EXISTS (SELECT 1 FROM task_dependencies
+			JOIN task_audit AS dependent ON dependent.task_id = task_dependencies.task_id
+			WHERE task_dependencies.depends_on_task_id = task_audit.task_id AND dependent.status = ?)`, database.TaskStatusBlocked).
+		Where("NOT EXISTS (SELECT 1 FROM idempotency_keys WHERE idempotency_keys.task_id = task_audit.task_id AND idempotency_keys.expires_at > ?)", time.Now()).
+		Pluck("task_audit.task_id", &taskIDs).Error; err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for start := 0; start < len(taskIDs); start += purgeBatchSize {
+		end := min(start+purgeBatchSize, len(taskIDs))
+		result := database.DB.Where("id IN ?", taskIDs[start:end]).Delete(&database.Task{})
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		purged += result.RowsAffected
+	}
+	return purged, nil
+}
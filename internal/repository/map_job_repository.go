@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+)
+
+type MapJobRepository interface {
+	CreateMapJob(job *database.MapJob) error
+	FindMapJobByID(jobID uint) (*database.MapJob, error)
+	CountMapJobTasks(jobID uint) (map[string]int64, error)
+	FindMapJobTasks(jobID uint) ([]*database.TaskAudit, error)
+	FindMapJobResults(jobID uint) ([]database.Result, error)
+}
+
+type mapJobRepository struct {
+	tx *gorm.DB
+}
+
+func NewMapJobRepository() MapJobRepository {
+	return &mapJobRepository{}
+}
+
+func (r *mapJobRepository) conn() *gorm.DB {
+	if r.tx != nil {
+		return r.tx
+	}
+	return database.DB
+}
+
+func (r *mapJobRepository) CreateMapJob(job *database.MapJob) error {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	return r.conn().Create(job).Error
+}
+
+func (r *mapJobRepository) FindMapJobByID(jobID uint) (*database.MapJob, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var job database.MapJob
+	err := r.conn().Where("id = ?", jobID).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *mapJobRepository) CountMapJobTasks(jobID uint) (map[string]int64, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	var results []struct {
+		Status database.TaskStatus `gorm:"column:status"`
+		Count  int64               `gorm:"column:count"`
+	}
+
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
+		Select("task_audit.status, COUNT(*) as count").
+		Where("tasks.map_job_id = ?", jobID).
+		Group("task_audit.status").
+		Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(results))
+	for _, result := range results {
+		counts[string(result.Status)] = result.Count
+	}
+	return counts, nil
+}
+
+func (r *mapJobRepository) FindMapJobTasks(jobID uint) ([]*database.TaskAudit, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var audits []*database.TaskAudit
+	err := r.conn().Model(&database.TaskAudit{}).
+		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
+		Where("tasks.map_job_id = ?", jobID).
+		Preload("Task").
+		Order("tasks.map_index ASC").
+		Find(&audits).Error
+	if err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+func (r *mapJobRepository) FindMapJobResults(jobID uint) ([]database.Result, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var results []database.Result
+	err := r.conn().Model(&database.Result{}).
+		Joins("JOIN tasks ON results.task_id = tasks.id").
+		Where("tasks.map_job_id = ?", jobID).
+		Order("results.id ASC").
+		Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -2,8 +2,10 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"rainchanel.com/internal/database"
 )
 
@@ -12,29 +14,38 @@ type ResultRepository interface {
 	FindResultByTaskID(taskID uint) (*database.Result, error)
 	FindResultsByUserID(userID uint) ([]database.Result, error)
 	FindResultByID(resultID uint) (*database.Result, error)
-	FindOldestUnconsumedResultByUserID(userID uint) (*database.Result, error)
-	MarkResultAsConsumed(resultID uint) error
+	LeaseResults(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error)
+	AckResult(resultID uint, receipt string) (bool, error)
 }
 
-type resultRepository struct{}
+type resultRepository struct {
+	tx *gorm.DB
+}
 
 func NewResultRepository() ResultRepository {
 	return &resultRepository{}
 }
 
+func (r *resultRepository) conn() *gorm.DB {
+	if r.tx != nil {
+		return r.tx
+	}
+	return database.DB
+}
+
 func (r *resultRepository) CreateResult(result *database.Result) error {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return errors.New("database not initialized")
 	}
-	return database.DB.Create(result).Error
+	return r.conn().Create(result).Error
 }
 
 func (r *resultRepository) FindResultByTaskID(taskID uint) (*database.Result, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 	var result database.Result
-	err := database.DB.Where("task_id = ?", taskID).First(&result).Error
+	err := r.conn().Where("task_id = ?", taskID).First(&result).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
@@ -45,11 +56,11 @@ func (r *resultRepository) FindResultByTaskID(taskID uint) (*database.Result, er
 }
 
 func (r *resultRepository) FindResultsByUserID(userID uint) ([]database.Result, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 	var results []database.Result
-	err := database.DB.Where("created_by = ?", userID).Order("created_at DESC").Find(&results).Error
+	err := r.conn().Where("created_by = ?", userID).Order("created_at DESC").Find(&results).Error
 	if err != nil {
 		return nil, err
 	}
@@ -57,11 +68,11 @@ func (r *resultRepository) FindResultsByUserID(userID uint) ([]database.Result,
 }
 
 func (r *resultRepository) FindResultByID(resultID uint) (*database.Result, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 	var result database.Result
-	err := database.DB.Where("id = ?", resultID).First(&result).Error
+	err := r.conn().Where("id = ?", resultID).First(&result).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
@@ -71,28 +82,65 @@ func (r *resultRepository) FindResultByID(resultID uint) (*database.Result, erro
 	return &result, nil
 }
 
-func (r *resultRepository) FindOldestUnconsumedResultByUserID(userID uint) (*database.Result, error) {
-	if database.DB == nil {
+// LeaseResults leases the user's oldest unconsumed results that are not
+// already leased, one per receipt, until leaseExpiresAt.
+func (r *resultRepository) LeaseResults(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error) {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
-	var result database.Result
-	err := database.DB.Where("created_by = ? AND consumed = ?", userID, false).
-		Order("created_at ASC").
-		First(&result).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, err
+	if len(receipts) == 0 {
+		return []*database.Result{}, nil
 	}
+
+	var results []*database.Result
+	err := r.conn().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("created_by = ? AND consumed = ?", userID, false).
+			Where("lease_expires_at IS NULL OR lease_expires_at <= ?", now).
+			Order("created_at ASC").
+			Order("id ASC").
+			Limit(len(receipts)).
+			Find(&results).Error
+		if err != nil {
+			return err
+		}
+
+		for i, result := range results {
+			err := tx.Model(&database.Result{}).
+				Where("id = ?", result.ID).
+				Updates(map[string]interface{}{
+					"receipt":          receipts[i],
+					"lease_expires_at": leaseExpiresAt,
+				}).Error
+			if err != nil {
+				return err
+			}
+			result.Receipt = receipts[i]
+			result.LeaseExpiresAt = &leaseExpiresAt
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &result, nil
+	return results, nil
 }
 
-func (r *resultRepository) MarkResultAsConsumed(resultID uint) error {
-	if database.DB == nil {
-		return errors.New("database not initialized")
+// AckResult consumes a result, but only while receipt is its latest lease. It
+// reports whether the result was consumed.
+func (r *resultRepository) AckResult(resultID uint, receipt string) (bool, error) {
+	if r.conn() == nil {
+		return false, errors.New("database not initialized")
+	}
+	result := r.conn().Model(&database.Result{}).
+		Where("id = ? AND receipt = ? AND consumed = ?", resultID, receipt, false).
+		Updates(map[string]interface{}{
+			"consumed":         true,
+			"lease_expires_at": nil,
+		})
+	if result.Error != nil {
+		return false, result.Error
 	}
-	return database.DB.Model(&database.Result{}).
-		Where("id = ?", resultID).
-		Update("consumed", true).Error
+	return result.RowsAffected == 1, nil
 }
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+)
+
+type ScheduleRepository interface {
+	CreateSchedule(schedule *database.Schedule) error
+	FindScheduleByID(scheduleID uint) (*database.Schedule, error)
+	FindSchedulesByUserID(userID uint) ([]database.Schedule, error)
+	FindDueSchedules(now time.Time) ([]database.Schedule, error)
+	UpdateScheduleStatus(scheduleID uint, status database.ScheduleStatus, nextRunAt time.Time) error
+	AdvanceSchedule(scheduleID uint, expectedNextRunAt time.Time, nextRunAt time.Time, lastRunAt *time.Time) (bool, error)
+	DeleteSchedule(scheduleID uint) error
+}
+
+type scheduleRepository struct{}
+
+func NewScheduleRepository() ScheduleRepository {
+	return &scheduleRepository{}
+}
+
+func (r *scheduleRepository) CreateSchedule(schedule *database.Schedule) error {
+	if database.DB == nil {
+		return errors.New("database not initialized")
+	}
+	return database.DB.Create(schedule).Error
+}
+
+func (r *scheduleRepository) FindScheduleByID(scheduleID uint) (*database.Schedule, error) {
+	if database.DB == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var schedule database.Schedule
+	err := database.DB.Where("id = ?", scheduleID).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *scheduleRepository) FindSchedulesByUserID(userID uint) ([]database.Schedule, error) {
+	if database.DB == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var schedules []database.Schedule
+	err := database.DB.Where("created_by = ?", userID).Order("created_at DESC").Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *scheduleRepository) FindDueSchedules(now time.Time) ([]database.Schedule, error) {
+	if database.DB == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var schedules []database.Schedule
+	err := database.DB.
+		Where("status = ? AND next_run_at <= ?", database.ScheduleStatusActive, now).
+		Order("next_run_at ASC").
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *scheduleRepository) UpdateScheduleStatus(scheduleID uint, status database.ScheduleStatus, nextRunAt time.Time) error {
+	if database.DB == nil {
+		return errors.New("database not initialized")
+	}
+	return database.DB.Model(&database.Schedule{}).
+		Where("id = ?", scheduleID).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"next_run_at": nextRunAt,
+		}).Error
+}
+
+func (r *scheduleRepository) AdvanceSchedule(scheduleID uint, expectedNextRunAt time.Time, nextRunAt time.Time, lastRunAt *time.Time) (bool, error) {
+	if database.DB == nil {
+		return false, errors.New("database not initialized")
+	}
+	updates := map[string]interface{}{
+		"next_run_at": nextRunAt,
+	}
+	if lastRunAt != nil {
+		updates["last_run_at"] = *lastRunAt
+	}
+	result := database.DB.Model(&database.Schedule{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", scheduleID, database.ScheduleStatusActive, expectedNextRunAt).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *scheduleRepository) DeleteSchedule(scheduleID uint) error {
+	if database.DB == nil {
+		return errors.New("database not initialized")
+	}
+	return database.DB.Where("id = ?", scheduleID).Delete(&database.Schedule{}).Error
+}
@@ -12,15 +12,27 @@ import (
 
 type TaskAuditRepository interface {
 	CreateTaskAudit(audit *database.TaskAudit) error
+	CreateTaskAudits(audits []*database.TaskAudit) error
 	FindTaskAuditByTaskID(taskID uint) (*database.TaskAudit, error)
-	UpdateTaskAuditStatus(taskID uint, status database.TaskStatus) error
-	UpdateTaskAuditConsumed(taskID uint) error
-	UpdateTaskAuditCompleted(taskID uint, processedBy uint) error
-	FindAndClaimPendingTask() (*database.TaskAudit, error)
+	UpdateTaskAuditCompleted(taskID uint, processedBy uint, claimToken string) error
+	FindAndClaimPendingTasks(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error)
 	FindStaleTasks(timeoutDuration time.Duration) ([]*database.TaskAudit, error)
-	ReclaimStaleTask(taskID uint, errorMsg string) error
-	UpdateTaskFailed(taskID uint, errorMsg string) error
+	FindOverdueTasks(now time.Time) ([]*database.TaskAudit, error)
+	MarkDeadlineExceeded(taskID uint, errorMsg string) (bool, error)
+	FindExpiredTasks(now time.Time) ([]*database.TaskAudit, error)
+	ExpireTask(taskID uint, errorMsg string) (bool, error)
+	ReclaimStaleTask(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error
+	ExtendLease(taskID uint, claimToken string, leaseExpiresAt time.Time) (bool, error)
+	ReleaseTask(taskID uint, claimToken string, nextAttemptAt *time.Time, reason string) error
+	UpdateTaskFailed(taskID uint, claimToken string, errorCode string, errorMsg string) error
+	CancelTask(taskID uint) (bool, error)
+	FindTaskAuditsByTaskIDs(taskIDs []uint) ([]*database.TaskAudit, error)
+	UnblockTask(taskID uint, effectiveAt time.Time, expiresAt *time.Time) (bool, error)
+	FailBlockedTask(taskID uint, errorCode string, errorMsg string) (bool, error)
+	RequeueFailedTask(taskID uint, status database.TaskStatus, effectiveAt time.Time, expiresAt *time.Time) (bool, error)
+	CreateTaskFailure(failure *database.TaskFailure) error
 	GetTaskStatistics() (map[string]int64, error)
+	GetQueueStatistics() (map[string]map[string]int64, error)
 	GetEnhancedStatistics() (map[string]interface{}, error)
 	FindTasksWithPagination(limit, offset int, status *database.TaskStatus) ([]*database.TaskAudit, int64, error)
 	GetRecentActivity(hours int) (map[string]int64, error)
@@ -32,25 +44,103 @@ type TaskAuditRepository interface {
 	GetUserErrorBreakdown(userID uint, limit int) ([]map[string]interface{}, error)
 }
 
-type taskAuditRepository struct{}
+type taskAuditRepository struct {
+	tx *gorm.DB
+}
+
+// activeStatuses are the statuses of a task that has not finished yet.
+var activeStatuses = []database.TaskStatus{database.TaskStatusPending, database.TaskStatusProcessing, database.TaskStatusBlocked}
 
 func NewTaskAuditRepository() TaskAuditRepository {
 	return &taskAuditRepository{}
 }
 
+func (r *taskAuditRepository) conn() *gorm.DB {
+	if r.tx != nil {
+		return r.tx
+	}
+	return database.DB
+}
+
+// ErrInvalidTransition is returned when a status update does not apply
+// because the task is no longer where the transition starts, usually because
+// another worker or the sweeper moved it first.
+var ErrInvalidTransition = errors.New("invalid task status transition")
+
+// TransitionError reports a status update that was refused. It matches
+// ErrInvalidTransition with errors.Is.
+type TransitionError struct {
+	TaskID uint
+	To     database.TaskStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s: task %d cannot move to %s from its current status", ErrInvalidTransition, e.TaskID, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// transitionTask moves a task to status to, but only while it is still in
+// one of the from statuses and matches the extra condition, if any. Worker
+// updates pass their claim token as the condition, which pins the update to
+// the attempt they claimed. It reports whether the row was updated.
+func transitionTask(db *gorm.DB, taskID uint, from []database.TaskStatus, to database.TaskStatus, updates map[string]interface{}, condition string, args ...interface{}) (bool, error) {
+	for _, status := range from {
+		if !database.CanTransition(status, to) {
+			return false, fmt.Errorf("%w: %s to %s is not allowed", ErrInvalidTransition, status, to)
+		}
+	}
+
+	updates["status"] = to
+	query := db.Model(&database.TaskAudit{}).Where("task_id = ? AND status IN ?", taskID, from)
+	if condition != "" {
+		query = query.Where(condition, args...)
+	}
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// transitionClaimedTask moves a task that the holder of claimToken is working
+// on, and returns a *TransitionError if that claim is no longer current.
+func (r *taskAuditRepository) transitionClaimedTask(taskID uint, claimToken string, to database.TaskStatus, updates map[string]interface{}) error {
+	moved, err := transitionTask(r.conn(), taskID, []database.TaskStatus{database.TaskStatusProcessing}, to, updates, "claim_token = ?", claimToken)
+	if err != nil {
+		return err
+	}
+	if !moved {
+		return &TransitionError{TaskID: taskID, To: to}
+	}
+	return nil
+}
+
 func (r *taskAuditRepository) CreateTaskAudit(audit *database.TaskAudit) error {
-	if database.DB == nil {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	return r.conn().Create(audit).Error
+}
+
+func (r *taskAuditRepository) CreateTaskAudits(audits []*database.TaskAudit) error {
+	if r.conn() == nil {
 		return errors.New("database not initialized")
 	}
-	return database.DB.Create(audit).Error
+	if len(audits) == 0 {
+		return nil
+	}
+	return r.conn().CreateInBatches(audits, createBatchSize).Error
 }
 
 func (r *taskAuditRepository) FindTaskAuditByTaskID(taskID uint) (*database.TaskAudit, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 	var audit database.TaskAudit
-	err := database.DB.Preload("Task").Where("task_id = ?", taskID).First(&audit).Error
+	err := r.conn().Preload("Task").Preload("Task.ReducesGroup").Where("task_id = ?", taskID).First(&audit).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
@@ -60,43 +150,20 @@ func (r *taskAuditRepository) FindTaskAuditByTaskID(taskID uint) (*database.Task
 	return &audit, nil
 }
 
-func (r *taskAuditRepository) UpdateTaskAuditStatus(taskID uint, status database.TaskStatus) error {
-	if database.DB == nil {
-		return errors.New("database not initialized")
-	}
-	return database.DB.Model(&database.TaskAudit{}).
-		Where("task_id = ?", taskID).
-		Update("status", status).Error
-}
-
-func (r *taskAuditRepository) UpdateTaskAuditConsumed(taskID uint) error {
-	if database.DB == nil {
+func (r *taskAuditRepository) UpdateTaskAuditCompleted(taskID uint, processedBy uint, claimToken string) error {
+	if r.conn() == nil {
 		return errors.New("database not initialized")
 	}
-	now := time.Now()
-	return database.DB.Model(&database.TaskAudit{}).
-		Where("task_id = ? AND consumed_at IS NULL", taskID).
-		Updates(map[string]interface{}{
-			"status":      database.TaskStatusProcessing,
-			"consumed_at": now,
-		}).Error
+	return r.transitionClaimedTask(taskID, claimToken, database.TaskStatusCompleted, map[string]interface{}{
+		"completed_at":     time.Now(),
+		"processed_by":     processedBy,
+		"lease_expires_at": nil,
+	})
 }
 
-func (r *taskAuditRepository) UpdateTaskAuditCompleted(taskID uint, processedBy uint) error {
-	if database.DB == nil {
-		return errors.New("database not initialized")
-	}
-	now := time.Now()
-	return database.DB.Model(&database.TaskAudit{}).
-		Where("task_id = ?", taskID).
-		Updates(map[string]interface{}{
-			"status":       database.TaskStatusCompleted,
-			"completed_at": now,
-			"processed_by": processedBy,
-		}).Error
-}
-
-func (r *taskAuditRepository) FindAndClaimPendingTask() (*database.TaskAudit, error) {
+// FindAndClaimPendingTasks always runs in its own transaction on the shared
+// connection, so claims are committed as soon as it returns.
+func (r *taskAuditRepository) FindAndClaimPendingTasks(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
 	if database.DB == nil {
 		return nil, errors.New("database not initialized")
 	}
@@ -109,7 +176,11 @@ func (r *taskAuditRepository) FindAndClaimPendingTask() (*database.TaskAudit, er
 		return nil, errors.New("database not initialized")
 	}
 
-	var audit database.TaskAudit
+	if len(claimTokens) == 0 {
+		return []*database.TaskAudit{}, nil
+	}
+
+	var audits []*database.TaskAudit
 
 	tx := database.DB.Begin()
 	if tx.Error != nil {
@@ -128,30 +199,52 @@ func (r *taskAuditRepository) FindAndClaimPendingTask() (*database.TaskAudit, er
 		}
 	}()
 
-	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("status = ?", database.TaskStatusPending).
+	now := time.Now()
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", database.TaskStatusPending, now).
+		Where("deadline IS NULL OR deadline > ?", now).
+		Where("expires_at IS NULL OR expires_at > ?", now)
+	if len(queues) > 0 {
+		query = query.Where("queue IN ?", queues)
+	}
+	err = query.
+		Order("effective_at ASC").
 		Order("published_at ASC").
-		Preload("Task").
-		First(&audit).Error
+		Limit(len(claimTokens)).
+		Find(&audits).Error
 
 	if err != nil {
 		tx.Rollback()
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
-		}
-		return nil, fmt.Errorf("failed to find pending task: %w", err)
+		return nil, fmt.Errorf("failed to find pending tasks: %w", err)
 	}
 
-	now := time.Now()
-	err = tx.Model(&audit).
-		Updates(map[string]interface{}{
-			"status":      database.TaskStatusProcessing,
-			"consumed_at": now,
-		}).Error
-
-	if err != nil {
+	if len(audits) == 0 {
 		tx.Rollback()
-		return nil, fmt.Errorf("failed to claim task: %w", err)
+		return audits, nil
+	}
+
+	taskIDs := make([]uint, 0, len(audits))
+	for i, audit := range audits {
+		lease := leaseDuration
+		if audit.TimeoutSeconds > 0 {
+			lease = time.Duration(audit.TimeoutSeconds) * time.Second
+		}
+		claimed, err := transitionTask(tx, audit.TaskID, []database.TaskStatus{database.TaskStatusPending}, database.TaskStatusProcessing,
+			map[string]interface{}{
+				"consumed_at":      now,
+				"processed_by":     workerID,
+				"claim_token":      claimTokens[i],
+				"lease_expires_at": now.Add(lease),
+				"next_attempt_at":  nil,
+				"expires_at":       nil,
+			}, "")
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to claim task: %w", err)
+		}
+		if claimed {
+			taskIDs = append(taskIDs, audit.TaskID)
+		}
 	}
 
 	if err := tx.Commit().Error; err != nil {
@@ -159,23 +252,30 @@ func (r *taskAuditRepository) FindAndClaimPendingTask() (*database.TaskAudit, er
 	}
 	committed = true
 
-	err = database.DB.Preload("Task").Where("task_id = ?", audit.TaskID).First(&audit).Error
+	audits = nil
+	err = database.DB.Preload("Task").Preload("Task.MapJob").Preload("Task.ReducesGroup").
+		Where("task_id IN ?", taskIDs).
+		Order("effective_at ASC").
+		Order("published_at ASC").
+		Find(&audits).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to reload task audit: %w", err)
+		return nil, fmt.Errorf("failed to reload task audits: %w", err)
 	}
 
-	return &audit, nil
+	return audits, nil
 }
 
 func (r *taskAuditRepository) FindStaleTasks(timeoutDuration time.Duration) ([]*database.TaskAudit, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 	var audits []*database.TaskAudit
-	threshold := time.Now().Add(-timeoutDuration)
+	now := time.Now()
+	threshold := now.Add(-timeoutDuration)
 
-	err := database.DB.
-		Where("status = ? AND consumed_at < ?", database.TaskStatusProcessing, threshold).
+	err := r.conn().
+		Where("status = ? AND (lease_expires_at < ? OR (lease_expires_at IS NULL AND consumed_at < ?))",
+			database.TaskStatusProcessing, now, threshold).
 		Preload("Task").
 		Find(&audits).Error
 
@@ -186,73 +286,293 @@ func (r *taskAuditRepository) FindStaleTasks(timeoutDuration time.Duration) ([]*
 	return audits, nil
 }
 
-func (r *taskAuditRepository) ReclaimStaleTask(taskID uint, errorMsg string) error {
-	if database.DB == nil {
+func (r *taskAuditRepository) FindOverdueTasks(now time.Time) ([]*database.TaskAudit, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var audits []*database.TaskAudit
+	err := r.conn().
+		Where("status IN ? AND deadline < ?", activeStatuses, now).
+		Preload("Task").
+		Find(&audits).Error
+	if err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+func (r *taskAuditRepository) MarkDeadlineExceeded(taskID uint, errorMsg string) (bool, error) {
+	if r.conn() == nil {
+		return false, errors.New("database not initialized")
+	}
+	return transitionTask(r.conn(), taskID, activeStatuses, database.TaskStatusDeadlineExceeded, map[string]interface{}{
+		"error_code":       string(database.TaskStatusDeadlineExceeded),
+		"error_msg":        errorMsg,
+		"claim_token":      "",
+		"next_attempt_at":  nil,
+		"lease_expires_at": nil,
+	}, "")
+}
+
+func (r *taskAuditRepository) FindExpiredTasks(now time.Time) ([]*database.TaskAudit, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var audits []*database.TaskAudit
+	err := r.conn().
+		Where("status = ? AND expires_at <= ?", database.TaskStatusPending, now).
+		Preload("Task").
+		Find(&audits).Error
+	if err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+func (r *taskAuditRepository) ExpireTask(taskID uint, errorMsg string) (bool, error) {
+	if r.conn() == nil {
+		return false, errors.New("database not initialized")
+	}
+	return transitionTask(r.conn(), taskID, []database.TaskStatus{database.TaskStatusPending}, database.TaskStatusExpired, map[string]interface{}{
+		"error_code":      string(database.TaskStatusExpired),
+		"error_msg":       errorMsg,
+		"next_attempt_at": nil,
+	}, "expires_at IS NOT NULL")
+}
+
+func (r *taskAuditRepository) ReclaimStaleTask(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+	if r.conn() == nil {
 		return errors.New("database not initialized")
 	}
-	return database.DB.Model(&database.TaskAudit{}).
-		Where("task_id = ?", taskID).
-		Updates(map[string]interface{}{
-			"status":      database.TaskStatusPending,
-			"consumed_at": nil,
-			"error_msg":   errorMsg,
-			"retry_count": gorm.Expr("retry_count + 1"),
-		}).Error
+	return r.transitionClaimedTask(taskID, claimToken, database.TaskStatusPending, map[string]interface{}{
+		"consumed_at":      nil,
+		"processed_by":     nil,
+		"claim_token":      "",
+		"lease_expires_at": nil,
+		"next_attempt_at":  nextAttemptAt,
+		"error_code":       errorCode,
+		"error_msg":        errorMsg,
+		"retry_count":      gorm.Expr("retry_count + 1"),
+	})
 }
 
-func (r *taskAuditRepository) UpdateTaskFailed(taskID uint, errorMsg string) error {
-	if database.DB == nil {
+func (r *taskAuditRepository) ExtendLease(taskID uint, claimToken string, leaseExpiresAt time.Time) (bool, error) {
+	if r.conn() == nil {
+		return false, errors.New("database not initialized")
+	}
+	result := r.conn().Model(&database.TaskAudit{}).
+		Where("task_id = ? AND status = ? AND claim_token = ?", taskID, database.TaskStatusProcessing, claimToken).
+		Update("lease_expires_at", leaseExpiresAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *taskAuditRepository) ReleaseTask(taskID uint, claimToken string, nextAttemptAt *time.Time, reason string) error {
+	if r.conn() == nil {
 		return errors.New("database not initialized")
 	}
-	return database.DB.Model(&database.TaskAudit{}).
-		Where("task_id = ?", taskID).
-		Updates(map[string]interface{}{
-			"status":    database.TaskStatusFailed,
-			"error_msg": errorMsg,
-		}).Error
+	return r.transitionClaimedTask(taskID, claimToken, database.TaskStatusPending, map[string]interface{}{
+		"consumed_at":      nil,
+		"processed_by":     nil,
+		"claim_token":      "",
+		"lease_expires_at": nil,
+		"next_attempt_at":  nextAttemptAt,
+		"release_reason":   reason,
+	})
+}
+
+func (r *taskAuditRepository) UpdateTaskFailed(taskID uint, claimToken string, errorCode string, errorMsg string) error {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	return r.transitionClaimedTask(taskID, claimToken, database.TaskStatusFailed, map[string]interface{}{
+		"error_code":       errorCode,
+		"error_msg":        errorMsg,
+		"lease_expires_at": nil,
+	})
+}
+
+func (r *taskAuditRepository) CancelTask(taskID uint) (bool, error) {
+	if r.conn() == nil {
+		return false, errors.New("database not initialized")
+	}
+	return transitionTask(r.conn(), taskID, activeStatuses, database.TaskStatusCancelled, map[string]interface{}{
+		"next_attempt_at":  nil,
+		"lease_expires_at": nil,
+		"error_msg":        "Task cancelled by owner",
+	}, "")
+}
+
+func (r *taskAuditRepository) FindTaskAuditsByTaskIDs(taskIDs []uint) ([]*database.TaskAudit, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var audits []*database.TaskAudit
+	if len(taskIDs) == 0 {
+		return audits, nil
+	}
+	err := r.conn().Preload("Task").Where("task_id IN ?", taskIDs).Find(&audits).Error
+	if err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+func (r *taskAuditRepository) UnblockTask(taskID uint, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+	if r.conn() == nil {
+		return false, errors.New("database not initialized")
+	}
+	return transitionTask(r.conn(), taskID, []database.TaskStatus{database.TaskStatusBlocked}, database.TaskStatusPending, map[string]interface{}{
+		"effective_at": effectiveAt,
+		"expires_at":   expiresAt,
+	}, "")
+}
+
+func (r *taskAuditRepository) FailBlockedTask(taskID uint, errorCode string, errorMsg string) (bool, error) {
+	if r.conn() == nil {
+		return false, errors.New("database not initialized")
+	}
+	return transitionTask(r.conn(), taskID, []database.TaskStatus{database.TaskStatusBlocked}, database.TaskStatusFailed, map[string]interface{}{
+		"error_code": errorCode,
+		"error_msg":  errorMsg,
+	}, "")
+}
+
+func (r *taskAuditRepository) RequeueFailedTask(taskID uint, status database.TaskStatus, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+	if r.conn() == nil {
+		return false, errors.New("database not initialized")
+	}
+	return transitionTask(r.conn(), taskID, []database.TaskStatus{database.TaskStatusFailed}, status, map[string]interface{}{
+		"retry_count":      0,
+		"error_code":       "",
+		"error_msg":        "",
+		"effective_at":     effectiveAt,
+		"next_attempt_at":  nil,
+		"consumed_at":      nil,
+		"processed_by":     nil,
+		"claim_token":      "",
+		"lease_expires_at": nil,
+		"expires_at":       expiresAt,
+		"completed_at":     nil,
+	}, "")
+}
+
+func (r *taskAuditRepository) CreateTaskFailure(failure *database.TaskFailure) error {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	return r.conn().Create(failure).Error
 }
 
 func (r *taskAuditRepository) GetTaskStatistics() (map[string]int64, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 	stats := make(map[string]int64)
 
 	var count int64
 
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Where("status = ?", database.TaskStatusPending).
 		Count(&count).Error; err != nil {
 		return nil, err
 	}
 	stats["pending"] = count
 
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Where("status = ?", database.TaskStatusProcessing).
 		Count(&count).Error; err != nil {
 		return nil, err
 	}
 	stats["processing"] = count
 
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Where("status = ?", database.TaskStatusCompleted).
 		Count(&count).Error; err != nil {
 		return nil, err
 	}
 	stats["completed"] = count
 
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Where("status = ?", database.TaskStatusFailed).
 		Count(&count).Error; err != nil {
 		return nil, err
 	}
 	stats["failed"] = count
 
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Where("status = ?", database.TaskStatusCancelled).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	stats["cancelled"] = count
+
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Where("status = ?", database.TaskStatusBlocked).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	stats["blocked"] = count
+
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Where("status = ?", database.TaskStatusDeadlineExceeded).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	stats["deadline_exceeded"] = count
+
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Where("status = ?", database.TaskStatusExpired).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	stats["expired"] = count
+
+	return stats, nil
+}
+
+func (r *taskAuditRepository) GetQueueStatistics() (map[string]map[string]int64, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	var results []struct {
+		Queue  string              `gorm:"column:queue"`
+		Status database.TaskStatus `gorm:"column:status"`
+		Count  int64               `gorm:"column:count"`
+	}
+
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Select("queue, status, COUNT(*) as count").
+		Group("queue, status").
+		Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]map[string]int64)
+	for _, result := range results {
+		if _, ok := stats[result.Queue]; !ok {
+			stats[result.Queue] = map[string]int64{
+				string(database.TaskStatusPending):          0,
+				string(database.TaskStatusProcessing):       0,
+				string(database.TaskStatusCompleted):        0,
+				string(database.TaskStatusFailed):           0,
+				string(database.TaskStatusCancelled):        0,
+				string(database.TaskStatusBlocked):          0,
+				string(database.TaskStatusDeadlineExceeded): 0,
+				string(database.TaskStatusExpired):          0,
+			}
+		}
+		stats[result.Queue][string(result.Status)] = result.Count
+	}
+
 	return stats, nil
 }
 
 func (r *taskAuditRepository) GetEnhancedStatistics() (map[string]interface{}, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 
@@ -265,14 +585,14 @@ func (r *taskAuditRepository) GetEnhancedStatistics() (map[string]interface{}, e
 	stats["counts"] = basicStats
 
 	var totalTasks int64
-	if err := database.DB.Model(&database.TaskAudit{}).Count(&totalTasks).Error; err != nil {
+	if err := r.conn().Model(&database.TaskAudit{}).Count(&totalTasks).Error; err != nil {
 		return nil, err
 	}
 	stats["total"] = totalTasks
 
 	var completedLastHour int64
 	oneHourAgo := time.Now().Add(-1 * time.Hour)
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Where("status = ? AND completed_at > ?", database.TaskStatusCompleted, oneHourAgo).
 		Count(&completedLastHour).Error; err != nil {
 		return nil, err
@@ -280,7 +600,7 @@ func (r *taskAuditRepository) GetEnhancedStatistics() (map[string]interface{}, e
 	stats["completed_last_hour"] = completedLastHour
 
 	var avgProcessingTime float64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Where("status = ? AND consumed_at IS NOT NULL AND completed_at IS NOT NULL", database.TaskStatusCompleted).
 		Select("AVG(TIMESTAMPDIFF(SECOND, consumed_at, completed_at))").
 		Scan(&avgProcessingTime).Error; err != nil {
@@ -290,7 +610,7 @@ func (r *taskAuditRepository) GetEnhancedStatistics() (map[string]interface{}, e
 	stats["avg_processing_time_seconds"] = avgProcessingTime
 
 	var retriedTasks int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Where("retry_count > 0").
 		Count(&retriedTasks).Error; err != nil {
 		return nil, err
@@ -298,7 +618,7 @@ func (r *taskAuditRepository) GetEnhancedStatistics() (map[string]interface{}, e
 	stats["retried_tasks"] = retriedTasks
 
 	var totalRetries int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Select("SUM(retry_count)").
 		Scan(&totalRetries).Error; err != nil {
 		totalRetries = 0
@@ -309,17 +629,17 @@ func (r *taskAuditRepository) GetEnhancedStatistics() (map[string]interface{}, e
 }
 
 func (r *taskAuditRepository) FindTasksWithPagination(limit, offset int, status *database.TaskStatus) ([]*database.TaskAudit, int64, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, 0, errors.New("database not initialized")
 	}
 
 	var audits []*database.TaskAudit
 	var total int64
 
-	query := database.DB.Model(&database.TaskAudit{}).Preload("Task").Preload("Task.Creator").Preload("Worker")
+	query := r.conn().Model(&database.TaskAudit{}).Preload("Task").Preload("Task.Creator").Preload("Worker")
 
 	if status != nil {
-		query = query.Where("status = ?", *status)
+		query = whereStatus(query, "status", "run_at", *status)
 	}
 
 	if err := query.Count(&total).Error; err != nil {
@@ -333,8 +653,18 @@ func (r *taskAuditRepository) FindTasksWithPagination(limit, offset int, status
 	return audits, total, nil
 }
 
+// whereStatus filters on status. Scheduled tasks are pending tasks held back
+// by run_at or delay_seconds; tasks waiting out a retry backoff or released
+// with a delay only have a later next_attempt_at and are left out.
+func whereStatus(query *gorm.DB, statusColumn, runAtColumn string, status database.TaskStatus) *gorm.DB {
+	if status == database.TaskStatusScheduled {
+		return query.Where(statusColumn+" = ? AND "+runAtColumn+" > ?", database.TaskStatusPending, time.Now())
+	}
+	return query.Where(statusColumn+" = ?", status)
+}
+
 func (r *taskAuditRepository) GetRecentActivity(hours int) (map[string]int64, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 
@@ -342,7 +672,7 @@ func (r *taskAuditRepository) GetRecentActivity(hours int) (map[string]int64, er
 	threshold := time.Now().Add(-time.Duration(hours) * time.Hour)
 
 	var published int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Where("published_at > ?", threshold).
 		Count(&published).Error; err != nil {
 		return nil, err
@@ -350,7 +680,7 @@ func (r *taskAuditRepository) GetRecentActivity(hours int) (map[string]int64, er
 	activity["published"] = published
 
 	var completed int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Where("status = ? AND completed_at > ?", database.TaskStatusCompleted, threshold).
 		Count(&completed).Error; err != nil {
 		return nil, err
@@ -358,7 +688,7 @@ func (r *taskAuditRepository) GetRecentActivity(hours int) (map[string]int64, er
 	activity["completed"] = completed
 
 	var failed int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Where("status = ? AND updated_at > ?", database.TaskStatusFailed, threshold).
 		Count(&failed).Error; err != nil {
 		return nil, err
@@ -369,45 +699,34 @@ func (r *taskAuditRepository) GetRecentActivity(hours int) (map[string]int64, er
 }
 
 func (r *taskAuditRepository) GetErrorBreakdown(limit int) ([]map[string]interface{}, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 
-	var results []struct {
-		ErrorMsg string `gorm:"column:error_msg"`
-		Count    int64  `gorm:"column:count"`
-	}
+	var results []errorBreakdownRow
 
-	if err := database.DB.Model(&database.TaskAudit{}).
-		Select("error_msg, COUNT(*) as count").
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Select("error_code, MAX(error_msg) as error_msg, COUNT(*) as count").
 		Where("status = ? AND error_msg != ''", database.TaskStatusFailed).
-		Group("error_msg").
+		Group("error_code").
 		Order("count DESC").
 		Limit(limit).
 		Scan(&results).Error; err != nil {
 		return nil, err
 	}
 
-	breakdown := make([]map[string]interface{}, len(results))
-	for i, result := range results {
-		breakdown[i] = map[string]interface{}{
-			"error": result.ErrorMsg,
-			"count": result.Count,
-		}
-	}
-
-	return breakdown, nil
+	return errorBreakdown(results), nil
 }
 
 func (r *taskAuditRepository) GetUserStatistics(userID uint) (map[string]int64, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 	stats := make(map[string]int64)
 
 	var count int64
 
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ? AND task_audit.status = ?", userID, database.TaskStatusPending).
 		Count(&count).Error; err != nil {
@@ -415,7 +734,7 @@ func (r *taskAuditRepository) GetUserStatistics(userID uint) (map[string]int64,
 	}
 	stats["pending"] = count
 
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ? AND task_audit.status = ?", userID, database.TaskStatusProcessing).
 		Count(&count).Error; err != nil {
@@ -423,7 +742,7 @@ func (r *taskAuditRepository) GetUserStatistics(userID uint) (map[string]int64,
 	}
 	stats["processing"] = count
 
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ? AND task_audit.status = ?", userID, database.TaskStatusCompleted).
 		Count(&count).Error; err != nil {
@@ -431,7 +750,7 @@ func (r *taskAuditRepository) GetUserStatistics(userID uint) (map[string]int64,
 	}
 	stats["completed"] = count
 
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ? AND task_audit.status = ?", userID, database.TaskStatusFailed).
 		Count(&count).Error; err != nil {
@@ -439,11 +758,43 @@ func (r *taskAuditRepository) GetUserStatistics(userID uint) (map[string]int64,
 	}
 	stats["failed"] = count
 
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
+		Where("tasks.created_by = ? AND task_audit.status = ?", userID, database.TaskStatusCancelled).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	stats["cancelled"] = count
+
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
+		Where("tasks.created_by = ? AND task_audit.status = ?", userID, database.TaskStatusBlocked).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	stats["blocked"] = count
+
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
+		Where("tasks.created_by = ? AND task_audit.status = ?", userID, database.TaskStatusDeadlineExceeded).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	stats["deadline_exceeded"] = count
+
+	if err := r.conn().Model(&database.TaskAudit{}).
+		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
+		Where("tasks.created_by = ? AND task_audit.status = ?", userID, database.TaskStatusExpired).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	stats["expired"] = count
+
 	return stats, nil
 }
 
 func (r *taskAuditRepository) GetUserEnhancedStatistics(userID uint) (map[string]interface{}, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 
@@ -456,7 +807,7 @@ func (r *taskAuditRepository) GetUserEnhancedStatistics(userID uint) (map[string
 	stats["counts"] = basicStats
 
 	var totalTasks int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ?", userID).
 		Count(&totalTasks).Error; err != nil {
@@ -466,7 +817,7 @@ func (r *taskAuditRepository) GetUserEnhancedStatistics(userID uint) (map[string
 
 	var completedLastHour int64
 	oneHourAgo := time.Now().Add(-1 * time.Hour)
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ? AND task_audit.status = ? AND task_audit.completed_at > ?", userID, database.TaskStatusCompleted, oneHourAgo).
 		Count(&completedLastHour).Error; err != nil {
@@ -475,7 +826,7 @@ func (r *taskAuditRepository) GetUserEnhancedStatistics(userID uint) (map[string
 	stats["completed_last_hour"] = completedLastHour
 
 	var avgProcessingTime float64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ? AND task_audit.status = ? AND task_audit.consumed_at IS NOT NULL AND task_audit.completed_at IS NOT NULL", userID, database.TaskStatusCompleted).
 		Select("AVG(TIMESTAMPDIFF(SECOND, task_audit.consumed_at, task_audit.completed_at))").
@@ -485,7 +836,7 @@ func (r *taskAuditRepository) GetUserEnhancedStatistics(userID uint) (map[string
 	stats["avg_processing_time_seconds"] = avgProcessingTime
 
 	var retriedTasks int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ? AND task_audit.retry_count > 0", userID).
 		Count(&retriedTasks).Error; err != nil {
@@ -494,7 +845,7 @@ func (r *taskAuditRepository) GetUserEnhancedStatistics(userID uint) (map[string
 	stats["retried_tasks"] = retriedTasks
 
 	var totalRetries int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ?", userID).
 		Select("SUM(task_audit.retry_count)").
@@ -507,20 +858,20 @@ func (r *taskAuditRepository) GetUserEnhancedStatistics(userID uint) (map[string
 }
 
 func (r *taskAuditRepository) FindUserTasksWithPagination(userID uint, limit, offset int, status *database.TaskStatus) ([]*database.TaskAudit, int64, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, 0, errors.New("database not initialized")
 	}
 
 	var audits []*database.TaskAudit
 	var total int64
 
-	query := database.DB.Model(&database.TaskAudit{}).
+	query := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ?", userID).
 		Preload("Task").Preload("Task.Creator").Preload("Worker")
 
 	if status != nil {
-		query = query.Where("task_audit.status = ?", *status)
+		query = whereStatus(query, "task_audit.status", "task_audit.run_at", *status)
 	}
 
 	if err := query.Count(&total).Error; err != nil {
@@ -535,7 +886,7 @@ func (r *taskAuditRepository) FindUserTasksWithPagination(userID uint, limit, of
 }
 
 func (r *taskAuditRepository) GetUserRecentActivity(userID uint, hours int) (map[string]int64, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 
@@ -543,7 +894,7 @@ func (r *taskAuditRepository) GetUserRecentActivity(userID uint, hours int) (map
 	threshold := time.Now().Add(-time.Duration(hours) * time.Hour)
 
 	var published int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ? AND task_audit.published_at > ?", userID, threshold).
 		Count(&published).Error; err != nil {
@@ -552,7 +903,7 @@ func (r *taskAuditRepository) GetUserRecentActivity(userID uint, hours int) (map
 	activity["published"] = published
 
 	var completed int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ? AND task_audit.status = ? AND task_audit.completed_at > ?", userID, database.TaskStatusCompleted, threshold).
 		Count(&completed).Error; err != nil {
@@ -561,7 +912,7 @@ func (r *taskAuditRepository) GetUserRecentActivity(userID uint, hours int) (map
 	activity["completed"] = completed
 
 	var failed int64
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
 		Where("tasks.created_by = ? AND task_audit.status = ? AND task_audit.updated_at > ?", userID, database.TaskStatusFailed, threshold).
 		Count(&failed).Error; err != nil {
@@ -573,33 +924,46 @@ func (r *taskAuditRepository) GetUserRecentActivity(userID uint, hours int) (map
 }
 
 func (r *taskAuditRepository) GetUserErrorBreakdown(userID uint, limit int) ([]map[string]interface{}, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 
-	var results []struct {
-		ErrorMsg string `gorm:"column:error_msg"`
-		Count    int64  `gorm:"column:count"`
-	}
+	var results []errorBreakdownRow
 
-	if err := database.DB.Model(&database.TaskAudit{}).
+	if err := r.conn().Model(&database.TaskAudit{}).
 		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
-		Select("task_audit.error_msg, COUNT(*) as count").
+		Select("task_audit.error_code, MAX(task_audit.error_msg) as error_msg, COUNT(*) as count").
 		Where("tasks.created_by = ? AND task_audit.status = ? AND task_audit.error_msg != ''", userID, database.TaskStatusFailed).
-		Group("task_audit.error_msg").
+		Group("task_audit.error_code").
 		Order("count DESC").
 		Limit(limit).
 		Scan(&results).Error; err != nil {
 		return nil, err
 	}
 
+	return errorBreakdown(results), nil
+}
+
+type errorBreakdownRow struct {
+	ErrorCode string `gorm:"column:error_code"`
+	ErrorMsg  string `gorm:"column:error_msg"`
+	Count     int64  `gorm:"column:count"`
+}
+
+// errorBreakdown reports failures grouped by error code, with one of the
+// group's messages as an example. Failures without a code are grouped together.
+func errorBreakdown(results []errorBreakdownRow) []map[string]interface{} {
 	breakdown := make([]map[string]interface{}, len(results))
 	for i, result := range results {
+		code := result.ErrorCode
+		if code == "" {
+			code = "unspecified"
+		}
 		breakdown[i] = map[string]interface{}{
+			"code":  code,
 			"error": result.ErrorMsg,
 			"count": result.Count,
 		}
 	}
-
-	return breakdown, nil
+	return breakdown
 }
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+)
+
+func TestWhereStatus(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		status database.TaskStatus
+		want   string
+	}{
+		{
+			name:   "stored status",
+			status: database.TaskStatusPending,
+			want:   "WHERE status = ?",
+		},
+		{
+			name:   "scheduled only looks at the requested run_at",
+			status: database.TaskStatusScheduled,
+			want:   "WHERE status = ? AND run_at > ?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var audits []database.TaskAudit
+			stmt := whereStatus(db.Model(&database.TaskAudit{}), "status", "run_at", tt.status).Find(&audits).Statement
+
+			assert.Contains(t, stmt.SQL.String(), tt.want)
+			assert.NotContains(t, stmt.SQL.String(), "next_attempt_at")
+			assert.Equal(t, database.TaskStatusPending, stmt.Vars[0])
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+)
+
+type TaskGroupRepository interface {
+	CreateTaskGroup(group *database.TaskGroup) error
+	FindTaskGroupByID(groupID uint) (*database.TaskGroup, error)
+	FindTaskGroupTasks(groupID uint) ([]*database.TaskAudit, error)
+	FindTaskGroupReduceTask(groupID uint) (*database.TaskAudit, error)
+}
+
+type taskGroupRepository struct {
+	tx *gorm.DB
+}
+
+func NewTaskGroupRepository() TaskGroupRepository {
+	return &taskGroupRepository{}
+}
+
+func (r *taskGroupRepository) conn() *gorm.DB {
+	if r.tx != nil {
+		return r.tx
+	}
+	return database.DB
+}
+
+func (r *taskGroupRepository) CreateTaskGroup(group *database.TaskGroup) error {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	return r.conn().Create(group).Error
+}
+
+func (r *taskGroupRepository) FindTaskGroupByID(groupID uint) (*database.TaskGroup, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var group database.TaskGroup
+	err := r.conn().Where("id = ?", groupID).First(&group).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *taskGroupRepository) FindTaskGroupTasks(groupID uint) ([]*database.TaskAudit, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var audits []*database.TaskAudit
+	err := r.conn().Model(&database.TaskAudit{}).
+		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
+		Where("tasks.task_group_id = ?", groupID).
+		Preload("Task").
+		Order("task_audit.task_id ASC").
+		Find(&audits).Error
+	if err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+func (r *taskGroupRepository) FindTaskGroupReduceTask(groupID uint) (*database.TaskAudit, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var audit database.TaskAudit
+	err := r.conn().Model(&database.TaskAudit{}).
+		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
+		Where("tasks.reduces_group_id = ?", groupID).
+		Preload("Task").
+		First(&audit).Error
+	if err != nil {
+		return nil, err
+	}
+	return &audit, nil
+}
@@ -7,30 +7,61 @@ import (
 	"rainchanel.com/internal/database"
 )
 
+// createBatchSize is how many rows CreateTasks and CreateTaskAudits put in a
+// single INSERT.
+const createBatchSize = 500
+
 type TaskRepository interface {
 	CreateTask(task *database.Task) error
+	CreateTasks(tasks []*database.Task) error
 	FindTaskByID(taskID uint) (*database.Task, error)
+	CreateIdempotencyKey(key *database.IdempotencyKey) error
+	FindIdempotencyKey(userID uint, key string) (*database.IdempotencyKey, error)
+	SetIdempotencyKeyTask(id uint, taskID uint) error
+	DeleteIdempotencyKey(id uint) error
+	CreateTaskDependencies(dependencies []database.TaskDependency) error
+	FindTaskDependencyIDs(taskID uint) ([]uint, error)
+	FindDependentTaskIDs(taskID uint) ([]uint, error)
 }
 
-type taskRepository struct{}
+type taskRepository struct {
+	tx *gorm.DB
+}
 
 func NewTaskRepository() TaskRepository {
 	return &taskRepository{}
 }
 
+func (r *taskRepository) conn() *gorm.DB {
+	if r.tx != nil {
+		return r.tx
+	}
+	return database.DB
+}
+
 func (r *taskRepository) CreateTask(task *database.Task) error {
-	if database.DB == nil {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	return r.conn().Create(task).Error
+}
+
+func (r *taskRepository) CreateTasks(tasks []*database.Task) error {
+	if r.conn() == nil {
 		return errors.New("database not initialized")
 	}
-	return database.DB.Create(task).Error
+	if len(tasks) == 0 {
+		return nil
+	}
+	return r.conn().CreateInBatches(tasks, createBatchSize).Error
 }
 
 func (r *taskRepository) FindTaskByID(taskID uint) (*database.Task, error) {
-	if database.DB == nil {
+	if r.conn() == nil {
 		return nil, errors.New("database not initialized")
 	}
 	var task database.Task
-	err := database.DB.Where("id = ?", taskID).First(&task).Error
+	err := r.conn().Where("id = ?", taskID).First(&task).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
@@ -40,3 +71,75 @@ func (r *taskRepository) FindTaskByID(taskID uint) (*database.Task, error) {
 	return &task, nil
 }
 
+func (r *taskRepository) CreateIdempotencyKey(key *database.IdempotencyKey) error {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	return r.conn().Create(key).Error
+}
+
+func (r *taskRepository) FindIdempotencyKey(userID uint, key string) (*database.IdempotencyKey, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var record database.IdempotencyKey
+	err := r.conn().Where("user_id = ? AND idempotency_key = ?", userID, key).First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *taskRepository) SetIdempotencyKeyTask(id uint, taskID uint) error {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	return r.conn().Model(&database.IdempotencyKey{}).
+		Where("id = ?", id).
+		Update("task_id", taskID).Error
+}
+
+func (r *taskRepository) DeleteIdempotencyKey(id uint) error {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	return r.conn().Delete(&database.IdempotencyKey{}, id).Error
+}
+
+func (r *taskRepository) CreateTaskDependencies(dependencies []database.TaskDependency) error {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	if len(dependencies) == 0 {
+		return nil
+	}
+	return r.conn().Create(&dependencies).Error
+}
+
+func (r *taskRepository) FindTaskDependencyIDs(taskID uint) ([]uint, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var taskIDs []uint
+	err := r.conn().Model(&database.TaskDependency{}).
+		Where("task_id = ?", taskID).
+		Pluck("depends_on_task_id", &taskIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return taskIDs, nil
+}
+
+func (r *taskRepository) FindDependentTaskIDs(taskID uint) ([]uint, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var taskIDs []uint
+	err := r.conn().Model(&database.TaskDependency{}).
+		Where("depends_on_task_id = ?", taskID).
+		Pluck("task_id", &taskIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return taskIDs, nil
+}
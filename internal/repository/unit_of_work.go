@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+)
+
+// Repositories are the repositories handed to a unit of work. Everything done
+// through them is part of the same transaction.
+type Repositories struct {
+	Tasks      TaskRepository
+	Audits     TaskAuditRepository
+	Results    ResultRepository
+	MapJobs    MapJobRepository
+	Workflows  WorkflowRepository
+	TaskGroups TaskGroupRepository
+}
+
+type UnitOfWork interface {
+	// Do runs fn in a transaction, which is committed if fn returns nil and
+	// rolled back otherwise.
+	Do(fn func(repos Repositories) error) error
+}
+
+type unitOfWork struct{}
+
+func NewUnitOfWork() UnitOfWork {
+	return &unitOfWork{}
+}
+
+func (u *unitOfWork) Do(fn func(repos Repositories) error) error {
+	if database.DB == nil {
+		return errors.New("database not initialized")
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(Repositories{
+			Tasks:      &taskRepository{tx: tx},
+			Audits:     &taskAuditRepository{tx: tx},
+			Results:    &resultRepository{tx: tx},
+			MapJobs:    &mapJobRepository{tx: tx},
+			Workflows:  &workflowRepository{tx: tx},
+			TaskGroups: &taskGroupRepository{tx: tx},
+		})
+	})
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"rainchanel.com/internal/config"
+	"rainchanel.com/internal/database"
+)
+
+func initTestDB(t *testing.T) {
+	host := os.Getenv("TEST_DB_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	port := 3306
+	if portStr := os.Getenv("TEST_DB_PORT"); portStr != "" {
+		if p, err := strconv.Atoi(portStr); err == nil {
+			port = p
+		}
+	}
+	user := os.Getenv("TEST_DB_USER")
+	if user == "" {
+		user = "root"
+	}
+	databaseName := os.Getenv("TEST_DB_NAME")
+	if databaseName == "" {
+		databaseName = "rainchanel_test"
+	}
+
+	if err := database.Init(config.DatabaseConfig{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Password: os.Getenv("TEST_DB_PASSWORD"),
+		Database: databaseName,
+	}); err != nil {
+		t.Skipf("Skipping test - could not connect to MySQL: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+}
+
+func TestUnitOfWork_Do(t *testing.T) {
+	initTestDB(t)
+
+	user := &database.User{Username: fmt.Sprintf("uow-test-%d", time.Now().UnixNano()), Password: "x"}
+	if err := database.DB.Create(user).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Where("created_by = ?", user.ID).Delete(&database.Task{})
+		database.DB.Delete(user)
+	})
+
+	countTasks := func() int64 {
+		var count int64
+		if err := database.DB.Model(&database.Task{}).Where("created_by = ?", user.ID).Count(&count).Error; err != nil {
+			t.Fatalf("Failed to count tasks: %v", err)
+		}
+		return count
+	}
+
+	t.Run("rolls back every write when fn fails", func(t *testing.T) {
+		errFailed := errors.New("failed")
+		err := NewUnitOfWork().Do(func(repos Repositories) error {
+			task := &database.Task{WasmModule: "AA==", Func: "add", Queue: database.DefaultQueue, CreatedBy: user.ID}
+			if err := repos.Tasks.CreateTask(task); err != nil {
+				return err
+			}
+			if err := repos.Audits.CreateTaskAudit(&database.TaskAudit{TaskID: task.ID, Status: database.TaskStatusPending, PublishedAt: time.Now()}); err != nil {
+				return err
+			}
+			return errFailed
+		})
+
+		if !errors.Is(err, errFailed) {
+			t.Fatalf("Do() error = %v, want %v", err, errFailed)
+		}
+		if count := countTasks(); count != 0 {
+			t.Errorf("found %d tasks after rollback, want 0", count)
+		}
+	})
+
+	t.Run("commits every write when fn succeeds", func(t *testing.T) {
+		err := NewUnitOfWork().Do(func(repos Repositories) error {
+			task := &database.Task{WasmModule: "AA==", Func: "add", Queue: database.DefaultQueue, CreatedBy: user.ID}
+			if err := repos.Tasks.CreateTask(task); err != nil {
+				return err
+			}
+			return repos.Audits.CreateTaskAudit(&database.TaskAudit{TaskID: task.ID, Status: database.TaskStatusPending, PublishedAt: time.Now()})
+		})
+
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if count := countTasks(); count != 1 {
+			t.Errorf("found %d tasks after commit, want 1", count)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+)
+
+type WorkflowRepository interface {
+	CreateWorkflow(workflow *database.Workflow) error
+	FindWorkflowByID(workflowID uint) (*database.Workflow, error)
+	FindWorkflowTasks(workflowID uint) ([]*database.TaskAudit, error)
+	FindWorkflowDependencies(workflowID uint) ([]database.TaskDependency, error)
+}
+
+type workflowRepository struct {
+	tx *gorm.DB
+}
+
+func NewWorkflowRepository() WorkflowRepository {
+	return &workflowRepository{}
+}
+
+func (r *workflowRepository) conn() *gorm.DB {
+	if r.tx != nil {
+		return r.tx
+	}
+	return database.DB
+}
+
+func (r *workflowRepository) CreateWorkflow(workflow *database.Workflow) error {
+	if r.conn() == nil {
+		return errors.New("database not initialized")
+	}
+	return r.conn().Create(workflow).Error
+}
+
+func (r *workflowRepository) FindWorkflowByID(workflowID uint) (*database.Workflow, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var workflow database.Workflow
+	err := r.conn().Where("id = ?", workflowID).First(&workflow).Error
+	if err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+func (r *workflowRepository) FindWorkflowTasks(workflowID uint) ([]*database.TaskAudit, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var audits []*database.TaskAudit
+	err := r.conn().Model(&database.TaskAudit{}).
+		Joins("JOIN tasks ON task_audit.task_id = tasks.id").
+		Where("tasks.workflow_id = ?", workflowID).
+		Preload("Task").
+		Order("task_audit.task_id ASC").
+		Find(&audits).Error
+	if err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+func (r *workflowRepository) FindWorkflowDependencies(workflowID uint) ([]database.TaskDependency, error) {
+	if r.conn() == nil {
+		return nil, errors.New("database not initialized")
+	}
+	var dependencies []database.TaskDependency
+	err := r.conn().Model(&database.TaskDependency{}).
+		Joins("JOIN tasks ON task_dependencies.task_id = tasks.id").
+		Where("tasks.workflow_id = ?", workflowID).
+		Find(&dependencies).Error
+	if err != nil {
+		return nil, err
+	}
+	return dependencies, nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
+)
+
+var ErrInvalidPurgeAge = errors.New("invalid purge age")
+
+// MaxDeadLetterRequeue bounds how many tasks one filtered requeue touches.
+const MaxDeadLetterRequeue = 1000
+
+type DeadLetterService interface {
+	ListDeadLetters(userID uint, filter dto.DeadLetterFilter, limit, offset int) ([]dto.DeadLetter, int64, error)
+	RequeueDeadLetter(taskID uint, userID uint) error
+	RequeueDeadLetters(userID uint, filter dto.DeadLetterFilter) (int, error)
+	PurgeDeadLetters(userID uint, filter dto.DeadLetterFilter, olderThan time.Duration) (int64, error)
+}
+
+type deadLetterService struct {
+	deadLetterRepo repository.DeadLetterRepository
+	taskService    TaskService
+}
+
+func NewDeadLetterService(taskService TaskService) DeadLetterService {
+	return &deadLetterService{
+		deadLetterRepo: repository.NewDeadLetterRepository(),
+		taskService:    taskService,
+	}
+}
+
+func NewDeadLetterServiceWithRepo(deadLetterRepo repository.DeadLetterRepository, taskService TaskService) DeadLetterService {
+	return &deadLetterService{
+		deadLetterRepo: deadLetterRepo,
+		taskService:    taskService,
+	}
+}
+
+func toRepositoryFilter(filter dto.DeadLetterFilter) repository.DeadLetterFilter {
+	return repository.DeadLetterFilter{
+		Func:          filter.Func,
+		Queue:         filter.Queue,
+		ErrorCode:     filter.ErrorCode,
+		ErrorContains: filter.ErrorContains,
+	}
+}
+
+func (s *deadLetterService) ListDeadLetters(userID uint, filter dto.DeadLetterFilter, limit, offset int) ([]dto.DeadLetter, int64, error) {
+	audits, total, err := s.deadLetterRepo.FindDeadLetters(userID, toRepositoryFilter(filter), limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find dead letters: %w", err)
+	}
+
+	taskIDs := make([]uint, 0, len(audits))
+	for _, audit := range audits {
+		taskIDs = append(taskIDs, audit.TaskID)
+	}
+	failures, err := s.deadLetterRepo.FindTaskFailures(taskIDs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find task failures: %w", err)
+	}
+	history := make(map[uint][]dto.TaskFailure, len(audits))
+	for _, failure := range failures {
+		history[failure.TaskID] = append(history[failure.TaskID], dto.TaskFailure{
+			Attempt:   failure.Attempt,
+			Code:      failure.Code,
+			ErrorMsg:  failure.ErrorMsg,
+			Retryable: failure.Retryable == nil || *failure.Retryable,
+			TrapKind:  failure.TrapKind,
+			Details:   failure.Details,
+			WorkerID:  failure.WorkerID,
+			FailedAt:  failure.CreatedAt,
+		})
+	}
+
+	deadLetters := make([]dto.DeadLetter, 0, len(audits))
+	for _, audit := range audits {
+		var args interface{}
+		if audit.Task.Args != "" {
+			if err := json.Unmarshal([]byte(audit.Task.Args), &args); err != nil {
+				return nil, 0, fmt.Errorf("failed to unmarshal task args: %w", err)
+			}
+		}
+		deadLetter := dto.DeadLetter{
+			Task: dto.Task{
+				ID:        audit.Task.ID,
+				Func:      audit.Task.Func,
+				Args:      args,
+				Queue:     audit.Task.Queue,
+				Priority:  audit.Task.Priority,
+				CreatedBy: audit.Task.CreatedBy,
+			},
+			ErrorCode:  audit.ErrorCode,
+			ErrorMsg:   audit.ErrorMsg,
+			RetryCount: audit.RetryCount,
+			FailedAt:   audit.UpdatedAt,
+			Failures:   history[audit.TaskID],
+		}
+		if deadLetter.Failures == nil {
+			deadLetter.Failures = []dto.TaskFailure{}
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, total, nil
+}
+
+func (s *deadLetterService) RequeueDeadLetter(taskID uint, userID uint) error {
+	return s.taskService.RequeueTask(taskID, userID)
+}
+
+// RequeueDeadLetters requeues matching tasks oldest first, so the tasks of a
+// workflow are back in place before the tasks that depend on them.
+func (s *deadLetterService) RequeueDeadLetters(userID uint, filter dto.DeadLetterFilter) (int, error) {
+	taskIDs, err := s.deadLetterRepo.FindDeadLetterTaskIDs(userID, toRepositoryFilter(filter), MaxDeadLetterRequeue)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find dead letters: %w", err)
+	}
+
+	requeued := 0
+	for _, taskID := range taskIDs {
+		if err := s.taskService.RequeueTask(taskID, userID); err != nil {
+			if !errors.Is(err, ErrTaskNotFailed) {
+				logrus.WithFields(logrus.Fields{
+					"task_id": taskID,
+					"error":   err.Error(),
+				}).Warn("Failed to requeue dead letter")
+			}
+			continue
+		}
+		requeued++
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"matched":  len(taskIDs),
+		"requeued": requeued,
+	}).Info("Dead letters requeued")
+
+	return requeued, nil
+}
+
+func (s *deadLetterService) PurgeDeadLetters(userID uint, filter dto.DeadLetterFilter, olderThan time.Duration) (int64, error) {
+	if olderThan <= 0 {
+		return 0, fmt.Errorf("%w: older_than must be positive", ErrInvalidPurgeAge)
+	}
+
+	purged, err := s.deadLetterRepo.PurgeDeadLetters(userID, toRepositoryFilter(filter), time.Now().Add(-olderThan))
+	if err != nil {
+		return purged, fmt.Errorf("failed to purge dead letters: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"older_than": olderThan.String(),
+		"purged":     purged,
+	}).Info("Dead letters purged")
+
+	return purged, nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
+)
+
+func TestDeadLetterService_ListDeadLetters(t *testing.T) {
+	failedAt := time.Now()
+	deadLetterRepo := &MockDeadLetterRepository{
+		FindDeadLettersFunc: func(userID uint, filter repository.DeadLetterFilter, limit, offset int) ([]*database.TaskAudit, int64, error) {
+			assert.Equal(t, repository.DeadLetterFilter{Func: "add", ErrorContains: "trap"}, filter)
+			return []*database.TaskAudit{
+				{TaskID: 5, Status: database.TaskStatusFailed, ErrorMsg: "trap", RetryCount: 3, UpdatedAt: failedAt, Task: database.Task{ID: 5, Func: "add", Args: `[1,2]`}},
+				{TaskID: 6, Status: database.TaskStatusFailed, ErrorMsg: "Dependency task 5 failed", Task: database.Task{ID: 6, Func: "add", Args: `[3,4]`}},
+			}, 2, nil
+		},
+		FindTaskFailuresFunc: func(taskIDs []uint) ([]database.TaskFailure, error) {
+			assert.Equal(t, []uint{5, 6}, taskIDs)
+			return []database.TaskFailure{
+				{TaskID: 5, Attempt: 1, ErrorMsg: "trap"},
+				{TaskID: 5, Attempt: 2, ErrorMsg: "trap"},
+			}, nil
+		},
+	}
+	service := NewDeadLetterServiceWithRepo(deadLetterRepo, &MockTaskServiceForStale{})
+
+	deadLetters, total, err := service.ListDeadLetters(1, dto.DeadLetterFilter{Func: "add", ErrorContains: "trap"}, 50, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, []interface{}{float64(1), float64(2)}, deadLetters[0].Task.Args)
+	assert.Equal(t, failedAt, deadLetters[0].FailedAt)
+	assert.Len(t, deadLetters[0].Failures, 2)
+	assert.Empty(t, deadLetters[1].Failures)
+	assert.NotNil(t, deadLetters[1].Failures)
+}
+
+func TestDeadLetterService_RequeueDeadLetters(t *testing.T) {
+	deadLetterRepo := &MockDeadLetterRepository{
+		FindDeadLetterTaskIDsFunc: func(userID uint, filter repository.DeadLetterFilter, limit int) ([]uint, error) {
+			assert.Equal(t, MaxDeadLetterRequeue, limit)
+			return []uint{4, 5, 6}, nil
+		},
+	}
+	var attempted []uint
+	taskService := &MockTaskServiceForStale{
+		RequeueTaskFunc: func(taskID uint, userID uint) error {
+			attempted = append(attempted, taskID)
+			switch taskID {
+			case 5:
+				return ErrTaskNotFailed
+			case 6:
+				return errors.New("database error")
+			}
+			return nil
+		},
+	}
+	service := NewDeadLetterServiceWithRepo(deadLetterRepo, taskService)
+
+	requeued, err := service.RequeueDeadLetters(1, dto.DeadLetterFilter{Func: "add"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requeued)
+	assert.Equal(t, []uint{4, 5, 6}, attempted)
+}
+
+func TestDeadLetterService_PurgeDeadLetters(t *testing.T) {
+	t.Run("rejects a non-positive age", func(t *testing.T) {
+		deadLetterRepo := &MockDeadLetterRepository{
+			PurgeDeadLettersFunc: func(userID uint, filter repository.DeadLetterFilter, failedBefore time.Time) (int64, error) {
+				t.Fatal("nothing should be purged")
+				return 0, nil
+			},
+		}
+		service := NewDeadLetterServiceWithRepo(deadLetterRepo, &MockTaskServiceForStale{})
+
+		_, err := service.PurgeDeadLetters(1, dto.DeadLetterFilter{}, 0)
+
+		assert.ErrorIs(t, err, ErrInvalidPurgeAge)
+	})
+
+	t.Run("purges dead letters older than the cutoff", func(t *testing.T) {
+		deadLetterRepo := &MockDeadLetterRepository{
+			PurgeDeadLettersFunc: func(userID uint, filter repository.DeadLetterFilter, failedBefore time.Time) (int64, error) {
+				assert.WithinDuration(t, time.Now().Add(-72*time.Hour), failedBefore, time.Minute)
+				return 12, nil
+			},
+		}
+		service := NewDeadLetterServiceWithRepo(deadLetterRepo, &MockTaskServiceForStale{})
+
+		purged, err := service.PurgeDeadLetters(1, dto.DeadLetterFilter{}, 72*time.Hour)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(12), purged)
+	})
+}
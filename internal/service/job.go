@@ -0,0 +1,29 @@
+package service
+
+import "rainchanel.com/internal/database"
+
+const (
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// jobStatus summarises the tasks of a workflow or map job: it is running until
+// every task has finished, and then reports the worst outcome among them.
+func jobStatus(counts map[string]int) string {
+	switch {
+	case counts[string(database.TaskStatusPending)]+
+		counts[string(database.TaskStatusProcessing)]+
+		counts[string(database.TaskStatusBlocked)] > 0:
+		return JobStatusRunning
+	case counts[string(database.TaskStatusFailed)]+
+		counts[string(database.TaskStatusDeadlineExceeded)]+
+		counts[string(database.TaskStatusExpired)] > 0:
+		return JobStatusFailed
+	case counts[string(database.TaskStatusCancelled)] > 0:
+		return JobStatusCancelled
+	default:
+		return JobStatusCompleted
+	}
+}
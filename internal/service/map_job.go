@@ -0,0 +1,218 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
+	"rainchanel.com/internal/validation"
+)
+
+var ErrMapJobNotFound = errors.New("map job not found")
+var ErrMapJobAccessDenied = errors.New("map job does not belong to user")
+var ErrMapJobNotFinished = errors.New("map job has unfinished tasks")
+var ErrInvalidMapJob = errors.New("invalid map job")
+
+const MaxMapJobTasks = 10000
+
+type MapJobService interface {
+	CreateMapJob(job dto.MapJob, createdBy uint) (*dto.MapJob, []uint, error)
+	GetMapJob(jobID uint, userID uint) (*dto.MapJob, error)
+	GetMapJobResults(jobID uint, userID uint) ([]dto.MapJobResult, error)
+}
+
+type mapJobService struct {
+	mapJobRepo  repository.MapJobRepository
+	taskService TaskService
+}
+
+func NewMapJobService(taskService TaskService) MapJobService {
+	return &mapJobService{
+		mapJobRepo:  repository.NewMapJobRepository(),
+		taskService: taskService,
+	}
+}
+
+func NewMapJobServiceWithRepo(mapJobRepo repository.MapJobRepository, taskService TaskService) MapJobService {
+	return &mapJobService{
+		mapJobRepo:  mapJobRepo,
+		taskService: taskService,
+	}
+}
+
+func (s *mapJobService) CreateMapJob(job dto.MapJob, createdBy uint) (*dto.MapJob, []uint, error) {
+	if len(job.Args) == 0 || len(job.Args) > MaxMapJobTasks {
+		return nil, nil, fmt.Errorf("%w: args must contain between 1 and %d argument sets", ErrInvalidMapJob, MaxMapJobTasks)
+	}
+
+	template := dto.Task{
+		Func:           job.Func,
+		Queue:          job.Queue,
+		Priority:       job.Priority,
+		Retry:          job.Retry,
+		TimeoutSeconds: job.TimeoutSeconds,
+		Deadline:       job.Deadline,
+		TTLSeconds:     job.TTLSeconds,
+	}
+	if err := validateTaskPlacement(&template); err != nil {
+		return nil, nil, err
+	}
+	if err := validation.ValidateTasks(job.WasmModule, job.Func, job.Args); err != nil {
+		return nil, nil, fmt.Errorf("task validation failed: %w", err)
+	}
+
+	dbJob := &database.MapJob{
+		WasmModule: job.WasmModule,
+		Func:       job.Func,
+		Queue:      template.Queue,
+		Priority:   template.Priority,
+		TaskCount:  len(job.Args),
+		CreatedBy:  createdBy,
+	}
+	var taskIDs []uint
+	err := s.taskService.PublishTaskBatch(createdBy, func(repos repository.Repositories, publish TaskPublisher) error {
+		if err := repos.MapJobs.CreateMapJob(dbJob); err != nil {
+			return fmt.Errorf("failed to create map job in database: %w", err)
+		}
+
+		tasks := make([]dto.Task, 0, len(job.Args))
+		opts := make([]dto.PublishOptions, 0, len(job.Args))
+		for i, args := range job.Args {
+			task := template
+			task.Args = args
+			tasks = append(tasks, task)
+			opts = append(opts, dto.PublishOptions{MapJobID: &dbJob.ID, MapIndex: i})
+		}
+		var err error
+		taskIDs, err = publish(tasks, opts)
+		if err != nil {
+			return fmt.Errorf("failed to publish map job tasks: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"map_job_id": dbJob.ID,
+		"user_id":    createdBy,
+		"tasks":      len(taskIDs),
+	}).Info("Map job created")
+
+	return &dto.MapJob{
+		ID:        dbJob.ID,
+		Func:      dbJob.Func,
+		Queue:     dbJob.Queue,
+		Priority:  dbJob.Priority,
+		TaskCount: dbJob.TaskCount,
+		Status:    JobStatusRunning,
+		CreatedBy: createdBy,
+		CreatedAt: &dbJob.CreatedAt,
+	}, taskIDs, nil
+}
+
+func (s *mapJobService) GetMapJob(jobID uint, userID uint) (*dto.MapJob, error) {
+	dbJob, err := s.findOwnedMapJob(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.mapJobCounts(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.MapJob{
+		ID:        dbJob.ID,
+		Func:      dbJob.Func,
+		Queue:     dbJob.Queue,
+		Priority:  dbJob.Priority,
+		TaskCount: dbJob.TaskCount,
+		Status:    jobStatus(counts),
+		Counts:    counts,
+		CreatedBy: dbJob.CreatedBy,
+		CreatedAt: &dbJob.CreatedAt,
+	}, nil
+}
+
+func (s *mapJobService) GetMapJobResults(jobID uint, userID uint) ([]dto.MapJobResult, error) {
+	if _, err := s.findOwnedMapJob(jobID, userID); err != nil {
+		return nil, err
+	}
+
+	counts, err := s.mapJobCounts(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if jobStatus(counts) == JobStatusRunning {
+		return nil, ErrMapJobNotFinished
+	}
+
+	audits, err := s.mapJobRepo.FindMapJobTasks(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find map job tasks: %w", err)
+	}
+	dbResults, err := s.mapJobRepo.FindMapJobResults(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find map job results: %w", err)
+	}
+
+	resultsByTask := make(map[uint]string, len(dbResults))
+	for _, dbResult := range dbResults {
+		if _, exists := resultsByTask[dbResult.TaskID]; !exists {
+			resultsByTask[dbResult.TaskID] = dbResult.Result
+		}
+	}
+
+	results := make([]dto.MapJobResult, 0, len(audits))
+	for _, audit := range audits {
+		result := dto.MapJobResult{
+			Index:    audit.Task.MapIndex,
+			TaskID:   audit.TaskID,
+			Status:   string(audit.Status),
+			ErrorMsg: audit.ErrorMsg,
+		}
+		if resultJSON, ok := resultsByTask[audit.TaskID]; ok {
+			if err := json.Unmarshal([]byte(resultJSON), &result.Result); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal result data: %w", err)
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s *mapJobService) mapJobCounts(jobID uint) (map[string]int, error) {
+	dbCounts, err := s.mapJobRepo.CountMapJobTasks(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count map job tasks: %w", err)
+	}
+	counts := make(map[string]int, len(dbCounts))
+	for status, count := range dbCounts {
+		counts[status] = int(count)
+	}
+	return counts, nil
+}
+
+func (s *mapJobService) findOwnedMapJob(jobID uint, userID uint) (*database.MapJob, error) {
+	dbJob, err := s.mapJobRepo.FindMapJobByID(jobID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMapJobNotFound
+		}
+		return nil, fmt.Errorf("failed to find map job: %w", err)
+	}
+
+	if dbJob.CreatedBy != userID {
+		return nil, ErrMapJobAccessDenied
+	}
+
+	return dbJob, nil
+}
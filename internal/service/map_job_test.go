@@ -0,0 +1,206 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
+)
+
+func TestMapJobService_CreateMapJob(t *testing.T) {
+	job := dto.MapJob{
+		WasmModule: testWasmModule,
+		Func:       "add",
+		Args:       []any{[]int{1, 2}, []int{3, 4}, []int{5, 6}},
+		Queue:      "sweeps",
+	}
+
+	t.Run("stores the job and one task per argument set together", func(t *testing.T) {
+		var stored *database.MapJob
+		mapJobRepo := &MockMapJobRepository{
+			CreateMapJobFunc: func(job *database.MapJob) error {
+				job.ID = 5
+				stored = job
+				return nil
+			},
+		}
+		var indexes []int
+		taskRepo := &MockTaskRepository{
+			CreateTaskFunc: func(task *database.Task) error {
+				t.Error("map job tasks should be inserted in batches")
+				return nil
+			},
+			CreateTasksFunc: func(tasks []*database.Task) error {
+				for i, task := range tasks {
+					assert.Empty(t, task.WasmModule)
+					assert.Equal(t, "sweeps", task.Queue)
+					assert.Equal(t, uint(5), *task.MapJobID)
+					indexes = append(indexes, task.MapIndex)
+					task.ID = uint(101 + i)
+				}
+				return nil
+			},
+		}
+		var auditTaskIDs []uint
+		auditRepo := &MockTaskAuditRepository{
+			CreateTaskAuditsFunc: func(audits []*database.TaskAudit) error {
+				for _, audit := range audits {
+					auditTaskIDs = append(auditTaskIDs, audit.TaskID)
+				}
+				return nil
+			},
+		}
+		uowCalls := 0
+		uow := &MockUnitOfWork{DoFunc: func(fn func(repos repository.Repositories) error) error {
+			uowCalls++
+			return fn(repository.Repositories{Tasks: taskRepo, Audits: auditRepo, MapJobs: mapJobRepo})
+		}}
+		taskService := NewTaskServiceWithRepos(taskRepo, auditRepo, &MockResultRepository{}, uow)
+		service := NewMapJobServiceWithRepo(&MockMapJobRepository{}, taskService)
+
+		created, taskIDs, err := service.CreateMapJob(job, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, uowCalls)
+		assert.Equal(t, testWasmModule, stored.WasmModule)
+		assert.Equal(t, 3, stored.TaskCount)
+		assert.Equal(t, []int{0, 1, 2}, indexes)
+		assert.Equal(t, []uint{101, 102, 103}, taskIDs)
+		assert.Equal(t, []uint{101, 102, 103}, auditTaskIDs)
+		assert.Equal(t, uint(5), created.ID)
+		assert.Equal(t, JobStatusRunning, created.Status)
+	})
+
+	t.Run("rejects an argument set that does not match the signature", func(t *testing.T) {
+		invalid := job
+		invalid.Args = []any{[]int{1, 2}, []int{3}}
+		mapJobRepo := &MockMapJobRepository{
+			CreateMapJobFunc: func(job *database.MapJob) error {
+				t.Fatal("map job should not be created")
+				return nil
+			},
+		}
+		service := NewMapJobServiceWithRepo(mapJobRepo, &MockTaskServiceForStale{})
+
+		created, _, err := service.CreateMapJob(invalid, 1)
+
+		assert.ErrorContains(t, err, "argument set 1")
+		assert.Nil(t, created)
+	})
+
+	t.Run("rejects an empty job", func(t *testing.T) {
+		empty := job
+		empty.Args = nil
+		service := NewMapJobServiceWithRepo(&MockMapJobRepository{}, &MockTaskServiceForStale{})
+
+		_, _, err := service.CreateMapJob(empty, 1)
+
+		assert.ErrorIs(t, err, ErrInvalidMapJob)
+	})
+
+	t.Run("fails the whole job when its tasks cannot be stored", func(t *testing.T) {
+		mapJobRepo := &MockMapJobRepository{
+			CreateMapJobFunc: func(job *database.MapJob) error {
+				job.ID = 5
+				return nil
+			},
+		}
+		auditRepo := &MockTaskAuditRepository{
+			CreateTaskAuditsFunc: func(audits []*database.TaskAudit) error {
+				return errors.New("database error")
+			},
+		}
+		var uowErr error
+		uow := &MockUnitOfWork{DoFunc: func(fn func(repos repository.Repositories) error) error {
+			uowErr = fn(repository.Repositories{Tasks: &MockTaskRepository{}, Audits: auditRepo, MapJobs: mapJobRepo})
+			return uowErr
+		}}
+		taskService := NewTaskServiceWithRepos(&MockTaskRepository{}, auditRepo, &MockResultRepository{}, uow)
+		service := NewMapJobServiceWithRepo(&MockMapJobRepository{}, taskService)
+
+		created, _, err := service.CreateMapJob(job, 1)
+
+		assert.ErrorContains(t, err, "failed to publish map job tasks")
+		assert.ErrorContains(t, uowErr, "database error", "the unit of work should roll the job back")
+		assert.Nil(t, created)
+	})
+}
+
+func TestMapJobService_GetMapJobResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  uint
+		findErr error
+		counts  map[string]int64
+		wantErr error
+	}{
+		{
+			name:    "not found",
+			userID:  1,
+			findErr: gorm.ErrRecordNotFound,
+			wantErr: ErrMapJobNotFound,
+		},
+		{
+			name:    "access denied",
+			userID:  2,
+			wantErr: ErrMapJobAccessDenied,
+		},
+		{
+			name:    "not finished",
+			userID:  1,
+			counts:  map[string]int64{"completed": 1, "pending": 1},
+			wantErr: ErrMapJobNotFinished,
+		},
+		{
+			name:   "finished",
+			userID: 1,
+			counts: map[string]int64{"completed": 1, "failed": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapJobRepo := &MockMapJobRepository{
+				FindMapJobByIDFunc: func(jobID uint) (*database.MapJob, error) {
+					if tt.findErr != nil {
+						return nil, tt.findErr
+					}
+					return &database.MapJob{ID: jobID, CreatedBy: 1, TaskCount: 2}, nil
+				},
+				CountMapJobTasksFunc: func(jobID uint) (map[string]int64, error) {
+					return tt.counts, nil
+				},
+				FindMapJobTasksFunc: func(jobID uint) ([]*database.TaskAudit, error) {
+					return []*database.TaskAudit{
+						{TaskID: 20, Status: database.TaskStatusFailed, ErrorMsg: "trap", Task: database.Task{ID: 20, MapIndex: 0}},
+						{TaskID: 21, Status: database.TaskStatusCompleted, Task: database.Task{ID: 21, MapIndex: 1}},
+					}, nil
+				},
+				FindMapJobResultsFunc: func(jobID uint) ([]database.Result, error) {
+					return []database.Result{
+						{TaskID: 21, Result: `7`},
+						{TaskID: 21, Result: `8`},
+					}, nil
+				},
+			}
+			service := NewMapJobServiceWithRepo(mapJobRepo, &MockTaskServiceForStale{})
+
+			results, err := service.GetMapJobResults(4, tt.userID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, results)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []dto.MapJobResult{
+				{Index: 0, TaskID: 20, Status: "failed", ErrorMsg: "trap"},
+				{Index: 1, TaskID: 21, Status: "completed", Result: float64(7)},
+			}, results)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/repository"
 )
 
 type MockUserRepository struct {
@@ -26,8 +27,16 @@ func (m *MockUserRepository) Create(user *database.User) error {
 }
 
 type MockTaskRepository struct {
-	CreateTaskFunc   func(task *database.Task) error
-	FindTaskByIDFunc func(taskID uint) (*database.Task, error)
+	CreateTaskFunc             func(task *database.Task) error
+	CreateTasksFunc            func(tasks []*database.Task) error
+	FindTaskByIDFunc           func(taskID uint) (*database.Task, error)
+	CreateIdempotencyKeyFunc   func(key *database.IdempotencyKey) error
+	FindIdempotencyKeyFunc     func(userID uint, key string) (*database.IdempotencyKey, error)
+	SetIdempotencyKeyTaskFunc  func(id uint, taskID uint) error
+	DeleteIdempotencyKeyFunc   func(id uint) error
+	CreateTaskDependenciesFunc func(dependencies []database.TaskDependency) error
+	FindTaskDependencyIDsFunc  func(taskID uint) ([]uint, error)
+	FindDependentTaskIDsFunc   func(taskID uint) ([]uint, error)
 }
 
 func (m *MockTaskRepository) CreateTask(task *database.Task) error {
@@ -37,6 +46,13 @@ func (m *MockTaskRepository) CreateTask(task *database.Task) error {
 	return nil
 }
 
+func (m *MockTaskRepository) CreateTasks(tasks []*database.Task) error {
+	if m.CreateTasksFunc != nil {
+		return m.CreateTasksFunc(tasks)
+	}
+	return nil
+}
+
 func (m *MockTaskRepository) FindTaskByID(taskID uint) (*database.Task, error) {
 	if m.FindTaskByIDFunc != nil {
 		return m.FindTaskByIDFunc(taskID)
@@ -44,26 +60,87 @@ func (m *MockTaskRepository) FindTaskByID(taskID uint) (*database.Task, error) {
 	return nil, nil
 }
 
+func (m *MockTaskRepository) CreateIdempotencyKey(key *database.IdempotencyKey) error {
+	if m.CreateIdempotencyKeyFunc != nil {
+		return m.CreateIdempotencyKeyFunc(key)
+	}
+	return nil
+}
+
+func (m *MockTaskRepository) FindIdempotencyKey(userID uint, key string) (*database.IdempotencyKey, error) {
+	if m.FindIdempotencyKeyFunc != nil {
+		return m.FindIdempotencyKeyFunc(userID, key)
+	}
+	return nil, nil
+}
+
+func (m *MockTaskRepository) SetIdempotencyKeyTask(id uint, taskID uint) error {
+	if m.SetIdempotencyKeyTaskFunc != nil {
+		return m.SetIdempotencyKeyTaskFunc(id, taskID)
+	}
+	return nil
+}
+
+func (m *MockTaskRepository) DeleteIdempotencyKey(id uint) error {
+	if m.DeleteIdempotencyKeyFunc != nil {
+		return m.DeleteIdempotencyKeyFunc(id)
+	}
+	return nil
+}
+
+func (m *MockTaskRepository) CreateTaskDependencies(dependencies []database.TaskDependency) error {
+	if m.CreateTaskDependenciesFunc != nil {
+		return m.CreateTaskDependenciesFunc(dependencies)
+	}
+	return nil
+}
+
+func (m *MockTaskRepository) FindTaskDependencyIDs(taskID uint) ([]uint, error) {
+	if m.FindTaskDependencyIDsFunc != nil {
+		return m.FindTaskDependencyIDsFunc(taskID)
+	}
+	return nil, nil
+}
+
+func (m *MockTaskRepository) FindDependentTaskIDs(taskID uint) ([]uint, error) {
+	if m.FindDependentTaskIDsFunc != nil {
+		return m.FindDependentTaskIDsFunc(taskID)
+	}
+	return nil, nil
+}
+
 type MockTaskAuditRepository struct {
-	CreateTaskAuditFunc          func(audit *database.TaskAudit) error
-	FindTaskAuditByTaskIDFunc    func(taskID uint) (*database.TaskAudit, error)
-	UpdateTaskAuditStatusFunc    func(taskID uint, status database.TaskStatus) error
-	UpdateTaskAuditConsumedFunc  func(taskID uint) error
-	UpdateTaskAuditCompletedFunc func(taskID uint, processedBy uint) error
-	FindAndClaimPendingTaskFunc  func() (*database.TaskAudit, error)
-	FindStaleTasksFunc           func(timeoutDuration time.Duration) ([]*database.TaskAudit, error)
-	ReclaimStaleTaskFunc         func(taskID uint, errorMsg string) error
-	UpdateTaskFailedFunc         func(taskID uint, errorMsg string) error
-	GetTaskStatisticsFunc        func() (map[string]int64, error)
-	GetEnhancedStatisticsFunc    func() (map[string]interface{}, error)
-	FindTasksWithPaginationFunc  func(limit, offset int, status *database.TaskStatus) ([]*database.TaskAudit, int64, error)
-	GetRecentActivityFunc        func(hours int) (map[string]int64, error)
-	GetErrorBreakdownFunc        func(limit int) ([]map[string]interface{}, error)
-	GetUserStatisticsFunc        func(userID uint) (map[string]int64, error)
-	GetUserEnhancedStatisticsFunc func(userID uint) (map[string]interface{}, error)
+	CreateTaskAuditFunc             func(audit *database.TaskAudit) error
+	CreateTaskAuditsFunc            func(audits []*database.TaskAudit) error
+	FindTaskAuditByTaskIDFunc       func(taskID uint) (*database.TaskAudit, error)
+	UpdateTaskAuditCompletedFunc    func(taskID uint, processedBy uint, claimToken string) error
+	FindAndClaimPendingTasksFunc    func(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error)
+	FindStaleTasksFunc              func(timeoutDuration time.Duration) ([]*database.TaskAudit, error)
+	FindOverdueTasksFunc            func(now time.Time) ([]*database.TaskAudit, error)
+	MarkDeadlineExceededFunc        func(taskID uint, errorMsg string) (bool, error)
+	FindExpiredTasksFunc            func(now time.Time) ([]*database.TaskAudit, error)
+	ExpireTaskFunc                  func(taskID uint, errorMsg string) (bool, error)
+	ReclaimStaleTaskFunc            func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error
+	ExtendLeaseFunc                 func(taskID uint, claimToken string, leaseExpiresAt time.Time) (bool, error)
+	ReleaseTaskFunc                 func(taskID uint, claimToken string, nextAttemptAt *time.Time, reason string) error
+	UpdateTaskFailedFunc            func(taskID uint, claimToken string, errorCode string, errorMsg string) error
+	CancelTaskFunc                  func(taskID uint) (bool, error)
+	FindTaskAuditsByTaskIDsFunc     func(taskIDs []uint) ([]*database.TaskAudit, error)
+	UnblockTaskFunc                 func(taskID uint, effectiveAt time.Time, expiresAt *time.Time) (bool, error)
+	FailBlockedTaskFunc             func(taskID uint, errorCode string, errorMsg string) (bool, error)
+	RequeueFailedTaskFunc           func(taskID uint, status database.TaskStatus, effectiveAt time.Time, expiresAt *time.Time) (bool, error)
+	CreateTaskFailureFunc           func(failure *database.TaskFailure) error
+	GetTaskStatisticsFunc           func() (map[string]int64, error)
+	GetQueueStatisticsFunc          func() (map[string]map[string]int64, error)
+	GetEnhancedStatisticsFunc       func() (map[string]interface{}, error)
+	FindTasksWithPaginationFunc     func(limit, offset int, status *database.TaskStatus) ([]*database.TaskAudit, int64, error)
+	GetRecentActivityFunc           func(hours int) (map[string]int64, error)
+	GetErrorBreakdownFunc           func(limit int) ([]map[string]interface{}, error)
+	GetUserStatisticsFunc           func(userID uint) (map[string]int64, error)
+	GetUserEnhancedStatisticsFunc   func(userID uint) (map[string]interface{}, error)
 	FindUserTasksWithPaginationFunc func(userID uint, limit, offset int, status *database.TaskStatus) ([]*database.TaskAudit, int64, error)
-	GetUserRecentActivityFunc    func(userID uint, hours int) (map[string]int64, error)
-	GetUserErrorBreakdownFunc    func(userID uint, limit int) ([]map[string]interface{}, error)
+	GetUserRecentActivityFunc       func(userID uint, hours int) (map[string]int64, error)
+	GetUserErrorBreakdownFunc       func(userID uint, limit int) ([]map[string]interface{}, error)
 }
 
 func (m *MockTaskAuditRepository) CreateTaskAudit(audit *database.TaskAudit) error {
@@ -73,6 +150,13 @@ func (m *MockTaskAuditRepository) CreateTaskAudit(audit *database.TaskAudit) err
 	return nil
 }
 
+func (m *MockTaskAuditRepository) CreateTaskAudits(audits []*database.TaskAudit) error {
+	if m.CreateTaskAuditsFunc != nil {
+		return m.CreateTaskAuditsFunc(audits)
+	}
+	return nil
+}
+
 func (m *MockTaskAuditRepository) FindTaskAuditByTaskID(taskID uint) (*database.TaskAudit, error) {
 	if m.FindTaskAuditByTaskIDFunc != nil {
 		return m.FindTaskAuditByTaskIDFunc(taskID)
@@ -80,55 +164,90 @@ func (m *MockTaskAuditRepository) FindTaskAuditByTaskID(taskID uint) (*database.
 	return nil, nil
 }
 
-func (m *MockTaskAuditRepository) UpdateTaskAuditStatus(taskID uint, status database.TaskStatus) error {
-	if m.UpdateTaskAuditStatusFunc != nil {
-		return m.UpdateTaskAuditStatusFunc(taskID, status)
+func (m *MockTaskAuditRepository) UpdateTaskAuditCompleted(taskID uint, processedBy uint, claimToken string) error {
+	if m.UpdateTaskAuditCompletedFunc != nil {
+		return m.UpdateTaskAuditCompletedFunc(taskID, processedBy, claimToken)
 	}
 	return nil
 }
 
-func (m *MockTaskAuditRepository) UpdateTaskAuditConsumed(taskID uint) error {
-	if m.UpdateTaskAuditConsumedFunc != nil {
-		return m.UpdateTaskAuditConsumedFunc(taskID)
+func (m *MockTaskAuditRepository) FindAndClaimPendingTasks(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
+	if m.FindAndClaimPendingTasksFunc != nil {
+		return m.FindAndClaimPendingTasksFunc(queues, workerID, claimTokens, leaseDuration)
 	}
-	return nil
+	return nil, nil
 }
 
-func (m *MockTaskAuditRepository) UpdateTaskAuditCompleted(taskID uint, processedBy uint) error {
-	if m.UpdateTaskAuditCompletedFunc != nil {
-		return m.UpdateTaskAuditCompletedFunc(taskID, processedBy)
+func (m *MockTaskAuditRepository) FindStaleTasks(timeoutDuration time.Duration) ([]*database.TaskAudit, error) {
+	if m.FindStaleTasksFunc != nil {
+		return m.FindStaleTasksFunc(timeoutDuration)
 	}
-	return nil
+	return nil, nil
 }
 
-func (m *MockTaskAuditRepository) FindAndClaimPendingTask() (*database.TaskAudit, error) {
-	if m.FindAndClaimPendingTaskFunc != nil {
-		return m.FindAndClaimPendingTaskFunc()
+func (m *MockTaskAuditRepository) FindOverdueTasks(now time.Time) ([]*database.TaskAudit, error) {
+	if m.FindOverdueTasksFunc != nil {
+		return m.FindOverdueTasksFunc(now)
 	}
 	return nil, nil
 }
 
-func (m *MockTaskAuditRepository) FindStaleTasks(timeoutDuration time.Duration) ([]*database.TaskAudit, error) {
-	if m.FindStaleTasksFunc != nil {
-		return m.FindStaleTasksFunc(timeoutDuration)
+func (m *MockTaskAuditRepository) MarkDeadlineExceeded(taskID uint, errorMsg string) (bool, error) {
+	if m.MarkDeadlineExceededFunc != nil {
+		return m.MarkDeadlineExceededFunc(taskID, errorMsg)
+	}
+	return false, nil
+}
+
+func (m *MockTaskAuditRepository) FindExpiredTasks(now time.Time) ([]*database.TaskAudit, error) {
+	if m.FindExpiredTasksFunc != nil {
+		return m.FindExpiredTasksFunc(now)
 	}
 	return nil, nil
 }
 
-func (m *MockTaskAuditRepository) ReclaimStaleTask(taskID uint, errorMsg string) error {
+func (m *MockTaskAuditRepository) ExpireTask(taskID uint, errorMsg string) (bool, error) {
+	if m.ExpireTaskFunc != nil {
+		return m.ExpireTaskFunc(taskID, errorMsg)
+	}
+	return false, nil
+}
+
+func (m *MockTaskAuditRepository) ReclaimStaleTask(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
 	if m.ReclaimStaleTaskFunc != nil {
-		return m.ReclaimStaleTaskFunc(taskID, errorMsg)
+		return m.ReclaimStaleTaskFunc(taskID, claimToken, errorCode, errorMsg, nextAttemptAt)
+	}
+	return nil
+}
+
+func (m *MockTaskAuditRepository) ExtendLease(taskID uint, claimToken string, leaseExpiresAt time.Time) (bool, error) {
+	if m.ExtendLeaseFunc != nil {
+		return m.ExtendLeaseFunc(taskID, claimToken, leaseExpiresAt)
+	}
+	return false, nil
+}
+
+func (m *MockTaskAuditRepository) ReleaseTask(taskID uint, claimToken string, nextAttemptAt *time.Time, reason string) error {
+	if m.ReleaseTaskFunc != nil {
+		return m.ReleaseTaskFunc(taskID, claimToken, nextAttemptAt, reason)
 	}
 	return nil
 }
 
-func (m *MockTaskAuditRepository) UpdateTaskFailed(taskID uint, errorMsg string) error {
+func (m *MockTaskAuditRepository) UpdateTaskFailed(taskID uint, claimToken string, errorCode string, errorMsg string) error {
 	if m.UpdateTaskFailedFunc != nil {
-		return m.UpdateTaskFailedFunc(taskID, errorMsg)
+		return m.UpdateTaskFailedFunc(taskID, claimToken, errorCode, errorMsg)
 	}
 	return nil
 }
 
+func (m *MockTaskAuditRepository) CancelTask(taskID uint) (bool, error) {
+	if m.CancelTaskFunc != nil {
+		return m.CancelTaskFunc(taskID)
+	}
+	return false, nil
+}
+
 func (m *MockTaskAuditRepository) GetTaskStatistics() (map[string]int64, error) {
 	if m.GetTaskStatisticsFunc != nil {
 		return m.GetTaskStatisticsFunc()
@@ -136,6 +255,48 @@ func (m *MockTaskAuditRepository) GetTaskStatistics() (map[string]int64, error)
 	return nil, nil
 }
 
+func (m *MockTaskAuditRepository) GetQueueStatistics() (map[string]map[string]int64, error) {
+	if m.GetQueueStatisticsFunc != nil {
+		return m.GetQueueStatisticsFunc()
+	}
+	return nil, nil
+}
+
+func (m *MockTaskAuditRepository) FindTaskAuditsByTaskIDs(taskIDs []uint) ([]*database.TaskAudit, error) {
+	if m.FindTaskAuditsByTaskIDsFunc != nil {
+		return m.FindTaskAuditsByTaskIDsFunc(taskIDs)
+	}
+	return nil, nil
+}
+
+func (m *MockTaskAuditRepository) UnblockTask(taskID uint, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+	if m.UnblockTaskFunc != nil {
+		return m.UnblockTaskFunc(taskID, effectiveAt, expiresAt)
+	}
+	return false, nil
+}
+
+func (m *MockTaskAuditRepository) FailBlockedTask(taskID uint, errorCode string, errorMsg string) (bool, error) {
+	if m.FailBlockedTaskFunc != nil {
+		return m.FailBlockedTaskFunc(taskID, errorCode, errorMsg)
+	}
+	return false, nil
+}
+
+func (m *MockTaskAuditRepository) RequeueFailedTask(taskID uint, status database.TaskStatus, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+	if m.RequeueFailedTaskFunc != nil {
+		return m.RequeueFailedTaskFunc(taskID, status, effectiveAt, expiresAt)
+	}
+	return false, nil
+}
+
+func (m *MockTaskAuditRepository) CreateTaskFailure(failure *database.TaskFailure) error {
+	if m.CreateTaskFailureFunc != nil {
+		return m.CreateTaskFailureFunc(failure)
+	}
+	return nil
+}
+
 func (m *MockTaskAuditRepository) GetEnhancedStatistics() (map[string]interface{}, error) {
 	if m.GetEnhancedStatisticsFunc != nil {
 		return m.GetEnhancedStatisticsFunc()
@@ -200,12 +361,12 @@ func (m *MockTaskAuditRepository) GetUserErrorBreakdown(userID uint, limit int)
 }
 
 type MockResultRepository struct {
-	CreateResultFunc                       func(result *database.Result) error
-	FindResultByTaskIDFunc                 func(taskID uint) (*database.Result, error)
-	FindResultsByUserIDFunc                func(userID uint) ([]database.Result, error)
-	FindResultByIDFunc                     func(resultID uint) (*database.Result, error)
-	FindOldestUnconsumedResultByUserIDFunc func(userID uint) (*database.Result, error)
-	MarkResultAsConsumedFunc               func(resultID uint) error
+	CreateResultFunc        func(result *database.Result) error
+	FindResultByTaskIDFunc  func(taskID uint) (*database.Result, error)
+	FindResultsByUserIDFunc func(userID uint) ([]database.Result, error)
+	FindResultByIDFunc      func(resultID uint) (*database.Result, error)
+	LeaseResultsFunc        func(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error)
+	AckResultFunc           func(resultID uint, receipt string) (bool, error)
 }
 
 func (m *MockResultRepository) CreateResult(result *database.Result) error {
@@ -236,16 +397,223 @@ func (m *MockResultRepository) FindResultByID(resultID uint) (*database.Result,
 	return nil, nil
 }
 
-func (m *MockResultRepository) FindOldestUnconsumedResultByUserID(userID uint) (*database.Result, error) {
-	if m.FindOldestUnconsumedResultByUserIDFunc != nil {
-		return m.FindOldestUnconsumedResultByUserIDFunc(userID)
+func (m *MockResultRepository) LeaseResults(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error) {
+	if m.LeaseResultsFunc != nil {
+		return m.LeaseResultsFunc(userID, receipts, leaseExpiresAt)
+	}
+	return nil, nil
+}
+
+func (m *MockResultRepository) AckResult(resultID uint, receipt string) (bool, error) {
+	if m.AckResultFunc != nil {
+		return m.AckResultFunc(resultID, receipt)
+	}
+	return true, nil
+}
+
+type MockScheduleRepository struct {
+	CreateScheduleFunc        func(schedule *database.Schedule) error
+	FindScheduleByIDFunc      func(scheduleID uint) (*database.Schedule, error)
+	FindSchedulesByUserIDFunc func(userID uint) ([]database.Schedule, error)
+	FindDueSchedulesFunc      func(now time.Time) ([]database.Schedule, error)
+	UpdateScheduleStatusFunc  func(scheduleID uint, status database.ScheduleStatus, nextRunAt time.Time) error
+	AdvanceScheduleFunc       func(scheduleID uint, expectedNextRunAt time.Time, nextRunAt time.Time, lastRunAt *time.Time) (bool, error)
+	DeleteScheduleFunc        func(scheduleID uint) error
+}
+
+func (m *MockScheduleRepository) CreateSchedule(schedule *database.Schedule) error {
+	if m.CreateScheduleFunc != nil {
+		return m.CreateScheduleFunc(schedule)
+	}
+	return nil
+}
+
+func (m *MockScheduleRepository) FindScheduleByID(scheduleID uint) (*database.Schedule, error) {
+	if m.FindScheduleByIDFunc != nil {
+		return m.FindScheduleByIDFunc(scheduleID)
+	}
+	return nil, nil
+}
+
+func (m *MockScheduleRepository) FindSchedulesByUserID(userID uint) ([]database.Schedule, error) {
+	if m.FindSchedulesByUserIDFunc != nil {
+		return m.FindSchedulesByUserIDFunc(userID)
+	}
+	return nil, nil
+}
+
+func (m *MockScheduleRepository) FindDueSchedules(now time.Time) ([]database.Schedule, error) {
+	if m.FindDueSchedulesFunc != nil {
+		return m.FindDueSchedulesFunc(now)
+	}
+	return nil, nil
+}
+
+func (m *MockScheduleRepository) UpdateScheduleStatus(scheduleID uint, status database.ScheduleStatus, nextRunAt time.Time) error {
+	if m.UpdateScheduleStatusFunc != nil {
+		return m.UpdateScheduleStatusFunc(scheduleID, status, nextRunAt)
+	}
+	return nil
+}
+
+func (m *MockScheduleRepository) AdvanceSchedule(scheduleID uint, expectedNextRunAt time.Time, nextRunAt time.Time, lastRunAt *time.Time) (bool, error) {
+	if m.AdvanceScheduleFunc != nil {
+		return m.AdvanceScheduleFunc(scheduleID, expectedNextRunAt, nextRunAt, lastRunAt)
+	}
+	return true, nil
+}
+
+func (m *MockScheduleRepository) DeleteSchedule(scheduleID uint) error {
+	if m.DeleteScheduleFunc != nil {
+		return m.DeleteScheduleFunc(scheduleID)
+	}
+	return nil
+}
+
+type MockWorkflowRepository struct {
+	CreateWorkflowFunc           func(workflow *database.Workflow) error
+	FindWorkflowByIDFunc         func(workflowID uint) (*database.Workflow, error)
+	FindWorkflowTasksFunc        func(workflowID uint) ([]*database.TaskAudit, error)
+	FindWorkflowDependenciesFunc func(workflowID uint) ([]database.TaskDependency, error)
+}
+
+func (m *MockWorkflowRepository) CreateWorkflow(workflow *database.Workflow) error {
+	if m.CreateWorkflowFunc != nil {
+		return m.CreateWorkflowFunc(workflow)
+	}
+	return nil
+}
+
+func (m *MockWorkflowRepository) FindWorkflowByID(workflowID uint) (*database.Workflow, error) {
+	if m.FindWorkflowByIDFunc != nil {
+		return m.FindWorkflowByIDFunc(workflowID)
+	}
+	return nil, nil
+}
+
+func (m *MockWorkflowRepository) FindWorkflowTasks(workflowID uint) ([]*database.TaskAudit, error) {
+	if m.FindWorkflowTasksFunc != nil {
+		return m.FindWorkflowTasksFunc(workflowID)
+	}
+	return nil, nil
+}
+
+func (m *MockWorkflowRepository) FindWorkflowDependencies(workflowID uint) ([]database.TaskDependency, error) {
+	if m.FindWorkflowDependenciesFunc != nil {
+		return m.FindWorkflowDependenciesFunc(workflowID)
+	}
+	return nil, nil
+}
+
+type MockMapJobRepository struct {
+	CreateMapJobFunc      func(job *database.MapJob) error
+	FindMapJobByIDFunc    func(jobID uint) (*database.MapJob, error)
+	CountMapJobTasksFunc  func(jobID uint) (map[string]int64, error)
+	FindMapJobTasksFunc   func(jobID uint) ([]*database.TaskAudit, error)
+	FindMapJobResultsFunc func(jobID uint) ([]database.Result, error)
+}
+
+func (m *MockMapJobRepository) CreateMapJob(job *database.MapJob) error {
+	if m.CreateMapJobFunc != nil {
+		return m.CreateMapJobFunc(job)
+	}
+	return nil
+}
+
+func (m *MockMapJobRepository) FindMapJobByID(jobID uint) (*database.MapJob, error) {
+	if m.FindMapJobByIDFunc != nil {
+		return m.FindMapJobByIDFunc(jobID)
+	}
+	return nil, nil
+}
+
+func (m *MockMapJobRepository) CountMapJobTasks(jobID uint) (map[string]int64, error) {
+	if m.CountMapJobTasksFunc != nil {
+		return m.CountMapJobTasksFunc(jobID)
+	}
+	return nil, nil
+}
+
+func (m *MockMapJobRepository) FindMapJobTasks(jobID uint) ([]*database.TaskAudit, error) {
+	if m.FindMapJobTasksFunc != nil {
+		return m.FindMapJobTasksFunc(jobID)
 	}
 	return nil, nil
 }
 
-func (m *MockResultRepository) MarkResultAsConsumed(resultID uint) error {
-	if m.MarkResultAsConsumedFunc != nil {
-		return m.MarkResultAsConsumedFunc(resultID)
+func (m *MockMapJobRepository) FindMapJobResults(jobID uint) ([]database.Result, error) {
+	if m.FindMapJobResultsFunc != nil {
+		return m.FindMapJobResultsFunc(jobID)
+	}
+	return nil, nil
+}
+
+type MockTaskGroupRepository struct {
+	CreateTaskGroupFunc         func(group *database.TaskGroup) error
+	FindTaskGroupByIDFunc       func(groupID uint) (*database.TaskGroup, error)
+	FindTaskGroupTasksFunc      func(groupID uint) ([]*database.TaskAudit, error)
+	FindTaskGroupReduceTaskFunc func(groupID uint) (*database.TaskAudit, error)
+}
+
+func (m *MockTaskGroupRepository) CreateTaskGroup(group *database.TaskGroup) error {
+	if m.CreateTaskGroupFunc != nil {
+		return m.CreateTaskGroupFunc(group)
 	}
 	return nil
 }
+
+func (m *MockTaskGroupRepository) FindTaskGroupByID(groupID uint) (*database.TaskGroup, error) {
+	if m.FindTaskGroupByIDFunc != nil {
+		return m.FindTaskGroupByIDFunc(groupID)
+	}
+	return nil, nil
+}
+
+func (m *MockTaskGroupRepository) FindTaskGroupTasks(groupID uint) ([]*database.TaskAudit, error) {
+	if m.FindTaskGroupTasksFunc != nil {
+		return m.FindTaskGroupTasksFunc(groupID)
+	}
+	return nil, nil
+}
+
+func (m *MockTaskGroupRepository) FindTaskGroupReduceTask(groupID uint) (*database.TaskAudit, error) {
+	if m.FindTaskGroupReduceTaskFunc != nil {
+		return m.FindTaskGroupReduceTaskFunc(groupID)
+	}
+	return nil, nil
+}
+
+type MockDeadLetterRepository struct {
+	FindDeadLettersFunc       func(userID uint, filter repository.DeadLetterFilter, limit, offset int) ([]*database.TaskAudit, int64, error)
+	FindDeadLetterTaskIDsFunc func(userID uint, filter repository.DeadLetterFilter, limit int) ([]uint, error)
+	FindTaskFailuresFunc      func(taskIDs []uint) ([]database.TaskFailure, error)
+	PurgeDeadLettersFunc      func(userID uint, filter repository.DeadLetterFilter, failedBefore time.Time) (int64, error)
+}
+
+func (m *MockDeadLetterRepository) FindDeadLetters(userID uint, filter repository.DeadLetterFilter, limit, offset int) ([]*database.TaskAudit, int64, error) {
+	if m.FindDeadLettersFunc != nil {
+		return m.FindDeadLettersFunc(userID, filter, limit, offset)
+	}
+	return nil, 0, nil
+}
+
+func (m *MockDeadLetterRepository) FindDeadLetterTaskIDs(userID uint, filter repository.DeadLetterFilter, limit int) ([]uint, error) {
+	if m.FindDeadLetterTaskIDsFunc != nil {
+		return m.FindDeadLetterTaskIDsFunc(userID, filter, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockDeadLetterRepository) FindTaskFailures(taskIDs []uint) ([]database.TaskFailure, error) {
+	if m.FindTaskFailuresFunc != nil {
+		return m.FindTaskFailuresFunc(taskIDs)
+	}
+	return nil, nil
+}
+
+func (m *MockDeadLetterRepository) PurgeDeadLetters(userID uint, filter repository.DeadLetterFilter, failedBefore time.Time) (int64, error) {
+	if m.PurgeDeadLettersFunc != nil {
+		return m.PurgeDeadLettersFunc(userID, filter, failedBefore)
+	}
+	return 0, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"rainchanel.com/internal/validation"
+)
+
+var ErrInvalidResultReference = errors.New("invalid result reference")
+
+type resultReference struct {
+	TaskID uint
+	Path   string
+}
+
+func parseResultReference(reference map[string]interface{}) (resultReference, error) {
+	var parsed resultReference
+	for key, value := range reference {
+		switch key {
+		case validation.ResultReferenceKey:
+			taskID, ok := referencedTaskID(value)
+			if !ok {
+				return parsed, fmt.Errorf("%w: %s must be a task id", ErrInvalidResultReference, validation.ResultReferenceKey)
+			}
+			parsed.TaskID = taskID
+		case "path":
+			path, ok := value.(string)
+			if !ok {
+				return parsed, fmt.Errorf("%w: path must be a string", ErrInvalidResultReference)
+			}
+			parsed.Path = path
+		default:
+			return parsed, fmt.Errorf("%w: unexpected field %q", ErrInvalidResultReference, key)
+		}
+	}
+	return parsed, nil
+}
+
+func referencedTaskID(value interface{}) (uint, bool) {
+	switch v := value.(type) {
+	case float64:
+		if v < 1 || v > math.MaxUint32 || v != math.Trunc(v) {
+			return 0, false
+		}
+		return uint(v), true
+	case json.Number:
+		id, err := strconv.ParseUint(v.String(), 10, 32)
+		return uint(id), err == nil && id > 0
+	case uint:
+		return v, v > 0
+	default:
+		return 0, false
+	}
+}
+
+// walkResultReferences returns a copy of args in which every result reference,
+// at any depth, has been replaced by the value returned from replace.
+func walkResultReferences(args interface{}, replace func(reference map[string]interface{}) (interface{}, error)) (interface{}, error) {
+	switch v := args.(type) {
+	case map[string]interface{}:
+		if validation.IsResultReference(v) {
+			return replace(v)
+		}
+		walked := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			replaced, err := walkResultReferences(value, replace)
+			if err != nil {
+				return nil, err
+			}
+			walked[key] = replaced
+		}
+		return walked, nil
+	case []interface{}:
+		walked := make([]interface{}, len(v))
+		for i, value := range v {
+			replaced, err := walkResultReferences(value, replace)
+			if err != nil {
+				return nil, err
+			}
+			walked[i] = replaced
+		}
+		return walked, nil
+	default:
+		return args, nil
+	}
+}
+
+func findResultReferences(args interface{}) ([]resultReference, error) {
+	var references []resultReference
+	_, err := walkResultReferences(args, func(reference map[string]interface{}) (interface{}, error) {
+		parsed, err := parseResultReference(reference)
+		if err != nil {
+			return nil, err
+		}
+		references = append(references, parsed)
+		return reference, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return references, nil
+}
+
+// resultAtPath follows a dot-separated path of object keys and array indexes,
+// such as "rows.0.total", into a decoded result.
+func resultAtPath(result interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return result, nil
+	}
+
+	value := result
+	for _, segment := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, fmt.Errorf("%w: path %q: key %q not found", ErrInvalidResultReference, path, segment)
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("%w: path %q: index %q out of range", ErrInvalidResultReference, path, segment)
+			}
+			value = v[index]
+		default:
+			return nil, fmt.Errorf("%w: path %q: cannot index into %T", ErrInvalidResultReference, path, value)
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindResultReferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    interface{}
+		want    []resultReference
+		wantErr error
+	}{
+		{
+			name: "no references",
+			args: []interface{}{float64(1), "two"},
+		},
+		{
+			name: "nested references",
+			args: []interface{}{
+				map[string]interface{}{"$from_task": float64(3)},
+				map[string]interface{}{"config": []interface{}{
+					map[string]interface{}{"$from_task": float64(4), "path": "rows.0"},
+				}},
+			},
+			want: []resultReference{{TaskID: 3}, {TaskID: 4, Path: "rows.0"}},
+		},
+		{
+			name:    "task id must be a number",
+			args:    []interface{}{map[string]interface{}{"$from_task": "fetch"}},
+			wantErr: ErrInvalidResultReference,
+		},
+		{
+			name:    "unknown field",
+			args:    []interface{}{map[string]interface{}{"$from_task": float64(3), "default": float64(0)}},
+			wantErr: ErrInvalidResultReference,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			references, err := findResultReferences(tt.args)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, references)
+		})
+	}
+}
+
+func TestResultAtPath(t *testing.T) {
+	result := map[string]interface{}{
+		"rows": []interface{}{
+			map[string]interface{}{"total": float64(12)},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "whole result", path: "", want: result},
+		{name: "nested value", path: "rows.0.total", want: float64(12)},
+		{name: "missing key", path: "count", wantErr: true},
+		{name: "index out of range", path: "rows.1", wantErr: true},
+		{name: "index into a number", path: "rows.0.total.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := resultAtPath(result, tt.path)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidResultReference)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, value)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"slices"
+	"time"
+
+	"rainchanel.com/internal/config"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+)
+
+var ErrInvalidRetryPolicy = errors.New("invalid retry policy")
+
+const MaxNonRetryableCodes = 50
+const MaxErrorCodeLength = 100
+
+// validateRetryPolicy checks a producer's retry policy against the limits set
+// by the server admin.
+func validateRetryPolicy(policy *dto.RetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	maxRetriesLimit := config.App.Task.MaxRetriesLimit
+	if policy.MaxRetries != nil && (*policy.MaxRetries < 0 || *policy.MaxRetries > maxRetriesLimit) {
+		return fmt.Errorf("%w: max_retries must be between 0 and %d", ErrInvalidRetryPolicy, maxRetriesLimit)
+	}
+
+	switch database.RetryBackoff(policy.Backoff) {
+	case "", database.RetryBackoffFixed, database.RetryBackoffLinear, database.RetryBackoffExponential:
+	default:
+		return fmt.Errorf("%w: unknown backoff %q", ErrInvalidRetryPolicy, policy.Backoff)
+	}
+
+	backoffLimit := config.App.Task.RetryBackoffLimitSeconds
+	if policy.BaseSeconds != nil && (*policy.BaseSeconds < 0 || *policy.BaseSeconds > backoffLimit) {
+		return fmt.Errorf("%w: base_seconds must be between 0 and %d", ErrInvalidRetryPolicy, backoffLimit)
+	}
+	if policy.MaxSeconds != nil && (*policy.MaxSeconds < 0 || *policy.MaxSeconds > backoffLimit) {
+		return fmt.Errorf("%w: max_seconds must be between 0 and %d", ErrInvalidRetryPolicy, backoffLimit)
+	}
+
+	if len(policy.NonRetryableCodes) > MaxNonRetryableCodes {
+		return fmt.Errorf("%w: at most %d non_retryable_codes", ErrInvalidRetryPolicy, MaxNonRetryableCodes)
+	}
+	for _, code := range policy.NonRetryableCodes {
+		if code == "" || len(code) > MaxErrorCodeLength {
+			return fmt.Errorf("%w: error codes must be between 1 and %d characters", ErrInvalidRetryPolicy, MaxErrorCodeLength)
+		}
+	}
+
+	return nil
+}
+
+func retryPolicyToDB(policy *dto.RetryPolicy) (database.RetryPolicy, error) {
+	if policy == nil {
+		return database.RetryPolicy{}, nil
+	}
+
+	dbPolicy := database.RetryPolicy{
+		MaxRetries:  policy.MaxRetries,
+		Backoff:     database.RetryBackoff(policy.Backoff),
+		BaseSeconds: policy.BaseSeconds,
+		MaxSeconds:  policy.MaxSeconds,
+	}
+	if len(policy.NonRetryableCodes) > 0 {
+		codesJSON, err := json.Marshal(policy.NonRetryableCodes)
+		if err != nil {
+			return database.RetryPolicy{}, fmt.Errorf("failed to marshal non-retryable codes: %w", err)
+		}
+		dbPolicy.NonRetryableCodes = string(codesJSON)
+	}
+	return dbPolicy, nil
+}
+
+func taskMaxRetries(policy database.RetryPolicy) int {
+	if policy.MaxRetries != nil {
+		return *policy.MaxRetries
+	}
+	return config.App.Task.MaxRetries
+}
+
+// isNonRetryable reports whether the producer asked for failures with code
+// not to be retried.
+func isNonRetryable(policy database.RetryPolicy, code string) bool {
+	if code == "" || policy.NonRetryableCodes == "" {
+		return false
+	}
+	var codes []string
+	if err := json.Unmarshal([]byte(policy.NonRetryableCodes), &codes); err != nil {
+		return false
+	}
+	return slices.Contains(codes, code)
+}
+
+func retryBackoff(policy database.RetryPolicy, retryCount int) time.Duration {
+	baseSeconds := config.App.Task.RetryBackoffBaseSeconds
+	if policy.BaseSeconds != nil {
+		baseSeconds = *policy.BaseSeconds
+	}
+	if baseSeconds <= 0 {
+		return 0
+	}
+
+	// A policy max of 0 means the server default, and no delay may go past
+	// the admin's limit.
+	maxSeconds := config.App.Task.RetryBackoffMaxSeconds
+	if policy.MaxSeconds != nil && *policy.MaxSeconds > 0 {
+		maxSeconds = *policy.MaxSeconds
+	}
+	if limit := config.App.Task.RetryBackoffLimitSeconds; limit > 0 && (maxSeconds <= 0 || maxSeconds > limit) {
+		maxSeconds = limit
+	}
+
+	// Work in float seconds so large retry counts cannot overflow a Duration.
+	var backoff float64
+	switch policy.Backoff {
+	case database.RetryBackoffFixed:
+		backoff = float64(baseSeconds)
+	case database.RetryBackoffLinear:
+		backoff = float64(baseSeconds) * float64(retryCount+1)
+	default:
+		backoff = float64(baseSeconds) * math.Pow(2, float64(retryCount))
+	}
+	if maxSeconds > 0 && backoff > float64(maxSeconds) {
+		backoff = float64(maxSeconds)
+	}
+
+	jitter := config.App.Task.RetryBackoffJitter
+	if jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		backoff *= 1 + jitter*(2*mathrand.Float64()-1)
+	}
+	if limit := config.App.Task.RetryBackoffLimitSeconds; limit > 0 && backoff > float64(limit) {
+		backoff = float64(limit)
+	}
+
+	if backoff >= float64(math.MaxInt64)/float64(time.Second) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(backoff * float64(time.Second))
+}
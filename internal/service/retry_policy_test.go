@@ -0,0 +1,106 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rainchanel.com/internal/config"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+)
+
+func TestValidateRetryPolicy(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			MaxRetriesLimit:          10,
+			RetryBackoffLimitSeconds: 600,
+		},
+	}
+	intPtr := func(n int) *int { return &n }
+
+	tests := []struct {
+		name    string
+		policy  *dto.RetryPolicy
+		wantErr bool
+	}{
+		{
+			name:   "no policy",
+			policy: nil,
+		},
+		{
+			name: "full policy within limits",
+			policy: &dto.RetryPolicy{
+				MaxRetries:        intPtr(10),
+				Backoff:           "linear",
+				BaseSeconds:       intPtr(5),
+				MaxSeconds:        intPtr(600),
+				NonRetryableCodes: []string{"invalid_input"},
+			},
+		},
+		{
+			name:   "zero retries",
+			policy: &dto.RetryPolicy{MaxRetries: intPtr(0)},
+		},
+		{
+			name:    "max retries above limit",
+			policy:  &dto.RetryPolicy{MaxRetries: intPtr(11)},
+			wantErr: true,
+		},
+		{
+			name:    "negative max retries",
+			policy:  &dto.RetryPolicy{MaxRetries: intPtr(-1)},
+			wantErr: true,
+		},
+		{
+			name:    "unknown backoff",
+			policy:  &dto.RetryPolicy{Backoff: "random"},
+			wantErr: true,
+		},
+		{
+			name:    "max delay above limit",
+			policy:  &dto.RetryPolicy{MaxSeconds: intPtr(601)},
+			wantErr: true,
+		},
+		{
+			name:    "empty error code",
+			policy:  &dto.RetryPolicy{NonRetryableCodes: []string{""}},
+			wantErr: true,
+		},
+		{
+			name:    "error code too long",
+			policy:  &dto.RetryPolicy{NonRetryableCodes: []string{strings.Repeat("x", MaxErrorCodeLength+1)}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRetryPolicy(tt.policy)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidRetryPolicy)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTaskMaxRetries(t *testing.T) {
+	config.App = &config.Config{Task: config.TaskConfig{MaxRetries: 3}}
+	ten := 10
+
+	assert.Equal(t, 3, taskMaxRetries(database.RetryPolicy{}))
+	assert.Equal(t, 10, taskMaxRetries(database.RetryPolicy{MaxRetries: &ten}))
+}
+
+func TestIsNonRetryable(t *testing.T) {
+	policy, err := retryPolicyToDB(&dto.RetryPolicy{NonRetryableCodes: []string{"invalid_input", "div_by_zero"}})
+	assert.NoError(t, err)
+
+	assert.True(t, isNonRetryable(policy, "div_by_zero"))
+	assert.False(t, isNonRetryable(policy, "timeout"))
+	assert.False(t, isNonRetryable(policy, ""))
+	assert.False(t, isNonRetryable(database.RetryPolicy{}, "div_by_zero"))
+}
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"rainchanel.com/internal/config"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+var ErrScheduleAccessDenied = errors.New("schedule does not belong to user")
+var ErrInvalidCronExpression = errors.New("invalid cron expression")
+var ErrInvalidMissedTickPolicy = errors.New("invalid missed tick policy")
+var ErrScheduleTicksLost = errors.New("unpublished schedule ticks could not be handed back")
+
+const maxScheduleRunsPerCheck = 100
+
+// scheduleHandBackAttempts is how often runSchedule tries to hand back the
+// ticks it could not publish before giving up on them.
+const scheduleHandBackAttempts = 3
+
+type ScheduleService interface {
+	CreateSchedule(schedule dto.Schedule, createdBy uint) (*dto.Schedule, error)
+	ListSchedules(userID uint) ([]dto.Schedule, error)
+	PauseSchedule(scheduleID uint, userID uint) error
+	ResumeSchedule(scheduleID uint, userID uint) error
+	DeleteSchedule(scheduleID uint, userID uint) error
+	RunDueSchedules() (int, error)
+}
+
+type scheduleService struct {
+	scheduleRepo repository.ScheduleRepository
+	taskService  TaskService
+}
+
+func NewScheduleService(taskService TaskService) ScheduleService {
+	return &scheduleService{
+		scheduleRepo: repository.NewScheduleRepository(),
+		taskService:  taskService,
+	}
+}
+
+func NewScheduleServiceWithRepo(scheduleRepo repository.ScheduleRepository, taskService TaskService) ScheduleService {
+	return &scheduleService{
+		scheduleRepo: scheduleRepo,
+		taskService:  taskService,
+	}
+}
+
+func (s *scheduleService) CreateSchedule(schedule dto.Schedule, createdBy uint) (*dto.Schedule, error) {
+	cronSchedule, err := cron.ParseStandard(schedule.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCronExpression, err)
+	}
+
+	missedTicks := database.MissedTickPolicy(schedule.MissedTicks)
+	if missedTicks == "" {
+		missedTicks = database.MissedTickPolicySkip
+	}
+	if missedTicks != database.MissedTickPolicySkip && missedTicks != database.MissedTickPolicyCatchUp {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMissedTickPolicy, schedule.MissedTicks)
+	}
+
+	task := dto.Task{
+		WasmModule: schedule.WasmModule,
+		Func:       schedule.Func,
+		Args:       schedule.Args,
+		Queue:      schedule.Queue,
+		Priority:   schedule.Priority,
+	}
+	if err := validateTaskDefinition(&task); err != nil {
+		return nil, err
+	}
+
+	argsJSON, err := json.Marshal(task.Args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schedule args: %w", err)
+	}
+
+	dbSchedule := &database.Schedule{
+		WasmModule:  task.WasmModule,
+		Func:        task.Func,
+		Args:        string(argsJSON),
+		Queue:       task.Queue,
+		Priority:    task.Priority,
+		CronExpr:    schedule.Cron,
+		MissedTicks: missedTicks,
+		Status:      database.ScheduleStatusActive,
+		NextRunAt:   cronSchedule.Next(time.Now()),
+		CreatedBy:   createdBy,
+	}
+	if err := s.scheduleRepo.CreateSchedule(dbSchedule); err != nil {
+		return nil, fmt.Errorf("failed to create schedule in database: %w", err)
+	}
+
+	result := toScheduleDTO(dbSchedule, task.Args)
+	return &result, nil
+}
+
+func (s *scheduleService) ListSchedules(userID uint) ([]dto.Schedule, error) {
+	dbSchedules, err := s.scheduleRepo.FindSchedulesByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find schedules: %w", err)
+	}
+
+	schedules := make([]dto.Schedule, 0, len(dbSchedules))
+	for i := range dbSchedules {
+		var args interface{}
+		if dbSchedules[i].Args != "" {
+			if err := json.Unmarshal([]byte(dbSchedules[i].Args), &args); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal schedule args: %w", err)
+			}
+		}
+		schedule := toScheduleDTO(&dbSchedules[i], args)
+		schedule.WasmModule = ""
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+func (s *scheduleService) PauseSchedule(scheduleID uint, userID uint) error {
+	schedule, err := s.findOwnedSchedule(scheduleID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.scheduleRepo.UpdateScheduleStatus(schedule.ID, database.ScheduleStatusPaused, schedule.NextRunAt); err != nil {
+		return fmt.Errorf("failed to pause schedule: %w", err)
+	}
+	return nil
+}
+
+func (s *scheduleService) ResumeSchedule(scheduleID uint, userID uint) error {
+	schedule, err := s.findOwnedSchedule(scheduleID, userID)
+	if err != nil {
+		return err
+	}
+
+	cronSchedule, err := cron.ParseStandard(schedule.CronExpr)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCronExpression, err)
+	}
+
+	if err := s.scheduleRepo.UpdateScheduleStatus(schedule.ID, database.ScheduleStatusActive, cronSchedule.Next(time.Now())); err != nil {
+		return fmt.Errorf("failed to resume schedule: %w", err)
+	}
+	return nil
+}
+
+func (s *scheduleService) DeleteSchedule(scheduleID uint, userID uint) error {
+	schedule, err := s.findOwnedSchedule(scheduleID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.scheduleRepo.DeleteSchedule(schedule.ID); err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	return nil
+}
+
+func (s *scheduleService) RunDueSchedules() (int, error) {
+	now := time.Now()
+	schedules, err := s.scheduleRepo.FindDueSchedules(now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find due schedules: %w", err)
+	}
+
+	publishedCount := 0
+	lost := 0
+	for i := range schedules {
+		published, err := s.runSchedule(&schedules[i], now)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"schedule_id": schedules[i].ID,
+				"error":       err.Error(),
+			}).Error("Failed to run schedule")
+			if errors.Is(err, ErrScheduleTicksLost) {
+				lost++
+			}
+		}
+		publishedCount += published
+	}
+
+	if lost > 0 {
+		return publishedCount, fmt.Errorf("%d of %d schedules: %w", lost, len(schedules), ErrScheduleTicksLost)
+	}
+	return publishedCount, nil
+}
+
+func (s *scheduleService) runSchedule(schedule *database.Schedule, now time.Time) (int, error) {
+	cronSchedule, err := cron.ParseStandard(schedule.CronExpr)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidCronExpression, err)
+	}
+
+	grace := time.Duration(config.App.Schedule.MisfireGraceSeconds) * time.Second
+	next := schedule.NextRunAt
+	skipped := 0
+
+	if schedule.MissedTicks != database.MissedTickPolicyCatchUp && now.Sub(next) > grace {
+		resumeAt := cronSchedule.Next(now.Add(-grace))
+		for tick := next; tick.Before(resumeAt) && skipped < maxScheduleRunsPerCheck; tick = cronSchedule.Next(tick) {
+			skipped++
+		}
+		next = resumeAt
+	}
+
+	var ticks []time.Time
+	for !next.After(now) && len(ticks) < maxScheduleRunsPerCheck {
+		ticks = append(ticks, next)
+		next = cronSchedule.Next(next)
+	}
+
+	var args interface{}
+	if schedule.Args != "" {
+		if err := json.Unmarshal([]byte(schedule.Args), &args); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal schedule args: %w", err)
+		}
+	}
+
+	claimed, err := s.scheduleRepo.AdvanceSchedule(schedule.ID, schedule.NextRunAt, next, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to advance schedule: %w", err)
+	}
+	if !claimed {
+		return 0, nil
+	}
+
+	if skipped > 0 {
+		logrus.WithFields(logrus.Fields{
+			"schedule_id": schedule.ID,
+			"skipped":     skipped,
+		}).Warn("Skipped missed schedule ticks")
+	}
+	if len(ticks) == 0 {
+		return 0, nil
+	}
+
+	task := dto.Task{
+		WasmModule: schedule.WasmModule,
+		Func:       schedule.Func,
+		Args:       args,
+		Queue:      schedule.Queue,
+		Priority:   schedule.Priority,
+	}
+
+	published := 0
+	var publishErr error
+	for range ticks {
+		taskID, err := s.taskService.PublishTask(task, schedule.CreatedBy, dto.PublishOptions{})
+		if err != nil {
+			publishErr = fmt.Errorf("failed to publish scheduled task: %w", err)
+			break
+		}
+		published++
+		logrus.WithFields(logrus.Fields{
+			"schedule_id": schedule.ID,
+			"task_id":     taskID,
+		}).Info("Published scheduled task")
+	}
+	// Hand back the ticks that were not published so the next check runs
+	// them, and record the run if anything went out.
+	resumeAt := next
+	if publishErr != nil {
+		resumeAt = ticks[published]
+	}
+	var lastRunAt *time.Time
+	if published > 0 {
+		lastRunAt = &now
+	}
+	if err := s.handBackTicks(schedule.ID, next, resumeAt, lastRunAt); err != nil {
+		if resumeAt.Equal(next) {
+			// Every tick went out, only the run time was not recorded.
+			logrus.WithFields(logrus.Fields{
+				"schedule_id": schedule.ID,
+				"error":       err.Error(),
+			}).Error("Failed to record schedule run")
+			return published, publishErr
+		}
+		return published, fmt.Errorf("%w: %d ticks from %s: %v (publish error: %v)",
+			ErrScheduleTicksLost, len(ticks)-published, resumeAt.Format(time.RFC3339), err, publishErr)
+	}
+
+	return published, publishErr
+}
+
+// handBackTicks moves the schedule from our claim to resumeAt, retrying on
+// database errors. It fails if the schedule no longer holds our claim, for
+// example because it was paused or deleted in the meantime.
+func (s *scheduleService) handBackTicks(scheduleID uint, claimedNextRunAt time.Time, resumeAt time.Time, lastRunAt *time.Time) error {
+	var err error
+	for attempt := 0; attempt < scheduleHandBackAttempts; attempt++ {
+		var claimed bool
+		claimed, err = s.scheduleRepo.AdvanceSchedule(scheduleID, claimedNextRunAt, resumeAt, lastRunAt)
+		if err == nil {
+			if !claimed {
+				return errors.New("schedule changed since it was claimed")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to advance schedule: %w", err)
+}
+
+func (s *scheduleService) findOwnedSchedule(scheduleID uint, userID uint) (*database.Schedule, error) {
+	schedule, err := s.scheduleRepo.FindScheduleByID(scheduleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, fmt.Errorf("failed to find schedule: %w", err)
+	}
+
+	if schedule.CreatedBy != userID {
+		return nil, ErrScheduleAccessDenied
+	}
+
+	return schedule, nil
+}
+
+func toScheduleDTO(schedule *database.Schedule, args interface{}) dto.Schedule {
+	nextRunAt := schedule.NextRunAt
+	return dto.Schedule{
+		ID:          schedule.ID,
+		WasmModule:  schedule.WasmModule,
+		Func:        schedule.Func,
+		Args:        args,
+		Queue:       schedule.Queue,
+		Priority:    schedule.Priority,
+		Cron:        schedule.CronExpr,
+		MissedTicks: string(schedule.MissedTicks),
+		Status:      string(schedule.Status),
+		NextRunAt:   &nextRunAt,
+		LastRunAt:   schedule.LastRunAt,
+		CreatedBy:   schedule.CreatedBy,
+	}
+}
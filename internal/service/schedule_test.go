@@ -0,0 +1,357 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"rainchanel.com/internal/config"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+)
+
+func TestNewScheduleService(t *testing.T) {
+	service := NewScheduleService(&MockTaskServiceForStale{})
+
+	if service == nil {
+		t.Error("NewScheduleService() returned nil")
+	}
+}
+
+func TestScheduleService_CreateSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule dto.Schedule
+		wantErr  error
+	}{
+		{
+			name: "invalid cron expression",
+			schedule: dto.Schedule{
+				WasmModule: "AGFzbQEAAAABBwFgAn9/AX9gAAF/",
+				Func:       "testFunc",
+				Cron:       "every tuesday",
+			},
+			wantErr: ErrInvalidCronExpression,
+		},
+		{
+			name: "invalid missed tick policy",
+			schedule: dto.Schedule{
+				WasmModule:  "AGFzbQEAAAABBwFgAn9/AX9gAAF/",
+				Func:        "testFunc",
+				Cron:        "*/5 * * * *",
+				MissedTicks: "replay",
+			},
+			wantErr: ErrInvalidMissedTickPolicy,
+		},
+		{
+			name: "invalid queue",
+			schedule: dto.Schedule{
+				WasmModule: "AGFzbQEAAAABBwFgAn9/AX9gAAF/",
+				Func:       "testFunc",
+				Cron:       "@hourly",
+				Queue:      "not a queue",
+			},
+			wantErr: ErrInvalidQueue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewScheduleServiceWithRepo(&MockScheduleRepository{}, &MockTaskServiceForStale{})
+
+			schedule, err := service.CreateSchedule(tt.schedule, 1)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Nil(t, schedule)
+		})
+	}
+}
+
+func TestScheduleService_PauseSchedule(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     uint
+		findErr    error
+		wantErr    error
+		wantStatus database.ScheduleStatus
+	}{
+		{
+			name:    "not found",
+			userID:  1,
+			findErr: gorm.ErrRecordNotFound,
+			wantErr: ErrScheduleNotFound,
+		},
+		{
+			name:    "access denied",
+			userID:  2,
+			wantErr: ErrScheduleAccessDenied,
+		},
+		{
+			name:       "success",
+			userID:     1,
+			wantStatus: database.ScheduleStatusPaused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotStatus database.ScheduleStatus
+			scheduleRepo := &MockScheduleRepository{
+				FindScheduleByIDFunc: func(scheduleID uint) (*database.Schedule, error) {
+					if tt.findErr != nil {
+						return nil, tt.findErr
+					}
+					return &database.Schedule{ID: scheduleID, CreatedBy: 1, CronExpr: "@hourly"}, nil
+				},
+				UpdateScheduleStatusFunc: func(scheduleID uint, status database.ScheduleStatus, nextRunAt time.Time) error {
+					gotStatus = status
+					return nil
+				},
+			}
+			service := NewScheduleServiceWithRepo(scheduleRepo, &MockTaskServiceForStale{})
+
+			err := service.PauseSchedule(10, tt.userID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantStatus, gotStatus)
+		})
+	}
+}
+
+func TestScheduleService_RunDueSchedules(t *testing.T) {
+	config.App = &config.Config{
+		Schedule: config.ScheduleConfig{
+			MisfireGraceSeconds: 60,
+		},
+	}
+
+	tests := []struct {
+		name          string
+		missedTicks   database.MissedTickPolicy
+		behind        time.Duration
+		claimed       bool
+		wantPublished int
+	}{
+		{
+			name:          "on time tick is published",
+			missedTicks:   database.MissedTickPolicySkip,
+			behind:        10 * time.Second,
+			claimed:       true,
+			wantPublished: 1,
+		},
+		{
+			name:          "skip drops ticks missed during downtime",
+			missedTicks:   database.MissedTickPolicySkip,
+			behind:        5*time.Hour + 10*time.Minute,
+			claimed:       true,
+			wantPublished: 0,
+		},
+		{
+			name:          "catch up publishes every missed tick",
+			missedTicks:   database.MissedTickPolicyCatchUp,
+			behind:        5*time.Hour + 10*time.Minute,
+			claimed:       true,
+			wantPublished: 6,
+		},
+		{
+			name:          "tick claimed by another instance",
+			missedTicks:   database.MissedTickPolicySkip,
+			behind:        10 * time.Second,
+			claimed:       false,
+			wantPublished: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextRunAt := time.Now().Add(-tt.behind)
+			var advancedTo time.Time
+			scheduleRepo := &MockScheduleRepository{
+				FindDueSchedulesFunc: func(now time.Time) ([]database.Schedule, error) {
+					return []database.Schedule{
+						{
+							ID:          1,
+							WasmModule:  "module",
+							Func:        "testFunc",
+							Args:        `[1,2]`,
+							CronExpr:    "@every 1h",
+							MissedTicks: tt.missedTicks,
+							Status:      database.ScheduleStatusActive,
+							NextRunAt:   nextRunAt,
+							CreatedBy:   1,
+						},
+					}, nil
+				},
+				AdvanceScheduleFunc: func(scheduleID uint, expectedNextRunAt time.Time, nextRunAt time.Time, lastRunAt *time.Time) (bool, error) {
+					advancedTo = nextRunAt
+					return tt.claimed, nil
+				},
+			}
+
+			published := 0
+			taskService := &MockTaskServiceForStale{
+				PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+					published++
+					return uint(published), nil
+				},
+			}
+
+			service := NewScheduleServiceWithRepo(scheduleRepo, taskService)
+
+			count, err := service.RunDueSchedules()
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantPublished, count)
+			assert.Equal(t, tt.wantPublished, published)
+			assert.True(t, advancedTo.After(time.Now()), "next run should be moved into the future")
+		})
+	}
+}
+
+func TestScheduleService_RunDueSchedules_PublishFailure(t *testing.T) {
+	config.App = &config.Config{
+		Schedule: config.ScheduleConfig{
+			MisfireGraceSeconds: 60,
+		},
+	}
+
+	nextRunAt := time.Now().Add(-(5*time.Hour + 10*time.Minute)).Truncate(time.Second)
+	type advance struct {
+		expected  time.Time
+		next      time.Time
+		lastRunAt *time.Time
+	}
+	var advances []advance
+	scheduleRepo := &MockScheduleRepository{
+		FindDueSchedulesFunc: func(now time.Time) ([]database.Schedule, error) {
+			return []database.Schedule{
+				{
+					ID:          1,
+					WasmModule:  "module",
+					Func:        "testFunc",
+					CronExpr:    "@every 1h",
+					MissedTicks: database.MissedTickPolicyCatchUp,
+					Status:      database.ScheduleStatusActive,
+					NextRunAt:   nextRunAt,
+					CreatedBy:   1,
+				},
+			}, nil
+		},
+		AdvanceScheduleFunc: func(scheduleID uint, expectedNextRunAt time.Time, nextRunAt time.Time, lastRunAt *time.Time) (bool, error) {
+			advances = append(advances, advance{expected: expectedNextRunAt, next: nextRunAt, lastRunAt: lastRunAt})
+			return true, nil
+		},
+	}
+
+	published := 0
+	taskService := &MockTaskServiceForStale{
+		PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+			if published == 2 {
+				return 0, errors.New("database error")
+			}
+			published++
+			return uint(published), nil
+		},
+	}
+
+	service := NewScheduleServiceWithRepo(scheduleRepo, taskService)
+
+	count, err := service.RunDueSchedules()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	if assert.Len(t, advances, 2) {
+		assert.Nil(t, advances[0].lastRunAt, "the claim should not record a run before anything is published")
+		assert.Equal(t, advances[0].next, advances[1].expected, "the rollback should only apply to our own claim")
+		assert.Equal(t, nextRunAt.Add(2*time.Hour), advances[1].next, "the tick that failed and those after it should run on the next check")
+		assert.NotNil(t, advances[1].lastRunAt)
+	}
+}
+
+func TestScheduleService_RunDueSchedules_HandBack(t *testing.T) {
+	config.App = &config.Config{
+		Schedule: config.ScheduleConfig{
+			MisfireGraceSeconds: 60,
+		},
+	}
+
+	tests := []struct {
+		name         string
+		handBack     []error
+		handBackLost bool
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "database error is retried",
+			handBack:     []error{errors.New("database error"), nil},
+			wantAttempts: 2,
+		},
+		{
+			name:         "schedule changed since the claim",
+			handBack:     []error{nil},
+			handBackLost: true,
+			wantAttempts: 1,
+			wantErr:      ErrScheduleTicksLost,
+		},
+		{
+			name:         "database error persists",
+			handBack:     []error{errors.New("database error"), errors.New("database error"), errors.New("database error")},
+			wantAttempts: scheduleHandBackAttempts,
+			wantErr:      ErrScheduleTicksLost,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextRunAt := time.Now().Add(-(2*time.Hour + 10*time.Minute)).Truncate(time.Second)
+			calls := 0
+			scheduleRepo := &MockScheduleRepository{
+				FindDueSchedulesFunc: func(now time.Time) ([]database.Schedule, error) {
+					return []database.Schedule{
+						{
+							ID:          1,
+							WasmModule:  "module",
+							Func:        "testFunc",
+							CronExpr:    "@every 1h",
+							MissedTicks: database.MissedTickPolicyCatchUp,
+							Status:      database.ScheduleStatusActive,
+							NextRunAt:   nextRunAt,
+							CreatedBy:   1,
+						},
+					}, nil
+				},
+				AdvanceScheduleFunc: func(scheduleID uint, expectedNextRunAt time.Time, nextRunAt time.Time, lastRunAt *time.Time) (bool, error) {
+					calls++
+					if calls == 1 {
+						return true, nil
+					}
+					err := tt.handBack[calls-2]
+					return err == nil && !tt.handBackLost, err
+				},
+			}
+			taskService := &MockTaskServiceForStale{
+				PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+					return 0, errors.New("database error")
+				},
+			}
+			service := NewScheduleServiceWithRepo(scheduleRepo, taskService)
+
+			count, err := service.RunDueSchedules()
+
+			assert.Equal(t, 0, count)
+			assert.Equal(t, tt.wantAttempts, calls-1)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"rainchanel.com/internal/config"
+)
+
+type SchedulerService interface {
+	Start(ctx context.Context)
+}
+
+type schedulerService struct {
+	scheduleService ScheduleService
+}
+
+func NewSchedulerService(scheduleService ScheduleService) SchedulerService {
+	return &schedulerService{
+		scheduleService: scheduleService,
+	}
+}
+
+func (s *schedulerService) Start(ctx context.Context) {
+	interval := time.Duration(config.App.Schedule.CheckIntervalSeconds) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logrus.WithFields(logrus.Fields{
+		"check_interval_seconds": config.App.Schedule.CheckIntervalSeconds,
+	}).Info("Schedule service started")
+
+	s.runDueSchedules()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Info("Schedule service stopped")
+			return
+		case <-ticker.C:
+			s.runDueSchedules()
+		}
+	}
+}
+
+func (s *schedulerService) runDueSchedules() {
+	publishedCount, err := s.scheduleService.RunDueSchedules()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("Error running due schedules")
+	}
+	if publishedCount > 0 {
+		logrus.WithFields(logrus.Fields{
+			"count": publishedCount,
+		}).Info("Published scheduled tasks")
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rainchanel.com/internal/config"
+	"rainchanel.com/internal/dto"
+)
+
+type MockScheduleServiceForScheduler struct {
+	RunDueSchedulesFunc func() (int, error)
+}
+
+func (m *MockScheduleServiceForScheduler) CreateSchedule(schedule dto.Schedule, createdBy uint) (*dto.Schedule, error) {
+	return nil, nil
+}
+func (m *MockScheduleServiceForScheduler) ListSchedules(userID uint) ([]dto.Schedule, error) {
+	return nil, nil
+}
+func (m *MockScheduleServiceForScheduler) PauseSchedule(scheduleID uint, userID uint) error {
+	return nil
+}
+func (m *MockScheduleServiceForScheduler) ResumeSchedule(scheduleID uint, userID uint) error {
+	return nil
+}
+func (m *MockScheduleServiceForScheduler) DeleteSchedule(scheduleID uint, userID uint) error {
+	return nil
+}
+
+func (m *MockScheduleServiceForScheduler) RunDueSchedules() (int, error) {
+	if m.RunDueSchedulesFunc != nil {
+		return m.RunDueSchedulesFunc()
+	}
+	return 0, nil
+}
+
+func TestSchedulerService_Start(t *testing.T) {
+	config.App = &config.Config{
+		Schedule: config.ScheduleConfig{
+			CheckIntervalSeconds: 1,
+		},
+	}
+
+	callCount := 0
+	scheduleService := &MockScheduleServiceForScheduler{
+		RunDueSchedulesFunc: func() (int, error) {
+			callCount++
+			return 0, nil
+		},
+	}
+
+	service := NewSchedulerService(scheduleService)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan bool)
+	go func() {
+		service.Start(ctx)
+		done <- true
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Fatal("Service did not stop within timeout")
+	}
+
+	assert.GreaterOrEqual(t, callCount, 1, "RunDueSchedules should be called at least once on start")
+}
@@ -50,11 +50,32 @@ func (s *staleTaskService) checkAndReclaimStaleTasks() {
 		logrus.WithFields(logrus.Fields{
 			"error": err.Error(),
 		}).Error("Error reclaiming stale tasks")
-		return
-	}
-	if reclaimedCount > 0 {
+	} else if reclaimedCount > 0 {
 		logrus.WithFields(logrus.Fields{
 			"count": reclaimedCount,
 		}).Info("Reclaimed stale tasks")
 	}
+
+	overdueCount, err := s.taskService.FailOverdueTasks()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("Error failing overdue tasks")
+	} else if overdueCount > 0 {
+		logrus.WithFields(logrus.Fields{
+			"count": overdueCount,
+		}).Info("Failed tasks past their deadline")
+	}
+
+	expiredCount, err := s.taskService.ExpireTasks()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Error("Error expiring unclaimed tasks")
+	}
+	if expiredCount > 0 {
+		logrus.WithFields(logrus.Fields{
+			"count": expiredCount,
+		}).Info("Expired tasks that were not claimed within their TTL")
+	}
 }
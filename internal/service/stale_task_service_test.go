@@ -8,29 +8,102 @@ import (
 	"github.com/stretchr/testify/assert"
 	"rainchanel.com/internal/config"
 	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
 )
 
 type MockTaskServiceForStale struct {
+	PublishTaskFunc       func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error)
 	ReclaimStaleTasksFunc func() (int, error)
+	FailOverdueTasksFunc  func() (int, error)
+	ExpireTasksFunc       func() (int, error)
+	CancelTaskFunc        func(taskID uint, userID uint) error
+	RequeueTaskFunc       func(taskID uint, userID uint) error
+
+	// BatchRepos are handed to PublishTaskBatch's build function, which
+	// publishes through PublishTaskFunc.
+	BatchRepos repository.Repositories
 }
 
-func (m *MockTaskServiceForStale) PublishTask(task dto.Task, createdBy uint) (uint, error) {
+func (m *MockTaskServiceForStale) PublishTask(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+	if m.PublishTaskFunc != nil {
+		return m.PublishTaskFunc(task, createdBy, opts)
+	}
 	return 0, nil
 }
-func (m *MockTaskServiceForStale) ConsumeTask() (*dto.Task, error) { return nil, nil }
-func (m *MockTaskServiceForStale) PublishResult(taskID uint, createdBy uint, processedBy uint, result string) error {
+func (m *MockTaskServiceForStale) PublishTaskBatch(createdBy uint, build func(repos repository.Repositories, publish TaskPublisher) error) error {
+	return build(m.BatchRepos, func(tasks []dto.Task, opts []dto.PublishOptions) ([]uint, error) {
+		taskIDs := make([]uint, 0, len(tasks))
+		for i, task := range tasks {
+			taskID, err := m.PublishTask(task, createdBy, opts[i])
+			if err != nil {
+				return nil, err
+			}
+			taskIDs = append(taskIDs, taskID)
+		}
+		return taskIDs, nil
+	})
+}
+func (m *MockTaskServiceForStale) ConsumeTask(workerID uint, queues []string) (*dto.ClaimedTask, error) {
+	return nil, nil
+}
+func (m *MockTaskServiceForStale) ConsumeTasks(workerID uint, queues []string, maxTasks int) ([]*dto.ClaimedTask, error) {
+	return nil, nil
+}
+func (m *MockTaskServiceForStale) ConsumeTasksWait(ctx context.Context, workerID uint, queues []string, maxTasks int, wait time.Duration) ([]*dto.ClaimedTask, error) {
+	return nil, nil
+}
+func (m *MockTaskServiceForStale) PublishResult(taskID uint, createdBy uint, processedBy uint, claimToken string, result string) error {
 	return nil
 }
-func (m *MockTaskServiceForStale) PublishFailure(taskID uint, createdBy uint, processedBy uint, errorMsg string) error {
+func (m *MockTaskServiceForStale) PublishFailure(taskID uint, createdBy uint, processedBy uint, claimToken string, failure dto.Failure) error {
+	return nil
+}
+func (m *MockTaskServiceForStale) ConsumeResult(userID uint) (*dto.LeasedResult, error) {
+	return nil, nil
+}
+func (m *MockTaskServiceForStale) ConsumeResults(userID uint, maxResults int) ([]*dto.LeasedResult, error) {
+	return nil, nil
+}
+func (m *MockTaskServiceForStale) AckResult(resultID uint, userID uint, receipt string) error {
 	return nil
 }
-func (m *MockTaskServiceForStale) ConsumeResult(userID uint) (*dto.Result, error) { return nil, nil }
 func (m *MockTaskServiceForStale) ReclaimStaleTasks() (int, error) {
 	if m.ReclaimStaleTasksFunc != nil {
 		return m.ReclaimStaleTasksFunc()
 	}
 	return 0, nil
 }
+func (m *MockTaskServiceForStale) FailOverdueTasks() (int, error) {
+	if m.FailOverdueTasksFunc != nil {
+		return m.FailOverdueTasksFunc()
+	}
+	return 0, nil
+}
+func (m *MockTaskServiceForStale) ExpireTasks() (int, error) {
+	if m.ExpireTasksFunc != nil {
+		return m.ExpireTasksFunc()
+	}
+	return 0, nil
+}
+
+func (m *MockTaskServiceForStale) CancelTask(taskID uint, userID uint) error {
+	if m.CancelTaskFunc != nil {
+		return m.CancelTaskFunc(taskID, userID)
+	}
+	return nil
+}
+func (m *MockTaskServiceForStale) RequeueTask(taskID uint, userID uint) error {
+	if m.RequeueTaskFunc != nil {
+		return m.RequeueTaskFunc(taskID, userID)
+	}
+	return nil
+}
+func (m *MockTaskServiceForStale) Heartbeat(taskID uint, claimToken string) (time.Time, error) {
+	return time.Time{}, nil
+}
+func (m *MockTaskServiceForStale) ReleaseTask(taskID uint, workerID uint, claimToken string, delay time.Duration, reason string) (time.Time, error) {
+	return time.Time{}, nil
+}
 
 func TestNewStaleTaskService(t *testing.T) {
 	mockTaskService := &MockTaskServiceForStale{}
@@ -155,4 +228,75 @@ func TestStaleTaskService_Start(t *testing.T) {
 
 		assert.True(t, true, "Service should handle errors gracefully")
 	})
+
+	t.Run("fails overdue tasks even when reclaim errors", func(t *testing.T) {
+		overdueCalled := make(chan bool, 1)
+		mockTaskService := &MockTaskServiceForStale{
+			ReclaimStaleTasksFunc: func() (int, error) {
+				return 0, assert.AnError
+			},
+			FailOverdueTasksFunc: func() (int, error) {
+				select {
+				case overdueCalled <- true:
+				default:
+				}
+				return 1, nil
+			},
+		}
+
+		service := NewStaleTaskService(mockTaskService)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		done := make(chan bool)
+		go func() {
+			service.Start(ctx)
+			done <- true
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+
+		<-done
+
+		select {
+		case <-overdueCalled:
+		default:
+			t.Error("FailOverdueTasks should be called on every check")
+		}
+	})
+
+	t.Run("expires unclaimed tasks", func(t *testing.T) {
+		expireCalled := make(chan bool, 1)
+		mockTaskService := &MockTaskServiceForStale{
+			ExpireTasksFunc: func() (int, error) {
+				select {
+				case expireCalled <- true:
+				default:
+				}
+				return 2, nil
+			},
+		}
+
+		service := NewStaleTaskService(mockTaskService)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		done := make(chan bool)
+		go func() {
+			service.Start(ctx)
+			done <- true
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+
+		<-done
+
+		select {
+		case <-expireCalled:
+		default:
+			t.Error("ExpireTasks should be called on every check")
+		}
+	})
 }
@@ -1,10 +1,16 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -19,20 +25,81 @@ import (
 var ErrNoTasksAvailable = errors.New("no tasks available")
 var ErrTaskNotFound = errors.New("task not found")
 var ErrInvalidCreatedBy = errors.New("created_by does not match task record")
+var ErrTaskAccessDenied = errors.New("task does not belong to user")
+var ErrTaskCancelled = errors.New("task cancelled")
+var ErrTaskNotCancellable = errors.New("task is already finished")
+var ErrTaskNotFailed = errors.New("task has not failed")
+var ErrTaskNotClaimed = errors.New("claim token does not match the current claim")
+var ErrInvalidQueue = errors.New("invalid queue name")
+var ErrInvalidPriority = errors.New("invalid task priority")
+var ErrInvalidBatchSize = errors.New("invalid batch size")
+var ErrInvalidWait = errors.New("invalid wait duration")
+var ErrIdempotencyKeyConflict = errors.New("idempotency key conflict")
+var ErrInvalidTimeout = errors.New("invalid task timeout")
+var ErrInvalidDeadline = errors.New("invalid task deadline")
+var ErrDeadlineExceeded = errors.New("task deadline exceeded")
+var ErrInvalidTTL = errors.New("invalid task ttl")
+var ErrInvalidReleaseDelay = errors.New("invalid release delay")
+var ErrDuplicateResult = errors.New("task already has a result")
+var ErrResultNotFound = errors.New("result not found")
+var ErrResultAccessDenied = errors.New("result does not belong to user")
+var ErrResultNotLeased = errors.New("receipt does not match the current lease")
+
+// ErrInvalidTransition is returned when a task's status changed underneath a
+// request, for example when two workers report on the same task at once.
+var ErrInvalidTransition = repository.ErrInvalidTransition
+
+// Error codes recorded for failures the server detects itself rather than
+// ones reported by a worker.
+const (
+	ErrorCodeLeaseExpired       = "lease_expired"
+	ErrorCodeInvalidReference   = "invalid_reference"
+	ErrorCodeArgumentResolution = "argument_resolution"
+	ErrorCodeDependencyFailed   = "dependency_failed"
+)
+
+const MaxTaskPriority = 100
+const MaxClaimBatchSize = 100
+const MaxResultBatchSize = 100
+const MaxConsumeWait = 60 * time.Second
+const MaxReleaseDelay = 24 * time.Hour
+
+// Tasks can become claimable without a publish (retry backoff, run_at, or a
+// publish on another instance), so long-polls also re-check on this interval.
+const consumeWaitRecheckInterval = 5 * time.Second
+
+var queueNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,99}$`)
+
+// TaskPublisher stores tasks, with opts[i] applying to tasks[i], and returns
+// their ids in the same order.
+type TaskPublisher func(tasks []dto.Task, opts []dto.PublishOptions) ([]uint, error)
 
 type TaskService interface {
-	PublishTask(task dto.Task, createdBy uint) (uint, error)
-	ConsumeTask() (*dto.Task, error)
-	PublishResult(taskID uint, createdBy uint, processedBy uint, result string) error
-	PublishFailure(taskID uint, createdBy uint, processedBy uint, errorMsg string) error
-	ConsumeResult(userID uint) (*dto.Result, error)
+	PublishTask(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error)
+	PublishTaskBatch(createdBy uint, build func(repos repository.Repositories, publish TaskPublisher) error) error
+	ConsumeTask(workerID uint, queues []string) (*dto.ClaimedTask, error)
+	ConsumeTasks(workerID uint, queues []string, maxTasks int) ([]*dto.ClaimedTask, error)
+	ConsumeTasksWait(ctx context.Context, workerID uint, queues []string, maxTasks int, wait time.Duration) ([]*dto.ClaimedTask, error)
+	PublishResult(taskID uint, createdBy uint, processedBy uint, claimToken string, result string) error
+	PublishFailure(taskID uint, createdBy uint, processedBy uint, claimToken string, failure dto.Failure) error
+	ConsumeResult(userID uint) (*dto.LeasedResult, error)
+	ConsumeResults(userID uint, maxResults int) ([]*dto.LeasedResult, error)
+	AckResult(resultID uint, userID uint, receipt string) error
 	ReclaimStaleTasks() (int, error)
+	FailOverdueTasks() (int, error)
+	ExpireTasks() (int, error)
+	CancelTask(taskID uint, userID uint) error
+	RequeueTask(taskID uint, userID uint) error
+	Heartbeat(taskID uint, claimToken string) (time.Time, error)
+	ReleaseTask(taskID uint, workerID uint, claimToken string, delay time.Duration, reason string) (time.Time, error)
 }
 
 type taskService struct {
 	taskRepo   repository.TaskRepository
 	auditRepo  repository.TaskAuditRepository
 	resultRepo repository.ResultRepository
+	uow        repository.UnitOfWork
+	notifier   *taskNotifier
 }
 
 func NewTaskService() TaskService {
@@ -40,82 +107,684 @@ func NewTaskService() TaskService {
 		taskRepo:   repository.NewTaskRepository(),
 		auditRepo:  repository.NewTaskAuditRepository(),
 		resultRepo: repository.NewResultRepository(),
+		uow:        repository.NewUnitOfWork(),
+		notifier:   newTaskNotifier(),
 	}
 }
 
-func NewTaskServiceWithRepos(taskRepo repository.TaskRepository, auditRepo repository.TaskAuditRepository, resultRepo repository.ResultRepository) TaskService {
+func NewTaskServiceWithRepos(taskRepo repository.TaskRepository, auditRepo repository.TaskAuditRepository, resultRepo repository.ResultRepository, uow repository.UnitOfWork) TaskService {
 	return &taskService{
 		taskRepo:   taskRepo,
 		auditRepo:  auditRepo,
 		resultRepo: resultRepo,
+		uow:        uow,
+		notifier:   newTaskNotifier(),
 	}
 }
 
-func (s *taskService) PublishTask(task dto.Task, createdBy uint) (uint, error) {
+func validateTaskDefinition(task *dto.Task) error {
+	if err := validateTaskPlacement(task); err != nil {
+		return err
+	}
 
 	if err := validation.ValidateTask(task.WasmModule, task.Func, task.Args); err != nil {
-		return 0, fmt.Errorf("task validation failed: %w", err)
+		return fmt.Errorf("task validation failed: %w", err)
 	}
 
+	return nil
+}
+
+func validateTaskPlacement(task *dto.Task) error {
+	if task.Queue == "" {
+		task.Queue = database.DefaultQueue
+	}
+	if !queueNamePattern.MatchString(task.Queue) {
+		return fmt.Errorf("%w: %q", ErrInvalidQueue, task.Queue)
+	}
+	if task.Priority < 0 || task.Priority > MaxTaskPriority {
+		return fmt.Errorf("%w: must be between 0 and %d", ErrInvalidPriority, MaxTaskPriority)
+	}
+	if err := validateRetryPolicy(task.Retry); err != nil {
+		return err
+	}
+	if task.TimeoutSeconds != 0 {
+		maxTimeout := config.App.Task.MaxTimeoutSeconds
+		if task.TimeoutSeconds < 0 || task.TimeoutSeconds > maxTimeout {
+			return fmt.Errorf("%w: timeout_seconds must be between 1 and %d", ErrInvalidTimeout, maxTimeout)
+		}
+	}
+	if task.Deadline != nil && !task.Deadline.After(time.Now()) {
+		return fmt.Errorf("%w: deadline must be in the future", ErrInvalidDeadline)
+	}
+	if task.TTLSeconds < 0 {
+		return fmt.Errorf("%w: ttl_seconds must not be negative", ErrInvalidTTL)
+	}
+
+	return nil
+}
+
+func (s *taskService) PublishTask(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+
+	if err := validateTaskDefinition(&task); err != nil {
+		return 0, err
+	}
+
+	// The request is hashed before a delay is turned into a run time.
+	var requestHash string
+	if opts.IdempotencyKey != "" {
+		hash, err := taskRequestHash(task, opts)
+		if err != nil {
+			return 0, err
+		}
+		requestHash = hash
+	}
+	if opts.Delay > 0 {
+		runAt := time.Now().Add(opts.Delay)
+		opts.RunAt = &runAt
+	}
+	if task.Deadline != nil && opts.RunAt != nil && !opts.RunAt.Before(*task.Deadline) {
+		return 0, fmt.Errorf("%w: deadline must be after run_at", ErrInvalidDeadline)
+	}
+
+	referencedTaskIDs, err := referencedTasks(s.taskRepo, task.Args, createdBy)
+	if err != nil {
+		return 0, err
+	}
+	for _, taskID := range referencedTaskIDs {
+		if !slices.Contains(opts.DependsOn, taskID) {
+			opts.DependsOn = append(opts.DependsOn, taskID)
+		}
+	}
+
+	if opts.IdempotencyKey == "" {
+		return s.createTask(task, createdBy, opts, nil)
+	}
+
+	record := &database.IdempotencyKey{
+		UserID:      createdBy,
+		Key:         opts.IdempotencyKey,
+		RequestHash: requestHash,
+		ExpiresAt:   time.Now().Add(time.Duration(config.App.Task.IdempotencyWindowSeconds) * time.Second),
+	}
+	return s.createTask(task, createdBy, opts, record)
+}
+
+// reserveIdempotencyKey inserts record, or returns the live record that already
+// holds the same key. An expired holder is replaced. It runs in the same
+// transaction as the task creation, so a key is never left without its task.
+func reserveIdempotencyKey(tasks repository.TaskRepository, record *database.IdempotencyKey) (*database.IdempotencyKey, error) {
+	for attempt := 0; ; attempt++ {
+		err := tasks.CreateIdempotencyKey(record)
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+
+		existing, err := tasks.FindIdempotencyKey(record.UserID, record.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find idempotency key: %w", err)
+		}
+		if existing.ExpiresAt.After(time.Now()) || attempt > 0 {
+			return existing, nil
+		}
+		if err := tasks.DeleteIdempotencyKey(existing.ID); err != nil {
+			return nil, fmt.Errorf("failed to delete expired idempotency key: %w", err)
+		}
+	}
+}
+
+// existingIdempotentTask returns the task of a publish request that already
+// used the key, as long as it asked for the same task.
+func existingIdempotentTask(existing *database.IdempotencyKey, requestHash string, createdBy uint) (uint, error) {
+	if existing.RequestHash != requestHash {
+		return 0, fmt.Errorf("%w: key was already used for a different task", ErrIdempotencyKeyConflict)
+	}
+	if existing.TaskID == nil {
+		return 0, fmt.Errorf("%w: a request with this key is still in progress", ErrIdempotencyKeyConflict)
+	}
+	logrus.WithFields(logrus.Fields{
+		"task_id": *existing.TaskID,
+		"user_id": createdBy,
+	}).Info("Returning existing task for repeated idempotency key")
+	return *existing.TaskID, nil
+}
+
+// taskRequestHash identifies a publish request for its idempotency key. The
+// schedule is hashed as requested, so a retry with the same delay_seconds
+// still matches even though it runs later.
+func taskRequestHash(task dto.Task, opts dto.PublishOptions) (string, error) {
+	var runAt *time.Time
+	if opts.RunAt != nil {
+		utc := opts.RunAt.UTC()
+		runAt = &utc
+	}
+	payload, err := json.Marshal(struct {
+		WasmModule     string           `json:"wasm_module"`
+		Func           string           `json:"func"`
+		Args           interface{}      `json:"args"`
+		Queue          string           `json:"queue"`
+		Priority       int              `json:"priority"`
+		Retry          *dto.RetryPolicy `json:"retry"`
+		TimeoutSeconds int              `json:"timeout_seconds"`
+		Deadline       *time.Time       `json:"deadline"`
+		TTLSeconds     int              `json:"ttl_seconds"`
+		RunAt          *time.Time       `json:"run_at"`
+		DelaySeconds   float64          `json:"delay_seconds"`
+	}{task.WasmModule, task.Func, task.Args, task.Queue, task.Priority, task.Retry, task.TimeoutSeconds, task.Deadline, task.TTLSeconds, runAt, opts.Delay.Seconds()})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal task for idempotency check: %w", err)
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// createTask stores the task, its dependencies and its audit row in one
+// transaction. idempotencyKey (if any) is reserved and pointed at the new task
+// in the same transaction; if the key is already held, the task it points at
+// is returned and nothing is created.
+func (s *taskService) createTask(task dto.Task, createdBy uint, opts dto.PublishOptions, idempotencyKey *database.IdempotencyKey) (uint, error) {
+	dbTask, audit, err := newTaskRows(task, createdBy, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var existing *database.IdempotencyKey
+	err = s.uow.Do(func(repos repository.Repositories) error {
+		existing = nil
+		if idempotencyKey != nil {
+			held, err := reserveIdempotencyKey(repos.Tasks, idempotencyKey)
+			if err != nil {
+				return err
+			}
+			if held != nil {
+				existing = held
+				return nil
+			}
+		}
+
+		if err := repos.Tasks.CreateTask(dbTask); err != nil {
+			return fmt.Errorf("failed to create task in database: %w", err)
+		}
+		audit.TaskID = dbTask.ID
+
+		if len(opts.DependsOn) > 0 {
+			if err := repos.Tasks.CreateTaskDependencies(taskDependencies(dbTask.ID, opts.DependsOn)); err != nil {
+				return fmt.Errorf("failed to create task dependencies: %w", err)
+			}
+		}
+
+		if err := repos.Audits.CreateTaskAudit(audit); err != nil {
+			return fmt.Errorf("failed to create task audit: %w", err)
+		}
+
+		if idempotencyKey != nil {
+			if err := repos.Tasks.SetIdempotencyKeyTask(idempotencyKey.ID, dbTask.ID); err != nil {
+				return fmt.Errorf("failed to record task for idempotency key: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if existing != nil {
+		return existingIdempotentTask(existing, idempotencyKey.RequestHash, createdBy)
+	}
+
+	s.afterTasksCreated([]*database.TaskAudit{audit})
+	return dbTask.ID, nil
+}
+
+// PublishTaskBatch runs build in one transaction. Tasks handed to publish are
+// stored in batches as part of it, so a parent row that build creates through
+// repos is committed together with all of its tasks or not at all. The
+// modules and args of published tasks must already have been validated.
+func (s *taskService) PublishTaskBatch(createdBy uint, build func(repos repository.Repositories, publish TaskPublisher) error) error {
+	var created []*database.TaskAudit
+	err := s.uow.Do(func(repos repository.Repositories) error {
+		created = created[:0]
+		return build(repos, func(tasks []dto.Task, opts []dto.PublishOptions) ([]uint, error) {
+			audits, err := s.storeTasks(repos, tasks, createdBy, opts)
+			if err != nil {
+				return nil, err
+			}
+			created = append(created, audits...)
+
+			taskIDs := make([]uint, 0, len(audits))
+			for _, audit := range audits {
+				taskIDs = append(taskIDs, audit.TaskID)
+			}
+			return taskIDs, nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.afterTasksCreated(created)
+	return nil
+}
+
+// storeTasks writes tasks, their dependencies and their audit rows through
+// repos, inserting each kind of row in batches.
+func (s *taskService) storeTasks(repos repository.Repositories, tasks []dto.Task, createdBy uint, opts []dto.PublishOptions) ([]*database.TaskAudit, error) {
+	if len(opts) != len(tasks) {
+		return nil, fmt.Errorf("got publish options for %d of %d tasks", len(opts), len(tasks))
+	}
+
+	dbTasks := make([]*database.Task, 0, len(tasks))
+	audits := make([]*database.TaskAudit, 0, len(tasks))
+	dependsOn := make([][]uint, 0, len(tasks))
+	for i := range tasks {
+		task := tasks[i]
+		taskOpts := opts[i]
+		if err := validateTaskPlacement(&task); err != nil {
+			return nil, err
+		}
+		referencedTaskIDs, err := referencedTasks(repos.Tasks, task.Args, createdBy)
+		if err != nil {
+			return nil, err
+		}
+		taskOpts.DependsOn = slices.Clone(taskOpts.DependsOn)
+		for _, taskID := range referencedTaskIDs {
+			if !slices.Contains(taskOpts.DependsOn, taskID) {
+				taskOpts.DependsOn = append(taskOpts.DependsOn, taskID)
+			}
+		}
+
+		dbTask, audit, err := newTaskRows(task, createdBy, taskOpts)
+		if err != nil {
+			return nil, err
+		}
+		dbTasks = append(dbTasks, dbTask)
+		audits = append(audits, audit)
+		dependsOn = append(dependsOn, taskOpts.DependsOn)
+	}
+
+	if err := repos.Tasks.CreateTasks(dbTasks); err != nil {
+		return nil, fmt.Errorf("failed to create tasks in database: %w", err)
+	}
+
+	var dependencies []database.TaskDependency
+	for i, dbTask := range dbTasks {
+		audits[i].TaskID = dbTask.ID
+		dependencies = append(dependencies, taskDependencies(dbTask.ID, dependsOn[i])...)
+	}
+	if err := repos.Tasks.CreateTaskDependencies(dependencies); err != nil {
+		return nil, fmt.Errorf("failed to create task dependencies: %w", err)
+	}
+	if err := repos.Audits.CreateTaskAudits(audits); err != nil {
+		return nil, fmt.Errorf("failed to create task audits: %w", err)
+	}
+
+	return audits, nil
+}
+
+// newTaskRows builds the task and audit rows for a task being published.
+func newTaskRows(task dto.Task, createdBy uint, opts dto.PublishOptions) (*database.Task, *database.TaskAudit, error) {
+	queue := task.Queue
+
 	argsJSON, err := json.Marshal(task.Args)
 	if err != nil {
-		return 0, fmt.Errorf("failed to marshal task args: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal task args: %w", err)
+	}
+	retryPolicy, err := retryPolicyToDB(task.Retry)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	dbTask := &database.Task{
-		WasmModule: task.WasmModule,
-		Func:       task.Func,
-		Args:       string(argsJSON),
-		CreatedBy:  createdBy,
+		WasmModule:     task.WasmModule,
+		Func:           task.Func,
+		Args:           string(argsJSON),
+		Queue:          queue,
+		Priority:       task.Priority,
+		WorkflowID:     opts.WorkflowID,
+		WorkflowKey:    opts.WorkflowKey,
+		MapJobID:       opts.MapJobID,
+		MapIndex:       opts.MapIndex,
+		TaskGroupID:    opts.TaskGroupID,
+		ReducesGroupID: opts.ReducesGroupID,
+		Retry:          retryPolicy,
+		CreatedBy:      createdBy,
 	}
-	if err := s.taskRepo.CreateTask(dbTask); err != nil {
-		return 0, fmt.Errorf("failed to create task in database: %w", err)
+
+	publishedAt := time.Now()
+	audit := &database.TaskAudit{
+		Queue:          queue,
+		Status:         database.TaskStatusPending,
+		PublishedAt:    publishedAt,
+		EffectiveAt:    effectivePublishTime(publishedAt, task.Priority),
+		TimeoutSeconds: task.TimeoutSeconds,
+		Deadline:       task.Deadline,
+		TTLSeconds:     task.TTLSeconds,
+	}
+	if audit.TTLSeconds == 0 {
+		audit.TTLSeconds = config.App.Task.QueueTTLSeconds[queue]
+	}
+	availableAt := publishedAt
+	if opts.RunAt != nil && opts.RunAt.After(publishedAt) {
+		runAt := *opts.RunAt
+		audit.NextAttemptAt = &runAt
+		audit.RunAt = &runAt
+		audit.EffectiveAt = effectivePublishTime(runAt, task.Priority)
+		availableAt = runAt
 	}
 
-	taskID := dbTask.ID
+	if len(opts.DependsOn) > 0 {
+		audit.Status = database.TaskStatusBlocked
+	} else {
+		audit.ExpiresAt = taskExpiry(audit.TTLSeconds, availableAt)
+	}
 
-	audit := &database.TaskAudit{
-		TaskID:      taskID,
-		Status:      database.TaskStatusPending,
-		PublishedAt: time.Now(),
+	return dbTask, audit, nil
+}
+
+func taskDependencies(taskID uint, dependsOn []uint) []database.TaskDependency {
+	dependencies := make([]database.TaskDependency, 0, len(dependsOn))
+	for _, dependsOnTaskID := range dependsOn {
+		dependencies = append(dependencies, database.TaskDependency{
+			TaskID:          taskID,
+			DependsOnTaskID: dependsOnTaskID,
+		})
 	}
+	return dependencies
+}
 
-	if err := s.auditRepo.CreateTaskAudit(audit); err != nil {
-		return 0, fmt.Errorf("failed to create task audit: %w", err)
+// afterTasksCreated wakes waiting workers for new tasks that can run now, and
+// releases new blocked tasks whose dependencies have already finished.
+func (s *taskService) afterTasksCreated(audits []*database.TaskAudit) {
+	ready := false
+	for _, audit := range audits {
+		if audit.Status == database.TaskStatusBlocked {
+			// A dependency may have finished before this task was recorded
+			// as waiting on it, in which case nothing else will release it.
+			if _, err := s.resolveBlockedTask(audit.TaskID); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"task_id": audit.TaskID,
+					"error":   err.Error(),
+				}).Warn("Failed to check dependencies of new task")
+			}
+			continue
+		}
+		if audit.NextAttemptAt == nil {
+			ready = true
+		}
 	}
+	if ready {
+		s.notifier.Notify()
+	}
+}
 
-	return taskID, nil
+func (s *taskService) ConsumeTask(workerID uint, queues []string) (*dto.ClaimedTask, error) {
+	claimed, err := s.ConsumeTasks(workerID, queues, 1)
+	if err != nil {
+		return nil, err
+	}
+	return claimed[0], nil
 }
 
-func (s *taskService) ConsumeTask() (*dto.Task, error) {
+func (s *taskService) ConsumeTasks(workerID uint, queues []string, maxTasks int) ([]*dto.ClaimedTask, error) {
+	if maxTasks < 1 || maxTasks > MaxClaimBatchSize {
+		return nil, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidBatchSize, MaxClaimBatchSize)
+	}
+
+	claimTokens := make([]string, maxTasks)
+	for i := range claimTokens {
+		claimToken, err := newClaimToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate claim token: %w", err)
+		}
+		claimTokens[i] = claimToken
+	}
 
-	audit, err := s.auditRepo.FindAndClaimPendingTask()
+	audits, err := s.auditRepo.FindAndClaimPendingTasks(queues, workerID, claimTokens, leaseDuration())
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNoTasksAvailable
+		return nil, fmt.Errorf("failed to find and claim tasks: %w", err)
+	}
+	if len(audits) == 0 {
+		return nil, ErrNoTasksAvailable
+	}
+
+	claimed := make([]*dto.ClaimedTask, 0, len(audits))
+	for _, audit := range audits {
+		var args interface{}
+		if audit.Task.Args != "" {
+			if err := json.Unmarshal([]byte(audit.Task.Args), &args); err != nil {
+				s.abandonClaim(audit, fmt.Errorf("failed to unmarshal task args: %w", err))
+				continue
+			}
+		}
+		args, err := s.resolveTaskArgs(&audit.Task, args)
+		if err != nil {
+			s.abandonClaim(audit, err)
+			continue
+		}
+
+		claimedTask := &dto.ClaimedTask{
+			Task: dto.Task{
+				ID:             audit.Task.ID,
+				WasmModule:     taskWasmModule(&audit.Task),
+				Func:           audit.Task.Func,
+				Args:           args,
+				Queue:          audit.Task.Queue,
+				Priority:       audit.Task.Priority,
+				TimeoutSeconds: int(taskLeaseDuration(audit).Seconds()),
+				Deadline:       audit.Deadline,
+				CreatedBy:      audit.Task.CreatedBy,
+			},
+			ClaimToken: audit.ClaimToken,
+		}
+		if audit.LeaseExpiresAt != nil {
+			claimedTask.LeaseExpiresAt = *audit.LeaseExpiresAt
+		} else {
+			claimedTask.LeaseExpiresAt = time.Now().Add(taskLeaseDuration(audit))
+		}
+		claimed = append(claimed, claimedTask)
+	}
+	if len(claimed) == 0 {
+		return nil, ErrNoTasksAvailable
+	}
+
+	return claimed, nil
+}
+
+// referencedTasks checks that every task whose result is referenced from args
+// belongs to createdBy, and returns their ids so the new task can wait for them.
+func referencedTasks(tasks repository.TaskRepository, args interface{}, createdBy uint) ([]uint, error) {
+	references, err := findResultReferences(args)
+	if err != nil {
+		return nil, err
+	}
+
+	taskIDs := make([]uint, 0, len(references))
+	for _, reference := range references {
+		if slices.Contains(taskIDs, reference.TaskID) {
+			continue
+		}
+		task, err := tasks.FindTaskByID(reference.TaskID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to find referenced task: %w", err)
 		}
-		return nil, fmt.Errorf("failed to find and claim task: %w", err)
+		if err != nil || task.CreatedBy != createdBy {
+			return nil, fmt.Errorf("%w: task %d not found", ErrInvalidResultReference, reference.TaskID)
+		}
+		taskIDs = append(taskIDs, reference.TaskID)
 	}
 
-	var args interface{}
-	if audit.Task.Args != "" {
-		if err := json.Unmarshal([]byte(audit.Task.Args), &args); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal task args: %w", err)
+	return taskIDs, nil
+}
+
+// resolveTaskArgs replaces the result references in a claimed task's args
+// with the referenced results. Arguments that were references are only
+// checked against the function signature once they have been resolved.
+func (s *taskService) resolveTaskArgs(task *database.Task, args interface{}) (interface{}, error) {
+	if task.ReducesGroup != nil && task.ReducesGroup.OnFailure == database.GroupFailurePolicyReduceSuccesses {
+		completed, err := s.completedMemberArgs(args)
+		if err != nil {
+			return nil, err
 		}
+		args = completed
 	}
 
-	task := &dto.Task{
-		ID:         audit.Task.ID,
-		WasmModule: audit.Task.WasmModule,
-		Func:       audit.Task.Func,
-		Args:       args,
-		CreatedBy:  audit.Task.CreatedBy,
+	referenced := false
+	resolved, err := walkResultReferences(args, func(reference map[string]interface{}) (interface{}, error) {
+		referenced = true
+		parsed, err := parseResultReference(reference)
+		if err != nil {
+			return nil, err
+		}
+
+		dbResult, err := s.resultRepo.FindResultByTaskID(parsed.TaskID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: task %d has no result", ErrInvalidResultReference, parsed.TaskID)
+			}
+			return nil, fmt.Errorf("failed to find result of task %d: %w", parsed.TaskID, err)
+		}
+
+		var result interface{}
+		if err := json.Unmarshal([]byte(dbResult.Result), &result); err != nil {
+			return nil, fmt.Errorf("%w: result of task %d is not valid JSON", ErrInvalidResultReference, parsed.TaskID)
+		}
+		return resultAtPath(result, parsed.Path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !referenced {
+		return args, nil
 	}
 
-	return task, nil
+	if err := validation.ValidateTask(taskWasmModule(task), task.Func, resolved); err != nil {
+		return nil, fmt.Errorf("%w: resolved arguments: %v", ErrInvalidResultReference, err)
+	}
+	return resolved, nil
 }
 
-func (s *taskService) PublishResult(taskID uint, createdBy uint, processedBy uint, result string) error {
+// completedMemberArgs rearranges the args of a reduce_successes reduce task
+// for the members' current status: the references to members that completed
+// come first, in member order, followed by a 0 for every other member and the
+// number of members that completed.
+func (s *taskService) completedMemberArgs(args interface{}) (interface{}, error) {
+	members, ok := args.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: reduce task args must be a list", ErrInvalidResultReference)
+	}
+	memberIDs := make([]uint, len(members))
+	for i, member := range members {
+		reference, ok := member.(map[string]interface{})
+		if !ok || !validation.IsResultReference(reference) {
+			return nil, fmt.Errorf("%w: reduce task argument %d is not a result reference", ErrInvalidResultReference, i)
+		}
+		parsed, err := parseResultReference(reference)
+		if err != nil {
+			return nil, err
+		}
+		memberIDs[i] = parsed.TaskID
+	}
+
+	audits, err := s.auditRepo.FindTaskAuditsByTaskIDs(memberIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find group task audits: %w", err)
+	}
+	completed := make(map[uint]bool, len(audits))
+	for _, audit := range audits {
+		completed[audit.TaskID] = audit.Status == database.TaskStatusCompleted
+	}
+
+	rearranged := make([]interface{}, 0, len(members)+1)
+	for i, member := range members {
+		if completed[memberIDs[i]] {
+			rearranged = append(rearranged, member)
+		}
+	}
+	count := len(rearranged)
+	for len(rearranged) < len(members) {
+		rearranged = append(rearranged, 0)
+	}
+	return append(rearranged, count), nil
+}
+
+// abandonClaim gives up a claimed task whose args could not be resolved. A bad
+// reference fails the task for good; anything else sends it back for a retry.
+func (s *taskService) abandonClaim(audit *database.TaskAudit, cause error) {
+	errorMsg := fmt.Sprintf("Failed to resolve task arguments: %v", cause)
+
+	// A transient error gets the same retry budget as a failure reported by
+	// a worker, so an error that never clears does not requeue forever.
+	permanent := errors.Is(cause, ErrInvalidResultReference)
+	if permanent || audit.RetryCount >= taskMaxRetries(audit.Task.Retry) {
+		retryable := false
+		failure := dto.Failure{Code: ErrorCodeArgumentResolution, Message: errorMsg}
+		if permanent {
+			failure = dto.Failure{Code: ErrorCodeInvalidReference, Message: errorMsg, Retryable: &retryable}
+		} else {
+			errorMsg = fmt.Sprintf("Failed to resolve task arguments after %d retries: %v", audit.RetryCount, cause)
+		}
+		if err := s.failTask(audit, failure, errorMsg); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"task_id": audit.TaskID,
+				"error":   err.Error(),
+			}).Error("Failed to mark task with unresolvable arguments as failed")
+			return
+		}
+		logrus.WithFields(logrus.Fields{
+			"task_id": audit.TaskID,
+			"error":   cause.Error(),
+		}).Error("Task failed permanently: arguments could not be resolved")
+		s.resolveDependents(audit.TaskID)
+		return
+	}
+
+	nextAttemptAt := time.Now().Add(retryBackoff(audit.Task.Retry, audit.RetryCount))
+	failure := dto.Failure{Code: ErrorCodeArgumentResolution, Message: errorMsg}
+	if err := s.retryTask(audit, failure, errorMsg, nextAttemptAt); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"task_id": audit.TaskID,
+			"error":   err.Error(),
+		}).Error("Failed to release task after argument resolution error")
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"task_id":         audit.TaskID,
+		"next_attempt_at": nextAttemptAt,
+		"error":           cause.Error(),
+	}).Warn("Released task after argument resolution error")
+}
+
+func (s *taskService) ConsumeTasksWait(ctx context.Context, workerID uint, queues []string, maxTasks int, wait time.Duration) ([]*dto.ClaimedTask, error) {
+	if wait <= 0 || wait > MaxConsumeWait {
+		return nil, fmt.Errorf("%w: must be between 0 and %s", ErrInvalidWait, MaxConsumeWait)
+	}
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	recheck := time.NewTicker(consumeWaitRecheckInterval)
+	defer recheck.Stop()
+
+	for {
+		published := s.notifier.Wait()
+
+		claimed, err := s.ConsumeTasks(workerID, queues, maxTasks)
+		if !errors.Is(err, ErrNoTasksAvailable) {
+			return claimed, err
+		}
+
+		select {
+		case <-published:
+		case <-recheck.C:
+		case <-deadline.C:
+			return nil, ErrNoTasksAvailable
+		case <-ctx.Done():
+			return nil, ErrNoTasksAvailable
+		}
+	}
+}
+
+func (s *taskService) PublishResult(taskID uint, createdBy uint, processedBy uint, claimToken string, result string) error {
 	audit, err := s.auditRepo.FindTaskAuditByTaskID(taskID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -128,33 +797,42 @@ func (s *taskService) PublishResult(taskID uint, createdBy uint, processedBy uin
 		return ErrInvalidCreatedBy
 	}
 
-	if err := s.auditRepo.UpdateTaskAuditCompleted(taskID, processedBy); err != nil {
-		return fmt.Errorf("failed to update task audit: %w", err)
+	if audit.Status == database.TaskStatusCompleted {
+		return ErrDuplicateResult
 	}
-
-	dbResult := &database.Result{
-		TaskID:      taskID,
-		CreatedBy:   createdBy,
-		ProcessedBy: processedBy,
-		Result:      result,
+	if err := checkLeaseHolder(audit, claimToken); err != nil {
+		return err
 	}
 
-	if err := s.resultRepo.CreateResult(dbResult); err != nil {
+	err = s.uow.Do(func(repos repository.Repositories) error {
+		if err := repos.Audits.UpdateTaskAuditCompleted(taskID, processedBy, claimToken); err != nil {
+			return fmt.Errorf("failed to complete task: %w", err)
+		}
 
-		if rollbackErr := s.auditRepo.UpdateTaskAuditStatus(taskID, database.TaskStatusFailed); rollbackErr != nil {
-			logrus.WithFields(logrus.Fields{
-				"task_id":      taskID,
-				"rollback_err": rollbackErr.Error(),
-				"original_err": err.Error(),
-			}).Warn("Failed to rollback task status after result creation failure")
+		dbResult := &database.Result{
+			TaskID:      taskID,
+			CreatedBy:   createdBy,
+			ProcessedBy: &processedBy,
+			Status:      database.TaskStatusCompleted,
+			Result:      result,
 		}
-		return fmt.Errorf("failed to create result in database: %w", err)
+		if err := repos.Results.CreateResult(dbResult); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrDuplicateResult
+			}
+			return fmt.Errorf("failed to create result in database: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	s.resolveDependents(taskID)
 	return nil
 }
 
-func (s *taskService) PublishFailure(taskID uint, createdBy uint, processedBy uint, errorMsg string) error {
+func (s *taskService) PublishFailure(taskID uint, createdBy uint, processedBy uint, claimToken string, failure dto.Failure) error {
 	audit, err := s.auditRepo.FindTaskAuditByTaskID(taskID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -167,55 +845,80 @@ func (s *taskService) PublishFailure(taskID uint, createdBy uint, processedBy ui
 		return ErrInvalidCreatedBy
 	}
 
-	maxRetries := config.App.Task.MaxRetries
-	if audit.RetryCount < maxRetries {
+	if err := checkLeaseHolder(audit, claimToken); err != nil {
+		return err
+	}
+
+	maxRetries := taskMaxRetries(audit.Task.Retry)
+	permanent := failure.Retryable != nil && !*failure.Retryable
+	nonRetryable := isNonRetryable(audit.Task.Retry, failure.Code)
+	if audit.RetryCount < maxRetries && !permanent && !nonRetryable {
 
-		backoffSeconds := int(math.Pow(2, float64(audit.RetryCount)))
+		backoff := retryBackoff(audit.Task.Retry, audit.RetryCount)
+		nextAttemptAt := time.Now().Add(backoff)
+		if retryMissesDeadline(audit, nextAttemptAt) {
+			s.exceedDeadline(audit, fmt.Sprintf("Task failed and its deadline passes before the next attempt: %s", failure.Message), &failure)
+			return nil
+		}
 		errorMsgWithRetry := fmt.Sprintf("Task failed (attempt %d/%d): %s. Will retry after backoff.",
-			audit.RetryCount+1, maxRetries+1, errorMsg)
+			audit.RetryCount+1, maxRetries+1, failure.Message)
 
-		if err := s.auditRepo.ReclaimStaleTask(taskID, errorMsgWithRetry); err != nil {
-			return fmt.Errorf("failed to reclaim task for retry: %w", err)
+		if err := s.retryTask(audit, failure, errorMsgWithRetry, nextAttemptAt); err != nil {
+			return err
 		}
 
 		logrus.WithFields(logrus.Fields{
 			"task_id":         taskID,
 			"attempt":         audit.RetryCount + 1,
 			"max_retries":     maxRetries + 1,
-			"backoff_seconds": backoffSeconds,
-			"error":           errorMsg,
+			"backoff_seconds": backoff.Seconds(),
+			"next_attempt_at": nextAttemptAt,
+			"code":            failure.Code,
+			"error":           failure.Message,
 		}).Info("Task failed, retrying")
 		return nil
 	}
 
-	if err := s.auditRepo.UpdateTaskFailed(taskID, fmt.Sprintf("Task failed after %d retries: %s", maxRetries+1, errorMsg)); err != nil {
-		return fmt.Errorf("failed to update task as failed: %w", err)
+	var finalErrorMsg string
+	switch {
+	case permanent:
+		finalErrorMsg = fmt.Sprintf("Task failed with a permanent error: %s", failure.Message)
+	case nonRetryable:
+		finalErrorMsg = fmt.Sprintf("Task failed with non-retryable code %s: %s", failure.Code, failure.Message)
+	default:
+		finalErrorMsg = fmt.Sprintf("Task failed after %d retries: %s", maxRetries+1, failure.Message)
+	}
+	if err := s.failTask(audit, failure, finalErrorMsg); err != nil {
+		return err
 	}
 
 	logrus.WithFields(logrus.Fields{
 		"task_id":     taskID,
-		"retry_count": maxRetries + 1,
-		"error":       errorMsg,
+		"retry_count": audit.RetryCount + 1,
+		"code":        failure.Code,
+		"trap_kind":   failure.TrapKind,
+		"error":       failure.Message,
 	}).Error("Task failed permanently")
+
+	s.resolveDependents(taskID)
 	return nil
 }
 
 func (s *taskService) ReclaimStaleTasks() (int, error) {
-	timeoutDuration := time.Duration(config.App.Task.TimeoutSeconds) * time.Second
-	staleTasks, err := s.auditRepo.FindStaleTasks(timeoutDuration)
+	staleTasks, err := s.auditRepo.FindStaleTasks(leaseDuration())
 	if err != nil {
 		return 0, fmt.Errorf("failed to find stale tasks: %w", err)
 	}
 
 	reclaimedCount := 0
-	maxRetries := config.App.Task.MaxRetries
 
 	for _, audit := range staleTasks {
+		maxRetries := taskMaxRetries(audit.Task.Retry)
 		if audit.RetryCount >= maxRetries {
 
-			errorMsg := fmt.Sprintf("Task timed out after %d retries (exceeded %d seconds)",
-				audit.RetryCount, config.App.Task.TimeoutSeconds)
-			if err := s.auditRepo.UpdateTaskFailed(audit.TaskID, errorMsg); err != nil {
+			errorMsg := fmt.Sprintf("Task lease expired after %d retries (no heartbeat for %d seconds)",
+				audit.RetryCount, int(taskLeaseDuration(audit).Seconds()))
+			if err := s.failTask(audit, dto.Failure{Code: ErrorCodeLeaseExpired, Message: errorMsg}, errorMsg); err != nil {
 				logrus.WithFields(logrus.Fields{
 					"task_id": audit.TaskID,
 					"error":   err.Error(),
@@ -226,11 +929,18 @@ func (s *taskService) ReclaimStaleTasks() (int, error) {
 				"task_id":     audit.TaskID,
 				"retry_count": audit.RetryCount,
 			}).Warn("Marked stale task as failed (max retries exceeded)")
+			s.resolveDependents(audit.TaskID)
 		} else {
 
-			errorMsg := fmt.Sprintf("Task timed out (exceeded %d seconds), reclaiming for retry",
-				config.App.Task.TimeoutSeconds)
-			if err := s.auditRepo.ReclaimStaleTask(audit.TaskID, errorMsg); err != nil {
+			errorMsg := fmt.Sprintf("Task lease expired (no heartbeat for %d seconds), reclaiming for retry",
+				int(taskLeaseDuration(audit).Seconds()))
+			failure := dto.Failure{Code: ErrorCodeLeaseExpired, Message: errorMsg}
+			nextAttemptAt := time.Now().Add(retryBackoff(audit.Task.Retry, audit.RetryCount))
+			if retryMissesDeadline(audit, nextAttemptAt) {
+				s.exceedDeadline(audit, "Task lease expired and its deadline passes before the next attempt", &failure)
+				continue
+			}
+			if err := s.retryTask(audit, failure, errorMsg, nextAttemptAt); err != nil {
 				logrus.WithFields(logrus.Fields{
 					"task_id": audit.TaskID,
 					"error":   err.Error(),
@@ -249,34 +959,627 @@ func (s *taskService) ReclaimStaleTasks() (int, error) {
 	return reclaimedCount, nil
 }
 
-func (s *taskService) ConsumeResult(userID uint) (*dto.Result, error) {
+// FailOverdueTasks moves every unfinished task whose deadline has passed to
+// deadline_exceeded.
+func (s *taskService) FailOverdueTasks() (int, error) {
+	overdue, err := s.auditRepo.FindOverdueTasks(time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to find overdue tasks: %w", err)
+	}
 
-	dbResult, err := s.resultRepo.FindOldestUnconsumedResultByUserID(userID)
+	failedCount := 0
+	for _, audit := range overdue {
+		if s.exceedDeadline(audit, fmt.Sprintf("Task did not complete before its deadline (%s)", audit.Deadline.Format(time.RFC3339)), nil) {
+			failedCount++
+		}
+	}
+
+	return failedCount, nil
+}
+
+// exceedDeadline ends a task that can no longer finish in time and fails the
+// tasks that depend on it. A failed attempt that caused it is added to the
+// task's failure history in the same transaction. It reports whether the task
+// was still unfinished.
+func (s *taskService) exceedDeadline(audit *database.TaskAudit, errorMsg string, failure *dto.Failure) bool {
+	exceeded := false
+	err := s.uow.Do(func(repos repository.Repositories) error {
+		var err error
+		exceeded, err = repos.Audits.MarkDeadlineExceeded(audit.TaskID, errorMsg)
+		if err != nil || !exceeded || failure == nil {
+			return err
+		}
+		return recordFailure(repos.Audits, audit, *failure)
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"task_id": audit.TaskID,
+			"error":   err.Error(),
+		}).Error("Failed to mark task as past its deadline")
+		return false
+	}
+	if !exceeded {
+		return false
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"task_id":  audit.TaskID,
+		"deadline": audit.Deadline,
+		"reason":   errorMsg,
+	}).Warn("Task deadline exceeded")
+	s.resolveDependents(audit.TaskID)
+	return true
+}
+
+// ExpireTasks moves every pending task that has waited past its TTL without
+// being claimed to expired, and leaves an expired result for its producer.
+func (s *taskService) ExpireTasks() (int, error) {
+	expired, err := s.auditRepo.FindExpiredTasks(time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired tasks: %w", err)
+	}
+
+	expiredCount := 0
+	failedCount := 0
+	var firstErr error
+	for _, audit := range expired {
+		errorMsg := fmt.Sprintf("Task was not claimed within its TTL of %d seconds", audit.TTLSeconds)
+		ok := false
+		err := s.uow.Do(func(repos repository.Repositories) error {
+			var err error
+			ok, err = repos.Audits.ExpireTask(audit.TaskID, errorMsg)
+			if err != nil || !ok {
+				return err
+			}
+			return repos.Results.CreateResult(&database.Result{
+				TaskID:    audit.TaskID,
+				CreatedBy: audit.Task.CreatedBy,
+				Status:    database.TaskStatusExpired,
+				Result:    "null",
+			})
+		})
+		if err != nil {
+			// The task stays pending, so the next sweep retries it.
+			logrus.WithFields(logrus.Fields{
+				"task_id": audit.TaskID,
+				"error":   err.Error(),
+			}).Error("Failed to expire task")
+			failedCount++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		expiredCount++
+		logrus.WithFields(logrus.Fields{
+			"task_id":     audit.TaskID,
+			"queue":       audit.Queue,
+			"ttl_seconds": audit.TTLSeconds,
+		}).Warn("Task expired before any worker claimed it")
+		s.resolveDependents(audit.TaskID)
+	}
+
+	if firstErr != nil {
+		return expiredCount, fmt.Errorf("failed to expire %d of %d tasks: %w", failedCount, len(expired), firstErr)
+	}
+	return expiredCount, nil
+}
+
+func retryMissesDeadline(audit *database.TaskAudit, nextAttemptAt time.Time) bool {
+	return audit.Deadline != nil && !nextAttemptAt.Before(*audit.Deadline)
+}
+
+// Each priority point moves a task ahead of work published up to PriorityAgingSeconds
+// later, so a low-priority task eventually outranks any newly published task.
+func effectivePublishTime(publishedAt time.Time, priority int) time.Time {
+	aging := time.Duration(config.App.Task.PriorityAgingSeconds) * time.Second
+	return publishedAt.Add(-time.Duration(priority) * aging)
+}
+
+func (s *taskService) CancelTask(taskID uint, userID uint) error {
+	audit, err := s.auditRepo.FindTaskAuditByTaskID(taskID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNoTasksAvailable
+			return ErrTaskNotFound
 		}
-		return nil, fmt.Errorf("failed to find result: %w", err)
+		return fmt.Errorf("failed to find task audit: %w", err)
 	}
 
-	var resultData interface{}
-	if err := json.Unmarshal([]byte(dbResult.Result), &resultData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal result data: %w", err)
+	if audit.Task.CreatedBy != userID {
+		return ErrTaskAccessDenied
 	}
 
-	result := &dto.Result{
-		TaskID:    dbResult.TaskID,
-		CreatedBy: dbResult.CreatedBy,
-		Result:    resultData,
+	cancelled, err := s.auditRepo.CancelTask(taskID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel task: %w", err)
+	}
+	if !cancelled {
+		return ErrTaskNotCancellable
 	}
 
-	if err := s.resultRepo.MarkResultAsConsumed(dbResult.ID); err != nil {
+	logrus.WithFields(logrus.Fields{
+		"task_id":         taskID,
+		"previous_status": audit.Status,
+	}).Info("Task cancelled")
 
-		logrus.WithFields(logrus.Fields{
-			"result_id": dbResult.ID,
-			"error":     err.Error(),
-		}).Warn("Failed to mark result as consumed")
+	s.resolveDependents(taskID)
+	return nil
+}
+
+// RequeueTask gives a permanently failed task a fresh set of retries. A task
+// with dependencies goes back to waiting on them.
+func (s *taskService) RequeueTask(taskID uint, userID uint) error {
+	audit, err := s.auditRepo.FindTaskAuditByTaskID(taskID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTaskNotFound
+		}
+		return fmt.Errorf("failed to find task audit: %w", err)
 	}
 
-	return result, nil
+	if audit.Task.CreatedBy != userID {
+		return ErrTaskAccessDenied
+	}
+
+	dependencyIDs, err := s.taskRepo.FindTaskDependencyIDs(taskID)
+	if err != nil {
+		return fmt.Errorf("failed to find task dependencies: %w", err)
+	}
+	status := database.TaskStatusPending
+	if len(dependencyIDs) > 0 {
+		status = database.TaskStatusBlocked
+	}
+
+	now := time.Now()
+	var expiresAt *time.Time
+	if status == database.TaskStatusPending {
+		expiresAt = taskExpiry(audit.TTLSeconds, now)
+	}
+	requeued, err := s.auditRepo.RequeueFailedTask(taskID, status, effectivePublishTime(now, audit.Task.Priority), expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to requeue task: %w", err)
+	}
+	if !requeued {
+		return ErrTaskNotFailed
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"task_id": taskID,
+		"status":  status,
+	}).Info("Failed task requeued")
+
+	if status == database.TaskStatusBlocked {
+		if _, err := s.resolveBlockedTask(taskID); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"task_id": taskID,
+				"error":   err.Error(),
+			}).Warn("Failed to check dependencies of requeued task")
+		}
+		return nil
+	}
+
+	s.notifier.Notify()
+	return nil
+}
+
+// resolveDependents re-checks every task blocked on taskID after it has
+// finished, following failures down the graph.
+func (s *taskService) resolveDependents(taskID uint) {
+	finished := []uint{taskID}
+	for len(finished) > 0 {
+		parentID := finished[0]
+		finished = finished[1:]
+
+		dependentIDs, err := s.taskRepo.FindDependentTaskIDs(parentID)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"task_id": parentID,
+				"error":   err.Error(),
+			}).Error("Failed to find dependent tasks")
+			continue
+		}
+
+		for _, dependentID := range dependentIDs {
+			failed, err := s.resolveBlockedTask(dependentID)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"task_id":    dependentID,
+					"depends_on": parentID,
+					"error":      err.Error(),
+				}).Error("Failed to resolve blocked task")
+				continue
+			}
+			if failed {
+				finished = append(finished, dependentID)
+			}
+		}
+	}
+}
+
+// resolveBlockedTask releases a blocked task once all of its dependencies have
+// completed, or fails it as soon as one of them has failed or been cancelled.
+// The reduce task of a task group follows the group's failure policy instead.
+// It reports whether the task was failed.
+func (s *taskService) resolveBlockedTask(taskID uint) (bool, error) {
+	audit, err := s.auditRepo.FindTaskAuditByTaskID(taskID)
+	if err != nil {
+		return false, fmt.Errorf("failed to find task audit: %w", err)
+	}
+	if audit.Status != database.TaskStatusBlocked {
+		return false, nil
+	}
+
+	policy := database.GroupFailurePolicyAbort
+	if audit.Task.ReducesGroup != nil {
+		policy = audit.Task.ReducesGroup.OnFailure
+	}
+
+	dependencyIDs, err := s.taskRepo.FindTaskDependencyIDs(taskID)
+	if err != nil {
+		return false, fmt.Errorf("failed to find task dependencies: %w", err)
+	}
+	dependencies, err := s.auditRepo.FindTaskAuditsByTaskIDs(dependencyIDs)
+	if err != nil {
+		return false, fmt.Errorf("failed to find dependency audits: %w", err)
+	}
+
+	ready := len(dependencies) == len(dependencyIDs)
+	var skipped []uint
+	for _, dependency := range dependencies {
+		switch dependency.Status {
+		case database.TaskStatusCompleted:
+		case database.TaskStatusFailed, database.TaskStatusCancelled, database.TaskStatusDeadlineExceeded, database.TaskStatusExpired:
+			switch {
+			case policy == database.GroupFailurePolicyReduceSuccesses:
+				skipped = append(skipped, dependency.TaskID)
+			case policy == database.GroupFailurePolicyWaitForRetries && dependency.Status == database.TaskStatusFailed:
+				// The failed task may still be requeued, so keep waiting.
+				ready = false
+			default:
+				return s.failBlockedTask(audit, dependencies, fmt.Sprintf("Dependency task %d %s", dependency.TaskID, dependency.Status))
+			}
+		default:
+			ready = false
+		}
+	}
+	if !ready {
+		return false, nil
+	}
+
+	// The reduce task's args are left as they are; the members that did not
+	// complete are only left out when it is claimed.
+	if len(skipped) > 0 && len(skipped) == len(dependencies) {
+		return s.failBlockedTask(audit, dependencies, "No task in the group completed")
+	}
+
+	readyAt := time.Now()
+	if audit.NextAttemptAt != nil && audit.NextAttemptAt.After(readyAt) {
+		readyAt = *audit.NextAttemptAt
+	}
+	unblocked, err := s.auditRepo.UnblockTask(taskID, effectivePublishTime(readyAt, audit.Task.Priority), taskExpiry(audit.TTLSeconds, readyAt))
+	if err != nil {
+		return false, fmt.Errorf("failed to unblock task: %w", err)
+	}
+	if unblocked {
+		logrus.WithField("task_id", taskID).Info("Task dependencies completed, task is now pending")
+		s.notifier.Notify()
+	}
+	return false, nil
+}
+
+// failBlockedTask fails a blocked task whose dependencies can no longer all
+// complete. When it is the reduce task of an aborting group, the members that
+// are still unfinished are cancelled as well.
+func (s *taskService) failBlockedTask(audit *database.TaskAudit, dependencies []*database.TaskAudit, errorMsg string) (bool, error) {
+	failed, err := s.auditRepo.FailBlockedTask(audit.TaskID, ErrorCodeDependencyFailed, errorMsg)
+	if err != nil {
+		return false, fmt.Errorf("failed to fail blocked task: %w", err)
+	}
+	if !failed {
+		return false, nil
+	}
+	logrus.WithFields(logrus.Fields{
+		"task_id": audit.TaskID,
+		"reason":  errorMsg,
+	}).Warn("Failed blocked task because a dependency did not complete")
+
+	if audit.Task.ReducesGroup != nil && audit.Task.ReducesGroup.OnFailure == database.GroupFailurePolicyAbort {
+		for _, dependency := range dependencies {
+			if dependency.Status == database.TaskStatusCompleted ||
+				dependency.Status == database.TaskStatusFailed ||
+				dependency.Status == database.TaskStatusCancelled ||
+				dependency.Status == database.TaskStatusDeadlineExceeded ||
+				dependency.Status == database.TaskStatusExpired {
+				continue
+			}
+			if err := s.CancelTask(dependency.TaskID, audit.Task.CreatedBy); err != nil && !errors.Is(err, ErrTaskNotCancellable) {
+				logrus.WithFields(logrus.Fields{
+					"task_id":       dependency.TaskID,
+					"task_group_id": *audit.Task.ReducesGroupID,
+					"error":         err.Error(),
+				}).Warn("Failed to cancel task of aborted group")
+			}
+		}
+	}
+	return true, nil
+}
+
+func (s *taskService) Heartbeat(taskID uint, claimToken string) (time.Time, error) {
+	audit, err := s.auditRepo.FindTaskAuditByTaskID(taskID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, ErrTaskNotFound
+		}
+		return time.Time{}, fmt.Errorf("failed to find task audit: %w", err)
+	}
+	if err := checkLeaseHolder(audit, claimToken); err != nil {
+		return time.Time{}, err
+	}
+
+	leaseExpiresAt := time.Now().Add(taskLeaseDuration(audit))
+	extended, err := s.auditRepo.ExtendLease(taskID, claimToken, leaseExpiresAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to extend lease: %w", err)
+	}
+	if !extended {
+		// MySQL reports zero affected rows when the value is unchanged, so
+		// re-read the row before telling the worker it lost the task.
+		audit, err = s.auditRepo.FindTaskAuditByTaskID(taskID)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to find task audit: %w", err)
+		}
+		if err := checkLeaseHolder(audit, claimToken); err != nil {
+			return time.Time{}, err
+		}
+		if audit.LeaseExpiresAt != nil {
+			leaseExpiresAt = *audit.LeaseExpiresAt
+		}
+	}
+
+	return leaseExpiresAt, nil
+}
+
+// ReleaseTask hands a claimed task back to the queue without using up a retry.
+// It returns when the task can be claimed again.
+func (s *taskService) ReleaseTask(taskID uint, workerID uint, claimToken string, delay time.Duration, reason string) (time.Time, error) {
+	if delay < 0 || delay > MaxReleaseDelay {
+		return time.Time{}, fmt.Errorf("%w: must be between 0 and %s", ErrInvalidReleaseDelay, MaxReleaseDelay)
+	}
+
+	audit, err := s.auditRepo.FindTaskAuditByTaskID(taskID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, ErrTaskNotFound
+		}
+		return time.Time{}, fmt.Errorf("failed to find task audit: %w", err)
+	}
+	if err := checkLeaseHolder(audit, claimToken); err != nil {
+		return time.Time{}, err
+	}
+	if audit.ProcessedBy == nil || *audit.ProcessedBy != workerID {
+		return time.Time{}, ErrTaskNotClaimed
+	}
+
+	availableAt := time.Now()
+	var nextAttemptAt *time.Time
+	if delay > 0 {
+		availableAt = availableAt.Add(delay)
+		nextAttemptAt = &availableAt
+	}
+	if err := s.auditRepo.ReleaseTask(taskID, claimToken, nextAttemptAt, reason); err != nil {
+		return time.Time{}, fmt.Errorf("failed to release task: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"task_id":   taskID,
+		"worker_id": workerID,
+		"delay":     delay.String(),
+		"reason":    reason,
+	}).Info("Worker released task")
+
+	if nextAttemptAt == nil {
+		s.notifier.Notify()
+	}
+	return availableAt, nil
+}
+
+// retryTask puts a claimed task back in the queue until nextAttemptAt and adds
+// the failed attempt to its history, as one unit.
+func (s *taskService) retryTask(audit *database.TaskAudit, failure dto.Failure, errorMsg string, nextAttemptAt time.Time) error {
+	return s.uow.Do(func(repos repository.Repositories) error {
+		if err := repos.Audits.ReclaimStaleTask(audit.TaskID, audit.ClaimToken, failure.Code, errorMsg, nextAttemptAt); err != nil {
+			return fmt.Errorf("failed to reclaim task for retry: %w", err)
+		}
+		return recordFailure(repos.Audits, audit, failure)
+	})
+}
+
+// failTask fails a claimed task for good and adds the failed attempt to its
+// history, as one unit.
+func (s *taskService) failTask(audit *database.TaskAudit, failure dto.Failure, errorMsg string) error {
+	return s.uow.Do(func(repos repository.Repositories) error {
+		if err := repos.Audits.UpdateTaskFailed(audit.TaskID, audit.ClaimToken, failure.Code, errorMsg); err != nil {
+			return fmt.Errorf("failed to update task as failed: %w", err)
+		}
+		return recordFailure(repos.Audits, audit, failure)
+	})
+}
+
+// recordFailure adds a failed attempt to the task's failure history.
+func recordFailure(audits repository.TaskAuditRepository, audit *database.TaskAudit, failure dto.Failure) error {
+	retryable := failure.Retryable == nil || *failure.Retryable
+	record := &database.TaskFailure{
+		TaskID:    audit.TaskID,
+		Attempt:   audit.RetryCount + 1,
+		Code:      failure.Code,
+		ErrorMsg:  failure.Message,
+		Retryable: &retryable,
+		TrapKind:  failure.TrapKind,
+		Details:   failure.Details,
+		WorkerID:  audit.ProcessedBy,
+	}
+	if err := audits.CreateTaskFailure(record); err != nil {
+		return fmt.Errorf("failed to record task failure: %w", err)
+	}
+	return nil
+}
+
+func checkLeaseHolder(audit *database.TaskAudit, claimToken string) error {
+	if audit.Status == database.TaskStatusCancelled {
+		return ErrTaskCancelled
+	}
+	if audit.Status == database.TaskStatusDeadlineExceeded {
+		return ErrDeadlineExceeded
+	}
+	if audit.Status != database.TaskStatusProcessing ||
+		subtle.ConstantTimeCompare([]byte(audit.ClaimToken), []byte(claimToken)) != 1 {
+		return ErrTaskNotClaimed
+	}
+	return nil
+}
+
+// Tasks of a map job share the job's module instead of storing their own copy.
+func taskWasmModule(task *database.Task) string {
+	if task.MapJob != nil {
+		return task.MapJob.WasmModule
+	}
+	return task.WasmModule
+}
+
+func newClaimToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func leaseDuration() time.Duration {
+	return time.Duration(config.App.Task.TimeoutSeconds) * time.Second
+}
+
+func resultLeaseDuration() time.Duration {
+	return time.Duration(config.App.Task.ResultLeaseSeconds) * time.Second
+}
+
+// taskLeaseDuration is the task's own timeout, if it set one.
+func taskLeaseDuration(audit *database.TaskAudit) time.Duration {
+	if audit.TimeoutSeconds > 0 {
+		return time.Duration(audit.TimeoutSeconds) * time.Second
+	}
+	return leaseDuration()
+}
+
+// taskExpiry is when a task that becomes claimable at availableAt expires if
+// no worker has claimed it, or nil if it has no TTL.
+func taskExpiry(ttlSeconds int, availableAt time.Time) *time.Time {
+	if ttlSeconds <= 0 {
+		return nil
+	}
+	expiresAt := availableAt.Add(time.Duration(ttlSeconds) * time.Second)
+	return &expiresAt
+}
+
+func (s *taskService) ConsumeResult(userID uint) (*dto.LeasedResult, error) {
+	leased, err := s.ConsumeResults(userID, 1)
+	if err != nil {
+		return nil, err
+	}
+	return leased[0], nil
+}
+
+// ConsumeResults leases up to maxResults of the user's results. Each stays
+// hidden from other calls until its lease runs out, and is only consumed once
+// AckResult is called with its receipt.
+func (s *taskService) ConsumeResults(userID uint, maxResults int) ([]*dto.LeasedResult, error) {
+	if maxResults < 1 || maxResults > MaxResultBatchSize {
+		return nil, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidBatchSize, MaxResultBatchSize)
+	}
+
+	receipts := make([]string, maxResults)
+	for i := range receipts {
+		receipt, err := newClaimToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate receipt: %w", err)
+		}
+		receipts[i] = receipt
+	}
+
+	dbResults, err := s.resultRepo.LeaseResults(userID, receipts, time.Now().Add(resultLeaseDuration()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease results: %w", err)
+	}
+	if len(dbResults) == 0 {
+		return nil, ErrNoTasksAvailable
+	}
+
+	leased := make([]*dto.LeasedResult, 0, len(dbResults))
+	for _, dbResult := range dbResults {
+		// A result that cannot be decoded is still delivered, as the raw
+		// string with a decode error, so it can be acked like any other.
+		var resultData interface{}
+		var decodeError string
+		if err := json.Unmarshal([]byte(dbResult.Result), &resultData); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"result_id": dbResult.ID,
+				"task_id":   dbResult.TaskID,
+				"error":     err.Error(),
+			}).Error("Failed to unmarshal result data")
+			resultData = dbResult.Result
+			decodeError = fmt.Sprintf("result is not valid JSON: %v", err)
+		}
+
+		status := dbResult.Status
+		if status == "" {
+			status = database.TaskStatusCompleted
+		}
+		leased = append(leased, &dto.LeasedResult{
+			Result: dto.Result{
+				ID:          dbResult.ID,
+				TaskID:      dbResult.TaskID,
+				CreatedBy:   dbResult.CreatedBy,
+				Status:      string(status),
+				Result:      resultData,
+				DecodeError: decodeError,
+			},
+			Receipt:        dbResult.Receipt,
+			LeaseExpiresAt: *dbResult.LeaseExpiresAt,
+		})
+	}
+
+	return leased, nil
+}
+
+func (s *taskService) AckResult(resultID uint, userID uint, receipt string) error {
+	dbResult, err := s.resultRepo.FindResultByID(resultID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrResultNotFound
+		}
+		return fmt.Errorf("failed to find result: %w", err)
+	}
+
+	if dbResult.CreatedBy != userID {
+		return ErrResultAccessDenied
+	}
+	if subtle.ConstantTimeCompare([]byte(dbResult.Receipt), []byte(receipt)) != 1 {
+		return ErrResultNotLeased
+	}
+	if dbResult.Consumed {
+		// Already acked with this receipt.
+		return nil
+	}
+
+	acked, err := s.resultRepo.AckResult(resultID, receipt)
+	if err != nil {
+		return fmt.Errorf("failed to ack result: %w", err)
+	}
+	if !acked {
+		return ErrResultNotLeased
+	}
+	return nil
 }
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
+	"rainchanel.com/internal/validation"
+)
+
+var ErrTaskGroupNotFound = errors.New("task group not found")
+var ErrTaskGroupAccessDenied = errors.New("task group does not belong to user")
+var ErrInvalidTaskGroup = errors.New("invalid task group")
+
+const MaxTaskGroupTasks = 1000
+
+type TaskGroupService interface {
+	CreateTaskGroup(tasks []dto.Task, reduce dto.TaskGroupReduce, onFailure string, createdBy uint) (*dto.TaskGroup, error)
+	GetTaskGroup(groupID uint, userID uint) (*dto.TaskGroup, error)
+}
+
+type taskGroupService struct {
+	taskGroupRepo repository.TaskGroupRepository
+	taskService   TaskService
+}
+
+func NewTaskGroupService(taskService TaskService) TaskGroupService {
+	return &taskGroupService{
+		taskGroupRepo: repository.NewTaskGroupRepository(),
+		taskService:   taskService,
+	}
+}
+
+func NewTaskGroupServiceWithRepo(taskGroupRepo repository.TaskGroupRepository, taskService TaskService) TaskGroupService {
+	return &taskGroupService{
+		taskGroupRepo: taskGroupRepo,
+		taskService:   taskService,
+	}
+}
+
+func (s *taskGroupService) CreateTaskGroup(tasks []dto.Task, reduce dto.TaskGroupReduce, onFailure string, createdBy uint) (*dto.TaskGroup, error) {
+	if len(tasks) == 0 || len(tasks) > MaxTaskGroupTasks {
+		return nil, fmt.Errorf("%w: must contain between 1 and %d tasks", ErrInvalidTaskGroup, MaxTaskGroupTasks)
+	}
+
+	policy := database.GroupFailurePolicy(onFailure)
+	if policy == "" {
+		policy = database.GroupFailurePolicyAbort
+	}
+	if policy != database.GroupFailurePolicyAbort &&
+		policy != database.GroupFailurePolicyReduceSuccesses &&
+		policy != database.GroupFailurePolicyWaitForRetries {
+		return nil, fmt.Errorf("%w: unknown on_failure policy %q", ErrInvalidTaskGroup, onFailure)
+	}
+
+	for i := range tasks {
+		if err := validateTaskDefinition(&tasks[i]); err != nil {
+			return nil, fmt.Errorf("group task %d: %w", i, err)
+		}
+	}
+
+	// Every member feeds one argument of the reduce function, so check its
+	// signature against placeholders before anything is published. Under
+	// reduce_successes it also gets the number of members that completed.
+	reduceTask := dto.Task{
+		WasmModule:     reduce.WasmModule,
+		Func:           reduce.Func,
+		Queue:          reduce.Queue,
+		Priority:       reduce.Priority,
+		Retry:          reduce.Retry,
+		TimeoutSeconds: reduce.TimeoutSeconds,
+		Deadline:       reduce.Deadline,
+		TTLSeconds:     reduce.TTLSeconds,
+	}
+	if err := validateTaskPlacement(&reduceTask); err != nil {
+		return nil, fmt.Errorf("reduce task: %w", err)
+	}
+	placeholders := make([]interface{}, len(tasks))
+	for i := range placeholders {
+		placeholders[i] = map[string]interface{}{validation.ResultReferenceKey: 0}
+	}
+	if policy == database.GroupFailurePolicyReduceSuccesses {
+		placeholders = append(placeholders, 0)
+	}
+	if err := validation.ValidateTask(reduce.WasmModule, reduce.Func, placeholders); err != nil {
+		return nil, fmt.Errorf("reduce task validation failed: %w", err)
+	}
+
+	dbGroup := &database.TaskGroup{
+		OnFailure: policy,
+		TaskCount: len(tasks),
+		CreatedBy: createdBy,
+	}
+	var taskIDs []uint
+	var reduceTaskID uint
+	err := s.taskService.PublishTaskBatch(createdBy, func(repos repository.Repositories, publish TaskPublisher) error {
+		if err := repos.TaskGroups.CreateTaskGroup(dbGroup); err != nil {
+			return fmt.Errorf("failed to create task group in database: %w", err)
+		}
+
+		opts := make([]dto.PublishOptions, len(tasks))
+		for i := range opts {
+			opts[i] = dto.PublishOptions{TaskGroupID: &dbGroup.ID}
+		}
+		var err error
+		taskIDs, err = publish(tasks, opts)
+		if err != nil {
+			return fmt.Errorf("failed to publish group tasks: %w", err)
+		}
+
+		references := make([]interface{}, 0, len(taskIDs))
+		for _, taskID := range taskIDs {
+			reference := map[string]interface{}{validation.ResultReferenceKey: taskID}
+			if reduce.Path != "" {
+				reference["path"] = reduce.Path
+			}
+			references = append(references, reference)
+		}
+		reduceTask.Args = references
+
+		reduceTaskIDs, err := publish([]dto.Task{reduceTask}, []dto.PublishOptions{{ReducesGroupID: &dbGroup.ID}})
+		if err != nil {
+			return fmt.Errorf("failed to publish reduce task: %w", err)
+		}
+		reduceTaskID = reduceTaskIDs[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"task_group_id":  dbGroup.ID,
+		"user_id":        createdBy,
+		"tasks":          len(taskIDs),
+		"reduce_task_id": reduceTaskID,
+		"on_failure":     policy,
+	}).Info("Task group created")
+
+	return &dto.TaskGroup{
+		ID:           dbGroup.ID,
+		OnFailure:    string(policy),
+		Status:       JobStatusRunning,
+		TaskIDs:      taskIDs,
+		ReduceTaskID: reduceTaskID,
+		ReduceStatus: string(database.TaskStatusBlocked),
+		CreatedBy:    createdBy,
+		CreatedAt:    &dbGroup.CreatedAt,
+	}, nil
+}
+
+func (s *taskGroupService) GetTaskGroup(groupID uint, userID uint) (*dto.TaskGroup, error) {
+	dbGroup, err := s.taskGroupRepo.FindTaskGroupByID(groupID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTaskGroupNotFound
+		}
+		return nil, fmt.Errorf("failed to find task group: %w", err)
+	}
+
+	if dbGroup.CreatedBy != userID {
+		return nil, ErrTaskGroupAccessDenied
+	}
+
+	audits, err := s.taskGroupRepo.FindTaskGroupTasks(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find task group tasks: %w", err)
+	}
+	reduceAudit, err := s.taskGroupRepo.FindTaskGroupReduceTask(groupID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to find reduce task: %w", err)
+	}
+
+	group := &dto.TaskGroup{
+		ID:        dbGroup.ID,
+		OnFailure: string(dbGroup.OnFailure),
+		Counts:    make(map[string]int),
+		TaskIDs:   make([]uint, 0, len(audits)),
+		CreatedBy: dbGroup.CreatedBy,
+		CreatedAt: &dbGroup.CreatedAt,
+	}
+	for _, audit := range audits {
+		group.Counts[string(audit.Status)]++
+		group.TaskIDs = append(group.TaskIDs, audit.TaskID)
+	}
+
+	// The group finishes with its reduce task; a group whose reduce task was
+	// never published did not finish creating.
+	if reduceAudit == nil {
+		group.Status = JobStatusCancelled
+		return group, nil
+	}
+	group.ReduceTaskID = reduceAudit.TaskID
+	group.ReduceStatus = string(reduceAudit.Status)
+	group.Status = jobStatus(map[string]int{string(reduceAudit.Status): 1})
+
+	return group, nil
+}
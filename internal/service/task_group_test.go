@@ -0,0 +1,200 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
+)
+
+func TestTaskGroupService_CreateTaskGroup(t *testing.T) {
+	tasks := []dto.Task{
+		{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}},
+		{WasmModule: testWasmModule, Func: "add", Args: []int{3, 4}},
+	}
+	reduce := dto.TaskGroupReduce{WasmModule: testWasmModule, Func: "add"}
+
+	t.Run("publishes members and a reduce task over their results", func(t *testing.T) {
+		var stored *database.TaskGroup
+		groupRepo := &MockTaskGroupRepository{
+			CreateTaskGroupFunc: func(group *database.TaskGroup) error {
+				group.ID = 9
+				stored = group
+				return nil
+			},
+		}
+		var reduceArgs interface{}
+		var reduceOpts dto.PublishOptions
+		published := 0
+		taskService := &MockTaskServiceForStale{
+			PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+				published++
+				if opts.ReducesGroupID != nil {
+					reduceArgs = task.Args
+					reduceOpts = opts
+					return 30, nil
+				}
+				assert.Equal(t, uint(9), *opts.TaskGroupID)
+				return uint(20 + published), nil
+			},
+			BatchRepos: repository.Repositories{TaskGroups: groupRepo},
+		}
+		service := NewTaskGroupServiceWithRepo(&MockTaskGroupRepository{}, taskService)
+
+		group, err := service.CreateTaskGroup(tasks, dto.TaskGroupReduce{WasmModule: testWasmModule, Func: "add", Path: "0"}, "", 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, database.GroupFailurePolicyAbort, stored.OnFailure)
+		assert.Equal(t, uint(9), *reduceOpts.ReducesGroupID)
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"$from_task": uint(21), "path": "0"},
+			map[string]interface{}{"$from_task": uint(22), "path": "0"},
+		}, reduceArgs)
+		assert.Equal(t, []uint{21, 22}, group.TaskIDs)
+		assert.Equal(t, uint(30), group.ReduceTaskID)
+		assert.Equal(t, JobStatusRunning, group.Status)
+	})
+
+	t.Run("rejects an unknown failure policy", func(t *testing.T) {
+		service := NewTaskGroupServiceWithRepo(&MockTaskGroupRepository{}, &MockTaskServiceForStale{})
+
+		group, err := service.CreateTaskGroup(tasks, reduce, "retry_forever", 1)
+
+		assert.ErrorIs(t, err, ErrInvalidTaskGroup)
+		assert.Nil(t, group)
+	})
+
+	t.Run("rejects a reduce function that does not take one argument per task", func(t *testing.T) {
+		groupRepo := &MockTaskGroupRepository{
+			CreateTaskGroupFunc: func(group *database.TaskGroup) error {
+				t.Fatal("task group should not be created")
+				return nil
+			},
+		}
+		service := NewTaskGroupServiceWithRepo(groupRepo, &MockTaskServiceForStale{})
+
+		group, err := service.CreateTaskGroup(tasks[:1], reduce, "", 1)
+
+		assert.ErrorContains(t, err, "reduce task validation failed")
+		assert.Nil(t, group)
+	})
+
+	t.Run("reduce_successes passes the completed count as an extra argument", func(t *testing.T) {
+		taskService := &MockTaskServiceForStale{
+			BatchRepos: repository.Repositories{TaskGroups: &MockTaskGroupRepository{}},
+		}
+		service := NewTaskGroupServiceWithRepo(&MockTaskGroupRepository{}, taskService)
+
+		_, err := service.CreateTaskGroup(tasks, reduce, string(database.GroupFailurePolicyReduceSuccesses), 1)
+		assert.ErrorContains(t, err, "reduce task validation failed")
+
+		group, err := service.CreateTaskGroup(tasks[:1], reduce, string(database.GroupFailurePolicyReduceSuccesses), 1)
+		assert.NoError(t, err)
+		assert.NotNil(t, group)
+	})
+
+	t.Run("fails the whole group when the reduce task cannot be published", func(t *testing.T) {
+		published := 0
+		taskService := &MockTaskServiceForStale{
+			PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+				if opts.ReducesGroupID != nil {
+					return 0, errors.New("database error")
+				}
+				published++
+				return uint(20 + published), nil
+			},
+			CancelTaskFunc: func(taskID uint, userID uint) error {
+				t.Error("members should be rolled back with the group, not cancelled")
+				return nil
+			},
+			BatchRepos: repository.Repositories{TaskGroups: &MockTaskGroupRepository{}},
+		}
+		service := NewTaskGroupServiceWithRepo(&MockTaskGroupRepository{}, taskService)
+
+		group, err := service.CreateTaskGroup(tasks, reduce, "", 1)
+
+		assert.ErrorContains(t, err, "failed to publish reduce task")
+		assert.Nil(t, group)
+	})
+}
+
+func TestTaskGroupService_GetTaskGroup(t *testing.T) {
+	tests := []struct {
+		name         string
+		userID       uint
+		findErr      error
+		reduceStatus database.TaskStatus
+		wantErr      error
+		wantStatus   string
+	}{
+		{
+			name:    "not found",
+			userID:  1,
+			findErr: gorm.ErrRecordNotFound,
+			wantErr: ErrTaskGroupNotFound,
+		},
+		{
+			name:    "access denied",
+			userID:  2,
+			wantErr: ErrTaskGroupAccessDenied,
+		},
+		{
+			name:         "running until the reduce task finishes",
+			userID:       1,
+			reduceStatus: database.TaskStatusBlocked,
+			wantStatus:   JobStatusRunning,
+		},
+		{
+			name:         "completed with the reduce task",
+			userID:       1,
+			reduceStatus: database.TaskStatusCompleted,
+			wantStatus:   JobStatusCompleted,
+		},
+		{
+			name:         "failed with the reduce task",
+			userID:       1,
+			reduceStatus: database.TaskStatusFailed,
+			wantStatus:   JobStatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groupRepo := &MockTaskGroupRepository{
+				FindTaskGroupByIDFunc: func(groupID uint) (*database.TaskGroup, error) {
+					if tt.findErr != nil {
+						return nil, tt.findErr
+					}
+					return &database.TaskGroup{ID: groupID, CreatedBy: 1, OnFailure: database.GroupFailurePolicyAbort}, nil
+				},
+				FindTaskGroupTasksFunc: func(groupID uint) ([]*database.TaskAudit, error) {
+					return []*database.TaskAudit{
+						{TaskID: 21, Status: database.TaskStatusCompleted},
+						{TaskID: 22, Status: database.TaskStatusCompleted},
+					}, nil
+				},
+				FindTaskGroupReduceTaskFunc: func(groupID uint) (*database.TaskAudit, error) {
+					return &database.TaskAudit{TaskID: 30, Status: tt.reduceStatus}, nil
+				},
+			}
+			service := NewTaskGroupServiceWithRepo(groupRepo, &MockTaskServiceForStale{})
+
+			group, err := service.GetTaskGroup(9, tt.userID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, group)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, group.Status)
+			assert.Equal(t, 2, group.Counts["completed"])
+			assert.Equal(t, []uint{21, 22}, group.TaskIDs)
+			assert.Equal(t, uint(30), group.ReduceTaskID)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package service
+
+import "sync"
+
+type taskNotifier struct {
+	mu      sync.Mutex
+	waiters chan struct{}
+}
+
+func newTaskNotifier() *taskNotifier {
+	return &taskNotifier{
+		waiters: make(chan struct{}),
+	}
+}
+
+// Wait returns a channel that is closed by the next call to Notify. Callers
+// must take the channel before checking for work so a publish that lands in
+// between is not missed.
+func (n *taskNotifier) Wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.waiters
+}
+
+func (n *taskNotifier) Notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.waiters)
+	n.waiters = make(chan struct{})
+}
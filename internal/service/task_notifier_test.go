@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskNotifier(t *testing.T) {
+	notifier := newTaskNotifier()
+
+	first := notifier.Wait()
+	second := notifier.Wait()
+
+	select {
+	case <-first:
+		t.Fatal("Wait() channel closed before Notify()")
+	default:
+	}
+
+	notifier.Notify()
+
+	for i, ch := range []<-chan struct{}{first, second} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("waiter %d was not woken by Notify()", i)
+		}
+	}
+
+	select {
+	case <-notifier.Wait():
+		t.Fatal("Wait() after Notify() returned an already closed channel")
+	default:
+	}
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,6 +12,7 @@ import (
 	"rainchanel.com/internal/config"
 	"rainchanel.com/internal/database"
 	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
 )
 
 func TestNewTaskService(t *testing.T) {
@@ -20,6 +24,13 @@ func TestNewTaskService(t *testing.T) {
 }
 
 func TestTaskService_PublishTask(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			MaxTimeoutSeconds: 3600,
+		},
+	}
+	pastDeadline := time.Now().Add(-time.Minute)
+
 	tests := []struct {
 		name       string
 		task       dto.Task
@@ -43,6 +54,76 @@ func TestTaskService_PublishTask(t *testing.T) {
 				return &MockTaskRepository{}, &MockTaskAuditRepository{}, &MockResultRepository{}
 			},
 		},
+		{
+			name: "invalid queue name",
+			task: dto.Task{
+				WasmModule: "AGFzbQEAAAABBwFgAn9/AX9gAAF/",
+				Func:       "testFunc",
+				Queue:      "gpu sim!",
+			},
+			createdBy:  1,
+			wantErr:    true,
+			wantTaskID: 0,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				return &MockTaskRepository{}, &MockTaskAuditRepository{}, &MockResultRepository{}
+			},
+		},
+		{
+			name: "priority out of range",
+			task: dto.Task{
+				WasmModule: "AGFzbQEAAAABBwFgAn9/AX9gAAF/",
+				Func:       "testFunc",
+				Priority:   MaxTaskPriority + 1,
+			},
+			createdBy:  1,
+			wantErr:    true,
+			wantTaskID: 0,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				return &MockTaskRepository{}, &MockTaskAuditRepository{}, &MockResultRepository{}
+			},
+		},
+		{
+			name: "timeout above server max",
+			task: dto.Task{
+				WasmModule:     "AGFzbQEAAAABBwFgAn9/AX9gAAF/",
+				Func:           "testFunc",
+				TimeoutSeconds: 3601,
+			},
+			createdBy:  1,
+			wantErr:    true,
+			wantTaskID: 0,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				return &MockTaskRepository{}, &MockTaskAuditRepository{}, &MockResultRepository{}
+			},
+		},
+		{
+			name: "negative ttl",
+			task: dto.Task{
+				WasmModule: "AGFzbQEAAAABBwFgAn9/AX9gAAF/",
+				Func:       "testFunc",
+				TTLSeconds: -1,
+			},
+			createdBy:  1,
+			wantErr:    true,
+			wantTaskID: 0,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				return &MockTaskRepository{}, &MockTaskAuditRepository{}, &MockResultRepository{}
+			},
+		},
+		{
+			name: "deadline in the past",
+			task: dto.Task{
+				WasmModule: "AGFzbQEAAAABBwFgAn9/AX9gAAF/",
+				Func:       "testFunc",
+				Deadline:   &pastDeadline,
+			},
+			createdBy:  1,
+			wantErr:    true,
+			wantTaskID: 0,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				return &MockTaskRepository{}, &MockTaskAuditRepository{}, &MockResultRepository{}
+			},
+		},
 		{
 			name: "validation error",
 			task: dto.Task{
@@ -63,9 +144,9 @@ func TestTaskService_PublishTask(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			taskRepo, auditRepo, resultRepo := tt.setupMocks()
-			service := NewTaskServiceWithRepos(taskRepo, auditRepo, resultRepo)
+			service := newTestTaskService(taskRepo, auditRepo, resultRepo)
 
-			taskID, err := service.PublishTask(tt.task, tt.createdBy)
+			taskID, err := service.PublishTask(tt.task, tt.createdBy, dto.PublishOptions{})
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -81,7 +162,178 @@ func TestTaskService_PublishTask(t *testing.T) {
 	}
 }
 
+// testWasmModule exports add(i32, i32) -> i32.
+const testWasmModule = "AGFzbQEAAAABBwFgAn9/AX8DAgEABwcBA2FkZAAACgkBBwAgACABags="
+
+func TestTaskService_PublishTask_IdempotencyKey(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			IdempotencyWindowSeconds: 3600,
+		},
+	}
+
+	task := dto.Task{
+		WasmModule: testWasmModule,
+		Func:       "add",
+		Args:       []int{1, 2},
+	}
+	normalized := task
+	normalized.Queue = database.DefaultQueue
+	requestHash, err := taskRequestHash(normalized, dto.PublishOptions{})
+	assert.NoError(t, err)
+	existingTaskID := uint(41)
+
+	tests := []struct {
+		name          string
+		existing      *database.IdempotencyKey
+		createTaskErr error
+		wantTaskID    uint
+		wantErr       error
+		wantCreated   bool
+		wantDeleted   bool
+	}{
+		{
+			name:        "first use of a key",
+			wantTaskID:  42,
+			wantCreated: true,
+		},
+		{
+			name: "repeated key with the same task",
+			existing: &database.IdempotencyKey{
+				ID:          7,
+				RequestHash: requestHash,
+				TaskID:      &existingTaskID,
+				ExpiresAt:   time.Now().Add(time.Hour),
+			},
+			wantTaskID: existingTaskID,
+		},
+		{
+			name: "repeated key with a different task",
+			existing: &database.IdempotencyKey{
+				ID:          7,
+				RequestHash: "different",
+				TaskID:      &existingTaskID,
+				ExpiresAt:   time.Now().Add(time.Hour),
+			},
+			wantErr: ErrIdempotencyKeyConflict,
+		},
+		{
+			name: "repeated key while the first request is in progress",
+			existing: &database.IdempotencyKey{
+				ID:          7,
+				RequestHash: requestHash,
+				ExpiresAt:   time.Now().Add(time.Hour),
+			},
+			wantErr: ErrIdempotencyKeyConflict,
+		},
+		{
+			name: "expired key is reused",
+			existing: &database.IdempotencyKey{
+				ID:          7,
+				RequestHash: "different",
+				TaskID:      &existingTaskID,
+				ExpiresAt:   time.Now().Add(-time.Minute),
+			},
+			wantTaskID:  42,
+			wantCreated: true,
+			wantDeleted: true,
+		},
+		{
+			name:          "key is rolled back with the task when publishing fails",
+			createTaskErr: errors.New("insert failed"),
+			wantErr:       errors.New("failed to create task in database: insert failed"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := tt.existing
+			created := false
+			deleted := false
+			var recordedTaskID uint
+
+			taskRepo := &MockTaskRepository{
+				CreateIdempotencyKeyFunc: func(key *database.IdempotencyKey) error {
+					assert.Equal(t, uint(1), key.UserID)
+					assert.Equal(t, "retry-1", key.Key)
+					assert.Equal(t, requestHash, key.RequestHash)
+					if existing != nil {
+						return gorm.ErrDuplicatedKey
+					}
+					key.ID = 8
+					return nil
+				},
+				FindIdempotencyKeyFunc: func(userID uint, key string) (*database.IdempotencyKey, error) {
+					return existing, nil
+				},
+				DeleteIdempotencyKeyFunc: func(id uint) error {
+					deleted = true
+					existing = nil
+					return nil
+				},
+				SetIdempotencyKeyTaskFunc: func(id uint, taskID uint) error {
+					assert.Equal(t, uint(8), id)
+					recordedTaskID = taskID
+					return nil
+				},
+				CreateTaskFunc: func(dbTask *database.Task) error {
+					if tt.createTaskErr != nil {
+						return tt.createTaskErr
+					}
+					created = true
+					dbTask.ID = 42
+					return nil
+				},
+			}
+			service := newTestTaskService(taskRepo, &MockTaskAuditRepository{}, &MockResultRepository{})
+
+			taskID, err := service.PublishTask(task, 1, dto.PublishOptions{IdempotencyKey: "retry-1"})
+
+			if tt.wantErr != nil {
+				if errors.Is(tt.wantErr, ErrIdempotencyKeyConflict) {
+					assert.ErrorIs(t, err, tt.wantErr)
+				} else {
+					assert.EqualError(t, err, tt.wantErr.Error())
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantTaskID, taskID)
+			}
+			assert.Equal(t, tt.wantCreated, created)
+			assert.Equal(t, tt.wantDeleted, deleted)
+			if tt.wantCreated {
+				assert.Equal(t, taskID, recordedTaskID)
+			}
+		})
+	}
+}
+
+func TestTaskRequestHash(t *testing.T) {
+	task := dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}, Queue: database.DefaultQueue}
+	runAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	laterRunAt := runAt.Add(time.Hour)
+	sameInstant := runAt.In(time.FixedZone("UTC+2", 2*60*60))
+
+	hash := func(opts dto.PublishOptions) string {
+		h, err := taskRequestHash(task, opts)
+		assert.NoError(t, err)
+		return h
+	}
+
+	assert.Equal(t, hash(dto.PublishOptions{RunAt: &runAt}), hash(dto.PublishOptions{RunAt: &sameInstant}))
+	assert.NotEqual(t, hash(dto.PublishOptions{RunAt: &runAt}), hash(dto.PublishOptions{RunAt: &laterRunAt}))
+	assert.NotEqual(t, hash(dto.PublishOptions{}), hash(dto.PublishOptions{RunAt: &runAt}))
+	assert.Equal(t, hash(dto.PublishOptions{Delay: time.Minute}), hash(dto.PublishOptions{Delay: time.Minute}))
+	assert.NotEqual(t, hash(dto.PublishOptions{Delay: time.Minute}), hash(dto.PublishOptions{Delay: 2 * time.Minute}))
+}
+
 func TestTaskService_ConsumeTask(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			TimeoutSeconds: 300,
+		},
+	}
+
 	tests := []struct {
 		name       string
 		wantErr    bool
@@ -92,8 +344,8 @@ func TestTaskService_ConsumeTask(t *testing.T) {
 			wantErr: true,
 			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
 				auditRepo := &MockTaskAuditRepository{
-					FindAndClaimPendingTaskFunc: func() (*database.TaskAudit, error) {
-						return nil, gorm.ErrRecordNotFound
+					FindAndClaimPendingTasksFunc: func(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
+						return []*database.TaskAudit{}, nil
 					},
 				}
 				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
@@ -104,15 +356,24 @@ func TestTaskService_ConsumeTask(t *testing.T) {
 			wantErr: false,
 			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
 				auditRepo := &MockTaskAuditRepository{
-					FindAndClaimPendingTaskFunc: func() (*database.TaskAudit, error) {
-						return &database.TaskAudit{
-							TaskID: 1,
-							Task: database.Task{
-								ID:         1,
-								WasmModule: "AGFzbQEAAAABBwFgAn9/AX9gAAF/",
-								Func:       "testFunc",
-								Args:       `["arg1"]`,
-								CreatedBy:  1,
+					FindAndClaimPendingTasksFunc: func(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
+						assert.Equal(t, uint(2), workerID)
+						assert.Len(t, claimTokens, 1)
+						assert.Len(t, claimTokens[0], 32)
+						assert.Equal(t, 300*time.Second, leaseDuration)
+						leaseExpiresAt := time.Now().Add(leaseDuration)
+						return []*database.TaskAudit{
+							{
+								TaskID:         1,
+								ClaimToken:     claimTokens[0],
+								LeaseExpiresAt: &leaseExpiresAt,
+								Task: database.Task{
+									ID:         1,
+									WasmModule: "AGFzbQEAAAABBwFgAn9/AX9gAAF/",
+									Func:       "testFunc",
+									Args:       `["arg1"]`,
+									CreatedBy:  1,
+								},
 							},
 						}, nil
 					},
@@ -125,9 +386,9 @@ func TestTaskService_ConsumeTask(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			taskRepo, auditRepo, resultRepo := tt.setupMocks()
-			service := NewTaskServiceWithRepos(taskRepo, auditRepo, resultRepo)
+			service := newTestTaskService(taskRepo, auditRepo, resultRepo)
 
-			task, err := service.ConsumeTask()
+			task, err := service.ConsumeTask(2, nil)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -135,11 +396,392 @@ func TestTaskService_ConsumeTask(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, task)
+				assert.Len(t, task.ClaimToken, 32)
+			}
+		})
+	}
+}
+
+func TestTaskService_ConsumeTasks(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			TimeoutSeconds: 300,
+			MaxRetries:     3,
+		},
+	}
+
+	tests := []struct {
+		name        string
+		maxTasks    int
+		available   int
+		badArgs     uint
+		wantCount   int
+		wantSkipped uint
+		wantErr     error
+	}{
+		{
+			name:     "zero is rejected",
+			maxTasks: 0,
+			wantErr:  ErrInvalidBatchSize,
+		},
+		{
+			name:     "above the limit is rejected",
+			maxTasks: MaxClaimBatchSize + 1,
+			wantErr:  ErrInvalidBatchSize,
+		},
+		{
+			name:      "empty queue",
+			maxTasks:  16,
+			available: 0,
+			wantErr:   ErrNoTasksAvailable,
+		},
+		{
+			name:      "fewer available than requested",
+			maxTasks:  16,
+			available: 3,
+			wantCount: 3,
+		},
+		{
+			name:      "full batch",
+			maxTasks:  4,
+			available: 10,
+			wantCount: 4,
+		},
+		{
+			name:        "undecodable args release only that task",
+			maxTasks:    3,
+			available:   3,
+			badArgs:     2,
+			wantCount:   2,
+			wantSkipped: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var released []uint
+			auditRepo := &MockTaskAuditRepository{
+				FindAndClaimPendingTasksFunc: func(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
+					assert.Len(t, claimTokens, tt.maxTasks)
+					seen := make(map[string]bool)
+					var audits []*database.TaskAudit
+					for i, claimToken := range claimTokens {
+						assert.False(t, seen[claimToken], "claim tokens must be unique")
+						seen[claimToken] = true
+						if i >= tt.available {
+							continue
+						}
+						args := `["arg1"]`
+						if uint(i+1) == tt.badArgs {
+							args = `["arg1"`
+						}
+						audits = append(audits, &database.TaskAudit{
+							TaskID:     uint(i + 1),
+							ClaimToken: claimToken,
+							Task: database.Task{
+								ID:   uint(i + 1),
+								Args: args,
+							},
+						})
+					}
+					return audits, nil
+				},
+				ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+					assert.Equal(t, tt.badArgs, taskID)
+					released = append(released, taskID)
+					return nil
+				},
+			}
+			service := newTestTaskService(&MockTaskRepository{}, auditRepo, &MockResultRepository{})
+
+			claimed, err := service.ConsumeTasks(2, nil, tt.maxTasks)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, claimed, tt.wantCount)
+			for _, claimedTask := range claimed {
+				assert.NotEqual(t, tt.wantSkipped, claimedTask.Task.ID)
+				assert.NotEmpty(t, claimedTask.ClaimToken)
+			}
+			if tt.wantSkipped != 0 {
+				assert.Equal(t, []uint{tt.wantSkipped}, released)
+			}
+		})
+	}
+}
+
+func TestTaskService_ConsumeTasks_ResultReferences(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			TimeoutSeconds: 300,
+			MaxRetries:     3,
+		},
+	}
+
+	tests := []struct {
+		name         string
+		args         string
+		result       *database.Result
+		resultErr    error
+		retryCount   int
+		wantArgs     interface{}
+		wantFailed   bool
+		wantReleased bool
+	}{
+		{
+			name:     "reference is replaced by the result",
+			args:     `[{"$from_task": 5, "path": "sum"}, 2]`,
+			result:   &database.Result{TaskID: 5, Result: `{"sum": 40}`},
+			wantArgs: []interface{}{float64(40), float64(2)},
+		},
+		{
+			name:       "missing result fails the task",
+			args:       `[{"$from_task": 5}, 2]`,
+			wantFailed: true,
+		},
+		{
+			name:       "bad path fails the task",
+			args:       `[{"$from_task": 5, "path": "rows.3"}, 2]`,
+			result:     &database.Result{TaskID: 5, Result: `{"rows": [1]}`},
+			wantFailed: true,
+		},
+		{
+			name:       "resolved value must match the signature",
+			args:       `[{"$from_task": 5}, 2]`,
+			result:     &database.Result{TaskID: 5, Result: `"not a number"`},
+			wantFailed: true,
+		},
+		{
+			name:         "transient error releases the task for a retry",
+			args:         `[{"$from_task": 5}, 2]`,
+			resultErr:    errors.New("database error"),
+			retryCount:   1,
+			wantReleased: true,
+		},
+		{
+			name:       "transient error fails the task once retries are used up",
+			args:       `[{"$from_task": 5}, 2]`,
+			resultErr:  errors.New("database error"),
+			retryCount: 3,
+			wantFailed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failed, released := false, false
+			auditRepo := &MockTaskAuditRepository{
+				FindAndClaimPendingTasksFunc: func(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
+					return []*database.TaskAudit{
+						{
+							TaskID:     7,
+							ClaimToken: claimTokens[0],
+							RetryCount: tt.retryCount,
+							Task: database.Task{
+								ID:         7,
+								WasmModule: testWasmModule,
+								Func:       "add",
+								Args:       tt.args,
+							},
+						},
+					}, nil
+				},
+				UpdateTaskFailedFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string) error {
+					assert.Equal(t, uint(7), taskID)
+					assert.Contains(t, errorMsg, "Failed to resolve task arguments")
+					failed = true
+					return nil
+				},
+				ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+					assert.Equal(t, ErrorCodeArgumentResolution, errorCode)
+					released = true
+					return nil
+				},
+			}
+			resultRepo := &MockResultRepository{
+				FindResultByTaskIDFunc: func(taskID uint) (*database.Result, error) {
+					assert.Equal(t, uint(5), taskID)
+					if tt.resultErr != nil {
+						return nil, tt.resultErr
+					}
+					if tt.result == nil {
+						return nil, gorm.ErrRecordNotFound
+					}
+					return tt.result, nil
+				},
+			}
+			service := newTestTaskService(&MockTaskRepository{}, auditRepo, resultRepo)
+
+			claimed, err := service.ConsumeTask(2, nil)
+
+			assert.Equal(t, tt.wantFailed, failed)
+			assert.Equal(t, tt.wantReleased, released)
+			if tt.wantFailed || tt.wantReleased {
+				assert.ErrorIs(t, err, ErrNoTasksAvailable)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantArgs, claimed.Task.Args)
+		})
+	}
+}
+
+func TestTaskService_PublishTask_ResultReferences(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{},
+	}
+
+	tests := []struct {
+		name          string
+		referencedBy  uint
+		findErr       error
+		wantErr       error
+		wantDependsOn []database.TaskDependency
+	}{
+		{
+			name:          "own task becomes a dependency",
+			referencedBy:  1,
+			wantDependsOn: []database.TaskDependency{{TaskID: 42, DependsOnTaskID: 5}},
+		},
+		{
+			name:         "another user's task",
+			referencedBy: 2,
+			wantErr:      ErrInvalidResultReference,
+		},
+		{
+			name:    "unknown task",
+			findErr: gorm.ErrRecordNotFound,
+			wantErr: ErrInvalidResultReference,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dependencies []database.TaskDependency
+			taskRepo := &MockTaskRepository{
+				FindTaskByIDFunc: func(taskID uint) (*database.Task, error) {
+					if tt.findErr != nil {
+						return nil, tt.findErr
+					}
+					return &database.Task{ID: taskID, CreatedBy: tt.referencedBy}, nil
+				},
+				CreateTaskFunc: func(task *database.Task) error {
+					task.ID = 42
+					return nil
+				},
+				CreateTaskDependenciesFunc: func(deps []database.TaskDependency) error {
+					dependencies = deps
+					return nil
+				},
+			}
+			auditRepo := &MockTaskAuditRepository{
+				FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+					return &database.TaskAudit{TaskID: taskID, Status: database.TaskStatusBlocked}, nil
+				},
+			}
+			service := newTestTaskService(taskRepo, auditRepo, &MockResultRepository{})
+
+			task := dto.Task{
+				WasmModule: testWasmModule,
+				Func:       "add",
+				Args:       []interface{}{map[string]interface{}{"$from_task": float64(5), "path": "0"}, float64(2)},
+			}
+			_, err := service.PublishTask(task, 1, dto.PublishOptions{})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
 			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantDependsOn, dependencies)
 		})
 	}
 }
 
+func TestTaskService_ConsumeTasksWait(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			TimeoutSeconds: 300,
+		},
+	}
+
+	claimableAfter := func(calls *int, n int) func(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
+		return func(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
+			*calls++
+			if *calls < n {
+				return nil, nil
+			}
+			return []*database.TaskAudit{
+				{TaskID: 1, ClaimToken: claimTokens[0], Task: database.Task{ID: 1}},
+			}, nil
+		}
+	}
+
+	t.Run("invalid wait", func(t *testing.T) {
+		service := newTestTaskService(&MockTaskRepository{}, &MockTaskAuditRepository{}, &MockResultRepository{})
+
+		_, err := service.ConsumeTasksWait(context.Background(), 2, nil, 1, MaxConsumeWait+time.Second)
+		assert.ErrorIs(t, err, ErrInvalidWait)
+	})
+
+	t.Run("task already available", func(t *testing.T) {
+		calls := 0
+		auditRepo := &MockTaskAuditRepository{FindAndClaimPendingTasksFunc: claimableAfter(&calls, 1)}
+		service := newTestTaskService(&MockTaskRepository{}, auditRepo, &MockResultRepository{})
+
+		claimed, err := service.ConsumeTasksWait(context.Background(), 2, nil, 1, 30*time.Second)
+		assert.NoError(t, err)
+		assert.Len(t, claimed, 1)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("woken by publish", func(t *testing.T) {
+		calls := 0
+		auditRepo := &MockTaskAuditRepository{FindAndClaimPendingTasksFunc: claimableAfter(&calls, 2)}
+		service := newTestTaskService(&MockTaskRepository{}, auditRepo, &MockResultRepository{})
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			service.(*taskService).notifier.Notify()
+		}()
+
+		start := time.Now()
+		claimed, err := service.ConsumeTasksWait(context.Background(), 2, nil, 1, 30*time.Second)
+		assert.NoError(t, err)
+		assert.Len(t, claimed, 1)
+		assert.Less(t, time.Since(start), consumeWaitRecheckInterval)
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		auditRepo := &MockTaskAuditRepository{}
+		service := newTestTaskService(&MockTaskRepository{}, auditRepo, &MockResultRepository{})
+
+		start := time.Now()
+		_, err := service.ConsumeTasksWait(context.Background(), 2, nil, 1, 100*time.Millisecond)
+		assert.ErrorIs(t, err, ErrNoTasksAvailable)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("released on shutdown", func(t *testing.T) {
+		auditRepo := &MockTaskAuditRepository{}
+		service := newTestTaskService(&MockTaskRepository{}, auditRepo, &MockResultRepository{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}()
+
+		start := time.Now()
+		_, err := service.ConsumeTasksWait(ctx, 2, nil, 1, 30*time.Second)
+		assert.ErrorIs(t, err, ErrNoTasksAvailable)
+		assert.Less(t, time.Since(start), consumeWaitRecheckInterval)
+	})
+}
+
 func TestTaskService_PublishResult(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -148,6 +790,7 @@ func TestTaskService_PublishResult(t *testing.T) {
 		processedBy uint
 		result      string
 		wantErr     bool
+		wantErrIs   error
 		setupMocks  func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository)
 	}{
 		{
@@ -189,30 +832,212 @@ func TestTaskService_PublishResult(t *testing.T) {
 			},
 		},
 		{
-			name:        "success",
+			name:        "task cancelled",
 			taskID:      123,
 			createdBy:   1,
 			processedBy: 2,
 			result:      `{"result":"success"}`,
-			wantErr:     false,
+			wantErr:     true,
 			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
 				auditRepo := &MockTaskAuditRepository{
 					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
 						return &database.TaskAudit{
 							TaskID: 123,
+							Status: database.TaskStatusCancelled,
 							Task: database.Task{
 								ID:        123,
 								CreatedBy: 1,
 							},
 						}, nil
 					},
-					UpdateTaskAuditCompletedFunc: func(taskID uint, processedBy uint) error {
+					UpdateTaskAuditCompletedFunc: func(taskID uint, processedBy uint, claimToken string) error {
+						t.Error("UpdateTaskAuditCompleted should not be called for a cancelled task")
 						return nil
 					},
 				}
-				resultRepo := &MockResultRepository{
-					CreateResultFunc: func(result *database.Result) error {
-						return nil
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:        "stale claim token",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			result:      `{"result":"success"}`,
+			wantErr:     true,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "token-of-newer-claim",
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+							},
+						}, nil
+					},
+					UpdateTaskAuditCompletedFunc: func(taskID uint, processedBy uint, claimToken string) error {
+						t.Error("UpdateTaskAuditCompleted should not be called with a stale claim token")
+						return nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:        "claim lost before completion",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			result:      `{"result":"success"}`,
+			wantErr:     true,
+			wantErrIs:   ErrInvalidTransition,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "claim-token",
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+							},
+						}, nil
+					},
+					UpdateTaskAuditCompletedFunc: func(taskID uint, processedBy uint, claimToken string) error {
+						return &repository.TransitionError{TaskID: taskID, To: database.TaskStatusCompleted}
+					},
+				}
+				resultRepo := &MockResultRepository{
+					CreateResultFunc: func(result *database.Result) error {
+						t.Error("CreateResult should not be called when the claim was lost")
+						return nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, resultRepo
+			},
+		},
+		{
+			name:        "task already completed",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			result:      `{"result":"success"}`,
+			wantErr:     true,
+			wantErrIs:   ErrDuplicateResult,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusCompleted,
+							ClaimToken: "claim-token",
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+							},
+						}, nil
+					},
+					UpdateTaskAuditCompletedFunc: func(taskID uint, processedBy uint, claimToken string) error {
+						t.Error("UpdateTaskAuditCompleted should not be called for a completed task")
+						return nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:        "result already stored",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			result:      `{"result":"success"}`,
+			wantErr:     true,
+			wantErrIs:   ErrDuplicateResult,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "claim-token",
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+							},
+						}, nil
+					},
+				}
+				resultRepo := &MockResultRepository{
+					CreateResultFunc: func(result *database.Result) error {
+						return gorm.ErrDuplicatedKey
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, resultRepo
+			},
+		},
+		{
+			name:        "result store fails",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			result:      `{"result":"success"}`,
+			wantErr:     true,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "claim-token",
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+							},
+						}, nil
+					},
+					UpdateTaskAuditCompletedFunc: func(taskID uint, processedBy uint, claimToken string) error {
+						return nil
+					},
+				}
+				resultRepo := &MockResultRepository{
+					CreateResultFunc: func(result *database.Result) error {
+						return errors.New("database error")
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, resultRepo
+			},
+		},
+		{
+			name:        "success",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			result:      `{"result":"success"}`,
+			wantErr:     false,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "claim-token",
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+							},
+						}, nil
+					},
+					UpdateTaskAuditCompletedFunc: func(taskID uint, processedBy uint, claimToken string) error {
+						return nil
+					},
+				}
+				resultRepo := &MockResultRepository{
+					CreateResultFunc: func(result *database.Result) error {
+						return nil
 					},
 				}
 				return &MockTaskRepository{}, auditRepo, resultRepo
@@ -223,12 +1048,15 @@ func TestTaskService_PublishResult(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			taskRepo, auditRepo, resultRepo := tt.setupMocks()
-			service := NewTaskServiceWithRepos(taskRepo, auditRepo, resultRepo)
+			service := newTestTaskService(taskRepo, auditRepo, resultRepo)
 
-			err := service.PublishResult(tt.taskID, tt.createdBy, tt.processedBy, tt.result)
+			err := service.PublishResult(tt.taskID, tt.createdBy, tt.processedBy, "claim-token", tt.result)
 
 			if tt.wantErr {
 				assert.Error(t, err)
+				if tt.wantErrIs != nil {
+					assert.ErrorIs(t, err, tt.wantErrIs)
+				}
 			} else {
 				assert.NoError(t, err)
 			}
@@ -236,11 +1064,176 @@ func TestTaskService_PublishResult(t *testing.T) {
 	}
 }
 
+func TestTaskService_UnitOfWork(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			IdempotencyWindowSeconds: 3600,
+		},
+	}
+
+	// The unit of work below runs fn once and flags the writes made inside
+	// it; a real one would roll all of them back when fn fails.
+	newService := func(taskRepo *MockTaskRepository, auditRepo *MockTaskAuditRepository, resultRepo *MockResultRepository, inTx *bool, calls *int) *taskService {
+		return NewTaskServiceWithRepos(taskRepo, auditRepo, resultRepo, &MockUnitOfWork{DoFunc: func(fn func(repos repository.Repositories) error) error {
+			*calls++
+			*inTx = true
+			defer func() { *inTx = false }()
+			return fn(repository.Repositories{Tasks: taskRepo, Audits: auditRepo, Results: resultRepo})
+		}}).(*taskService)
+	}
+
+	t.Run("publish task writes task, audit and idempotency key together", func(t *testing.T) {
+		var inTx bool
+		var calls int
+		taskRepo := &MockTaskRepository{
+			CreateIdempotencyKeyFunc: func(key *database.IdempotencyKey) error {
+				assert.True(t, inTx, "the idempotency key should be reserved in the unit of work")
+				key.ID = 8
+				return nil
+			},
+			CreateTaskFunc: func(task *database.Task) error {
+				assert.True(t, inTx, "CreateTask should run in the unit of work")
+				task.ID = 42
+				return nil
+			},
+			SetIdempotencyKeyTaskFunc: func(id uint, taskID uint) error {
+				assert.True(t, inTx, "SetIdempotencyKeyTask should run in the unit of work")
+				return errors.New("database error")
+			},
+			DeleteIdempotencyKeyFunc: func(id uint) error {
+				t.Error("the key should be rolled back with the task instead of deleted")
+				return nil
+			},
+		}
+		auditRepo := &MockTaskAuditRepository{
+			CreateTaskAuditFunc: func(audit *database.TaskAudit) error {
+				assert.True(t, inTx, "CreateTaskAudit should run in the unit of work")
+				assert.Equal(t, uint(42), audit.TaskID)
+				return nil
+			},
+		}
+		service := newService(taskRepo, auditRepo, &MockResultRepository{}, &inTx, &calls)
+
+		_, err := service.PublishTask(dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}}, 1, dto.PublishOptions{IdempotencyKey: "retry-1"})
+
+		assert.ErrorContains(t, err, "failed to record task for idempotency key")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("publish result completes the task and stores the result together", func(t *testing.T) {
+		var inTx bool
+		var calls int
+		taskRepo := &MockTaskRepository{
+			FindDependentTaskIDsFunc: func(taskID uint) ([]uint, error) {
+				t.Error("dependents should not be resolved when the unit of work fails")
+				return nil, nil
+			},
+		}
+		auditRepo := &MockTaskAuditRepository{
+			FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+				return &database.TaskAudit{
+					TaskID:     taskID,
+					Status:     database.TaskStatusProcessing,
+					ClaimToken: "claim-token",
+					Task:       database.Task{ID: taskID, CreatedBy: 1},
+				}, nil
+			},
+			UpdateTaskAuditCompletedFunc: func(taskID uint, processedBy uint, claimToken string) error {
+				assert.True(t, inTx, "UpdateTaskAuditCompleted should run in the unit of work")
+				return nil
+			},
+		}
+		resultRepo := &MockResultRepository{
+			CreateResultFunc: func(result *database.Result) error {
+				assert.True(t, inTx, "CreateResult should run in the unit of work")
+				return errors.New("database error")
+			},
+		}
+		service := newService(taskRepo, auditRepo, resultRepo, &inTx, &calls)
+
+		err := service.PublishResult(123, 1, 2, "claim-token", `{"result":"success"}`)
+
+		assert.ErrorContains(t, err, "failed to create result in database")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("publish failure fails the task and records the attempt together", func(t *testing.T) {
+		var inTx bool
+		var calls int
+		taskRepo := &MockTaskRepository{
+			FindDependentTaskIDsFunc: func(taskID uint) ([]uint, error) {
+				t.Error("dependents should not be resolved when the unit of work fails")
+				return nil, nil
+			},
+		}
+		auditRepo := &MockTaskAuditRepository{
+			FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+				return &database.TaskAudit{
+					TaskID:     taskID,
+					Status:     database.TaskStatusProcessing,
+					ClaimToken: "claim-token",
+					Task:       database.Task{ID: taskID, CreatedBy: 1},
+				}, nil
+			},
+			UpdateTaskFailedFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string) error {
+				assert.True(t, inTx, "UpdateTaskFailed should run in the unit of work")
+				return nil
+			},
+			CreateTaskFailureFunc: func(failure *database.TaskFailure) error {
+				assert.True(t, inTx, "CreateTaskFailure should run in the unit of work")
+				return errors.New("database error")
+			},
+		}
+		service := newService(taskRepo, auditRepo, &MockResultRepository{}, &inTx, &calls)
+
+		err := service.PublishFailure(123, 1, 2, "claim-token", dto.Failure{Message: "boom"})
+
+		assert.ErrorContains(t, err, "failed to record task failure")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("expiry moves the task and stores its result together", func(t *testing.T) {
+		var inTx bool
+		var calls int
+		taskRepo := &MockTaskRepository{
+			FindDependentTaskIDsFunc: func(taskID uint) ([]uint, error) {
+				t.Error("dependents should not be resolved when the unit of work fails")
+				return nil, nil
+			},
+		}
+		auditRepo := &MockTaskAuditRepository{
+			FindExpiredTasksFunc: func(now time.Time) ([]*database.TaskAudit, error) {
+				return []*database.TaskAudit{{TaskID: 7, TTLSeconds: 60, Task: database.Task{ID: 7, CreatedBy: 1}}}, nil
+			},
+			ExpireTaskFunc: func(taskID uint, errorMsg string) (bool, error) {
+				assert.True(t, inTx, "ExpireTask should run in the unit of work")
+				return true, nil
+			},
+		}
+		resultRepo := &MockResultRepository{
+			CreateResultFunc: func(result *database.Result) error {
+				assert.True(t, inTx, "CreateResult should run in the unit of work")
+				return errors.New("database error")
+			},
+		}
+		service := newService(taskRepo, auditRepo, resultRepo, &inTx, &calls)
+
+		count, err := service.ExpireTasks()
+
+		assert.ErrorContains(t, err, "failed to expire 1 of 1 tasks")
+		assert.Equal(t, 0, count)
+		assert.Equal(t, 1, calls)
+	})
+}
+
 func TestTaskService_PublishFailure(t *testing.T) {
+	notRetryable := false
 
 	config.App = &config.Config{
 		Task: config.TaskConfig{
-			MaxRetries: 3,
+			MaxRetries:              3,
+			RetryBackoffBaseSeconds: 1,
+			RetryBackoffMaxSeconds:  300,
 		},
 	}
 
@@ -249,7 +1242,9 @@ func TestTaskService_PublishFailure(t *testing.T) {
 		taskID      uint
 		createdBy   uint
 		processedBy uint
+		code        string
 		errorMsg    string
+		retryable   *bool
 		retryCount  int
 		wantErr     bool
 		setupMocks  func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository)
@@ -267,6 +1262,8 @@ func TestTaskService_PublishFailure(t *testing.T) {
 					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
 						return &database.TaskAudit{
 							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "claim-token",
 							RetryCount: 1,
 							Task: database.Task{
 								ID:        123,
@@ -274,7 +1271,10 @@ func TestTaskService_PublishFailure(t *testing.T) {
 							},
 						}, nil
 					},
-					ReclaimStaleTaskFunc: func(taskID uint, errorMsg string) error {
+					ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+						if !nextAttemptAt.After(time.Now()) {
+							return errors.New("next attempt not delayed")
+						}
 						return nil
 					},
 				}
@@ -294,6 +1294,8 @@ func TestTaskService_PublishFailure(t *testing.T) {
 					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
 						return &database.TaskAudit{
 							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "claim-token",
 							RetryCount: 3,
 							Task: database.Task{
 								ID:        123,
@@ -301,70 +1303,179 @@ func TestTaskService_PublishFailure(t *testing.T) {
 							},
 						}, nil
 					},
-					UpdateTaskFailedFunc: func(taskID uint, errorMsg string) error {
+					UpdateTaskFailedFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string) error {
 						return nil
 					},
 				}
 				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
 			},
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			taskRepo, auditRepo, resultRepo := tt.setupMocks()
-			service := NewTaskServiceWithRepos(taskRepo, auditRepo, resultRepo)
-
-			err := service.PublishFailure(tt.taskID, tt.createdBy, tt.processedBy, tt.errorMsg)
-
-			if tt.wantErr {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-			}
-		})
-	}
-}
-
-func TestTaskService_ConsumeResult(t *testing.T) {
-	tests := []struct {
-		name       string
-		userID     uint
-		wantErr    bool
-		setupMocks func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository)
-	}{
 		{
-			name:    "no results available",
-			userID:  1,
-			wantErr: true,
+			name:        "task policy allows no retries",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			errorMsg:    "execution failed",
+			wantErr:     false,
 			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
-				resultRepo := &MockResultRepository{
-					FindOldestUnconsumedResultByUserIDFunc: func(userID uint) (*database.Result, error) {
-						return nil, gorm.ErrRecordNotFound
+				noRetries := 0
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "claim-token",
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+								Retry:     database.RetryPolicy{MaxRetries: &noRetries},
+							},
+						}, nil
+					},
+					ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+						t.Error("ReclaimStaleTask should not be called for a task without retries")
+						return nil
+					},
+					UpdateTaskFailedFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string) error {
+						return nil
 					},
 				}
-				return &MockTaskRepository{}, &MockTaskAuditRepository{}, resultRepo
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
 			},
 		},
 		{
-			name:    "success",
-			userID:  1,
-			wantErr: false,
+			name:        "non-retryable code",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			code:        "invalid_input",
+			errorMsg:    "bad input",
+			wantErr:     false,
 			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
-				resultRepo := &MockResultRepository{
-					FindOldestUnconsumedResultByUserIDFunc: func(userID uint) (*database.Result, error) {
-						return &database.Result{
-							ID:        1,
-							TaskID:    123,
-							CreatedBy: 1,
-							Result:    `{"result":"success"}`,
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "claim-token",
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+								Retry:     database.RetryPolicy{NonRetryableCodes: `["invalid_input"]`},
+							},
 						}, nil
 					},
-					MarkResultAsConsumedFunc: func(resultID uint) error {
+					ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+						t.Error("ReclaimStaleTask should not be called for a non-retryable code")
 						return nil
 					},
-				}
-				return &MockTaskRepository{}, &MockTaskAuditRepository{}, resultRepo
+					UpdateTaskFailedFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string) error {
+						if !strings.Contains(errorMsg, "non-retryable code invalid_input") {
+							t.Errorf("unexpected error message %q", errorMsg)
+						}
+						return nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:        "worker reports permanent failure",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			code:        "div_by_zero",
+			errorMsg:    "integer divide by zero",
+			retryable:   &notRetryable,
+			wantErr:     false,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "claim-token",
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+							},
+						}, nil
+					},
+					ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+						t.Error("ReclaimStaleTask should not be called for a permanent failure")
+						return nil
+					},
+					UpdateTaskFailedFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string) error {
+						if errorCode != "div_by_zero" {
+							t.Errorf("expected error code div_by_zero, got %q", errorCode)
+						}
+						return nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:        "retry would start after deadline",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			errorMsg:    "execution failed",
+			wantErr:     false,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				deadline := time.Now().Add(500 * time.Millisecond)
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusProcessing,
+							ClaimToken: "claim-token",
+							RetryCount: 1,
+							Deadline:   &deadline,
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+							},
+						}, nil
+					},
+					ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+						t.Error("ReclaimStaleTask should not be called when the retry would miss the deadline")
+						return nil
+					},
+					MarkDeadlineExceededFunc: func(taskID uint, errorMsg string) (bool, error) {
+						return true, nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:        "already reclaimed",
+			taskID:      123,
+			createdBy:   1,
+			processedBy: 2,
+			errorMsg:    "execution failed",
+			retryCount:  1,
+			wantErr:     true,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return &database.TaskAudit{
+							TaskID:     123,
+							Status:     database.TaskStatusPending,
+							RetryCount: 2,
+							Task: database.Task{
+								ID:        123,
+								CreatedBy: 1,
+							},
+						}, nil
+					},
+					ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+						t.Error("ReclaimStaleTask should not be called for a task this worker no longer holds")
+						return nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
 			},
 		},
 	}
@@ -372,16 +1483,356 @@ func TestTaskService_ConsumeResult(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			taskRepo, auditRepo, resultRepo := tt.setupMocks()
-			service := NewTaskServiceWithRepos(taskRepo, auditRepo, resultRepo)
+			service := newTestTaskService(taskRepo, auditRepo, resultRepo)
 
-			result, err := service.ConsumeResult(tt.userID)
+			err := service.PublishFailure(tt.taskID, tt.createdBy, tt.processedBy, "claim-token", dto.Failure{
+				Code:      tt.code,
+				Message:   tt.errorMsg,
+				Retryable: tt.retryable,
+			})
 
 			if tt.wantErr {
 				assert.Error(t, err)
-				assert.Nil(t, result)
 			} else {
 				assert.NoError(t, err)
-				assert.NotNil(t, result)
+			}
+		})
+	}
+}
+
+func TestTaskService_PublishFailure_RecordsFailureHistory(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			MaxRetries: 3,
+		},
+	}
+
+	workerID := uint(2)
+	var recorded *database.TaskFailure
+	auditRepo := &MockTaskAuditRepository{
+		FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+			return &database.TaskAudit{
+				TaskID:      taskID,
+				Status:      database.TaskStatusProcessing,
+				ClaimToken:  "claim-token",
+				ProcessedBy: &workerID,
+				RetryCount:  1,
+				Task:        database.Task{ID: taskID, CreatedBy: 1},
+			}, nil
+		},
+		CreateTaskFailureFunc: func(failure *database.TaskFailure) error {
+			recorded = failure
+			return nil
+		},
+	}
+	service := newTestTaskService(&MockTaskRepository{}, auditRepo, &MockResultRepository{})
+
+	err := service.PublishFailure(123, 1, workerID, "claim-token", dto.Failure{
+		Code:     "oom",
+		Message:  "out of memory",
+		TrapKind: "memory_grow",
+		Details:  "at alloc (wasm-function[12])",
+	})
+
+	retryable := true
+	assert.NoError(t, err)
+	assert.Equal(t, &database.TaskFailure{
+		TaskID:    123,
+		Attempt:   2,
+		Code:      "oom",
+		ErrorMsg:  "out of memory",
+		Retryable: &retryable,
+		TrapKind:  "memory_grow",
+		Details:   "at alloc (wasm-function[12])",
+		WorkerID:  &workerID,
+	}, recorded)
+}
+
+func TestTaskService_ConsumeResult(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			ResultLeaseSeconds: 60,
+		},
+	}
+
+	tests := []struct {
+		name       string
+		userID     uint
+		wantErr    error
+		wantStatus string
+		setupMocks func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository)
+	}{
+		{
+			name:    "no results available",
+			userID:  1,
+			wantErr: ErrNoTasksAvailable,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				resultRepo := &MockResultRepository{
+					LeaseResultsFunc: func(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error) {
+						return []*database.Result{}, nil
+					},
+				}
+				return &MockTaskRepository{}, &MockTaskAuditRepository{}, resultRepo
+			},
+		},
+		{
+			name:    "lease fails",
+			userID:  1,
+			wantErr: errors.New("failed to lease results: database error"),
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				resultRepo := &MockResultRepository{
+					LeaseResultsFunc: func(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error) {
+						return nil, errors.New("database error")
+					},
+				}
+				return &MockTaskRepository{}, &MockTaskAuditRepository{}, resultRepo
+			},
+		},
+		{
+			name:       "success",
+			userID:     1,
+			wantStatus: "completed",
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				resultRepo := &MockResultRepository{
+					LeaseResultsFunc: func(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error) {
+						assert.Equal(t, uint(1), userID)
+						assert.Len(t, receipts, 1)
+						assert.WithinDuration(t, time.Now().Add(time.Minute), leaseExpiresAt, 5*time.Second)
+						return []*database.Result{{
+							ID:             1,
+							TaskID:         123,
+							CreatedBy:      1,
+							Result:         `{"result":"success"}`,
+							Receipt:        receipts[0],
+							LeaseExpiresAt: &leaseExpiresAt,
+						}}, nil
+					},
+				}
+				return &MockTaskRepository{}, &MockTaskAuditRepository{}, resultRepo
+			},
+		},
+		{
+			name:       "expired task",
+			userID:     1,
+			wantStatus: "expired",
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				resultRepo := &MockResultRepository{
+					LeaseResultsFunc: func(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error) {
+						return []*database.Result{{
+							ID:             2,
+							TaskID:         124,
+							CreatedBy:      1,
+							Status:         database.TaskStatusExpired,
+							Result:         "null",
+							Receipt:        receipts[0],
+							LeaseExpiresAt: &leaseExpiresAt,
+						}}, nil
+					},
+				}
+				return &MockTaskRepository{}, &MockTaskAuditRepository{}, resultRepo
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskRepo, auditRepo, resultRepo := tt.setupMocks()
+			service := newTestTaskService(taskRepo, auditRepo, resultRepo)
+
+			leased, err := service.ConsumeResult(tt.userID)
+
+			if tt.wantErr != nil {
+				if errors.Is(tt.wantErr, ErrNoTasksAvailable) {
+					assert.ErrorIs(t, err, tt.wantErr)
+				} else {
+					assert.EqualError(t, err, tt.wantErr.Error())
+				}
+				assert.Nil(t, leased)
+			} else {
+				assert.NoError(t, err)
+				if assert.NotNil(t, leased) {
+					assert.Equal(t, tt.wantStatus, leased.Result.Status)
+					assert.NotEmpty(t, leased.Receipt)
+					assert.NotZero(t, leased.Result.ID)
+					assert.False(t, leased.LeaseExpiresAt.IsZero())
+				}
+			}
+		})
+	}
+}
+
+func TestTaskService_ConsumeResults(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			ResultLeaseSeconds: 60,
+		},
+	}
+
+	t.Run("rejects invalid batch sizes", func(t *testing.T) {
+		service := newTestTaskService(&MockTaskRepository{}, &MockTaskAuditRepository{}, &MockResultRepository{
+			LeaseResultsFunc: func(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error) {
+				t.Error("LeaseResults should not be called for an invalid batch size")
+				return nil, nil
+			},
+		})
+
+		for _, maxResults := range []int{0, MaxResultBatchSize + 1} {
+			_, err := service.ConsumeResults(1, maxResults)
+			assert.ErrorIs(t, err, ErrInvalidBatchSize)
+		}
+	})
+
+	t.Run("returns fewer results than requested", func(t *testing.T) {
+		service := newTestTaskService(&MockTaskRepository{}, &MockTaskAuditRepository{}, &MockResultRepository{
+			LeaseResultsFunc: func(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error) {
+				assert.Len(t, receipts, 3)
+				assert.NotEqual(t, receipts[0], receipts[1])
+				return []*database.Result{
+					{ID: 1, TaskID: 10, CreatedBy: 1, Result: "1", Receipt: receipts[0], LeaseExpiresAt: &leaseExpiresAt},
+					{ID: 2, TaskID: 11, CreatedBy: 1, Result: "2", Receipt: receipts[1], LeaseExpiresAt: &leaseExpiresAt},
+				}, nil
+			},
+		})
+
+		leased, err := service.ConsumeResults(1, 3)
+
+		assert.NoError(t, err)
+		if assert.Len(t, leased, 2) {
+			assert.Equal(t, uint(1), leased[0].Result.ID)
+			assert.Equal(t, uint(2), leased[1].Result.ID)
+			assert.NotEqual(t, leased[0].Receipt, leased[1].Receipt)
+		}
+	})
+
+	t.Run("delivers a result that cannot be decoded as a string", func(t *testing.T) {
+		service := newTestTaskService(&MockTaskRepository{}, &MockTaskAuditRepository{}, &MockResultRepository{
+			LeaseResultsFunc: func(userID uint, receipts []string, leaseExpiresAt time.Time) ([]*database.Result, error) {
+				return []*database.Result{
+					{ID: 1, TaskID: 10, CreatedBy: 1, Result: "{not json", Receipt: receipts[0], LeaseExpiresAt: &leaseExpiresAt},
+					{ID: 2, TaskID: 11, CreatedBy: 1, Result: "2", Receipt: receipts[1], LeaseExpiresAt: &leaseExpiresAt},
+				}, nil
+			},
+		})
+
+		leased, err := service.ConsumeResults(1, 2)
+
+		assert.NoError(t, err)
+		if assert.Len(t, leased, 2) {
+			assert.Equal(t, "{not json", leased[0].Result.Result)
+			assert.Contains(t, leased[0].Result.DecodeError, "not valid JSON")
+			assert.NotEmpty(t, leased[0].Receipt)
+			assert.Equal(t, float64(2), leased[1].Result.Result)
+			assert.Empty(t, leased[1].Result.DecodeError)
+		}
+	})
+}
+
+func TestTaskService_AckResult(t *testing.T) {
+	leasedResult := func(consumed bool) func(resultID uint) (*database.Result, error) {
+		return func(resultID uint) (*database.Result, error) {
+			return &database.Result{ID: resultID, CreatedBy: 1, Receipt: "receipt", Consumed: consumed}, nil
+		}
+	}
+
+	tests := []struct {
+		name       string
+		userID     uint
+		receipt    string
+		wantErr    error
+		setupMocks func() *MockResultRepository
+	}{
+		{
+			name:    "result not found",
+			userID:  1,
+			receipt: "receipt",
+			wantErr: ErrResultNotFound,
+			setupMocks: func() *MockResultRepository {
+				return &MockResultRepository{
+					FindResultByIDFunc: func(resultID uint) (*database.Result, error) {
+						return nil, gorm.ErrRecordNotFound
+					},
+				}
+			},
+		},
+		{
+			name:    "another user's result",
+			userID:  2,
+			receipt: "receipt",
+			wantErr: ErrResultAccessDenied,
+			setupMocks: func() *MockResultRepository {
+				return &MockResultRepository{FindResultByIDFunc: leasedResult(false)}
+			},
+		},
+		{
+			name:    "superseded receipt",
+			userID:  1,
+			receipt: "old-receipt",
+			wantErr: ErrResultNotLeased,
+			setupMocks: func() *MockResultRepository {
+				return &MockResultRepository{
+					FindResultByIDFunc: leasedResult(false),
+					AckResultFunc: func(resultID uint, receipt string) (bool, error) {
+						t.Error("AckResult should not be called with a superseded receipt")
+						return false, nil
+					},
+				}
+			},
+		},
+		{
+			name:    "leased again during the ack",
+			userID:  1,
+			receipt: "receipt",
+			wantErr: ErrResultNotLeased,
+			setupMocks: func() *MockResultRepository {
+				return &MockResultRepository{
+					FindResultByIDFunc: leasedResult(false),
+					AckResultFunc: func(resultID uint, receipt string) (bool, error) {
+						return false, nil
+					},
+				}
+			},
+		},
+		{
+			name:    "repeated ack",
+			userID:  1,
+			receipt: "receipt",
+			setupMocks: func() *MockResultRepository {
+				return &MockResultRepository{
+					FindResultByIDFunc: leasedResult(true),
+					AckResultFunc: func(resultID uint, receipt string) (bool, error) {
+						t.Error("AckResult should not be called for a result that was already acked")
+						return false, nil
+					},
+				}
+			},
+		},
+		{
+			name:    "success",
+			userID:  1,
+			receipt: "receipt",
+			setupMocks: func() *MockResultRepository {
+				return &MockResultRepository{
+					FindResultByIDFunc: leasedResult(false),
+					AckResultFunc: func(resultID uint, receipt string) (bool, error) {
+						assert.Equal(t, uint(7), resultID)
+						assert.Equal(t, "receipt", receipt)
+						return true, nil
+					},
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestTaskService(&MockTaskRepository{}, &MockTaskAuditRepository{}, tt.setupMocks())
+
+			err := service.AckResult(7, tt.userID, tt.receipt)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
 			}
 		})
 	}
@@ -424,24 +1875,1249 @@ func TestTaskService_ReclaimStaleTasks(t *testing.T) {
 							{TaskID: 2, RetryCount: 0, Task: database.Task{ID: 2, CreatedBy: 1}},
 						}, nil
 					},
-					ReclaimStaleTaskFunc: func(taskID uint, errorMsg string) error {
+					ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
 						return nil
 					},
 				}
 				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
 			},
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			taskRepo, auditRepo, resultRepo := tt.setupMocks()
-			service := NewTaskServiceWithRepos(taskRepo, auditRepo, resultRepo)
-
-			reclaimed, err := service.ReclaimStaleTasks()
-
-			assert.NoError(t, err)
-			assert.Equal(t, tt.wantReclaimed, reclaimed)
-		})
-	}
+		{
+			name:          "task policy overrides max retries",
+			wantReclaimed: 1,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				noRetries, tenRetries := 0, 10
+				auditRepo := &MockTaskAuditRepository{
+					FindStaleTasksFunc: func(timeoutDuration time.Duration) ([]*database.TaskAudit, error) {
+						return []*database.TaskAudit{
+							{TaskID: 1, RetryCount: 0, Task: database.Task{ID: 1, CreatedBy: 1, Retry: database.RetryPolicy{MaxRetries: &noRetries}}},
+							{TaskID: 2, RetryCount: 5, Task: database.Task{ID: 2, CreatedBy: 1, Retry: database.RetryPolicy{MaxRetries: &tenRetries}}},
+						}, nil
+					},
+					ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+						if taskID != 2 {
+							t.Errorf("task %d should not be reclaimed", taskID)
+						}
+						return nil
+					},
+					UpdateTaskFailedFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string) error {
+						if taskID != 1 {
+							t.Errorf("task %d should not be failed", taskID)
+						}
+						return nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:          "messages report the task's own timeout",
+			wantReclaimed: 1,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindStaleTasksFunc: func(timeoutDuration time.Duration) ([]*database.TaskAudit, error) {
+						return []*database.TaskAudit{
+							{TaskID: 1, RetryCount: 0, TimeoutSeconds: 30, Task: database.Task{ID: 1, CreatedBy: 1}},
+							{TaskID: 2, RetryCount: 3, TimeoutSeconds: 45, Task: database.Task{ID: 2, CreatedBy: 1}},
+						}, nil
+					},
+					ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+						assert.Contains(t, errorMsg, "no heartbeat for 30 seconds")
+						return nil
+					},
+					UpdateTaskFailedFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string) error {
+						assert.Contains(t, errorMsg, "no heartbeat for 45 seconds")
+						return nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskRepo, auditRepo, resultRepo := tt.setupMocks()
+			service := newTestTaskService(taskRepo, auditRepo, resultRepo)
+
+			reclaimed, err := service.ReclaimStaleTasks()
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantReclaimed, reclaimed)
+		})
+	}
+}
+
+func TestTaskService_FailOverdueTasks(t *testing.T) {
+	deadline := time.Now().Add(-time.Minute)
+	var marked []uint
+	var resolved []uint
+	auditRepo := &MockTaskAuditRepository{
+		FindOverdueTasksFunc: func(now time.Time) ([]*database.TaskAudit, error) {
+			return []*database.TaskAudit{
+				{TaskID: 1, Status: database.TaskStatusPending, Deadline: &deadline},
+				{TaskID: 2, Status: database.TaskStatusProcessing, Deadline: &deadline},
+			}, nil
+		},
+		MarkDeadlineExceededFunc: func(taskID uint, errorMsg string) (bool, error) {
+			marked = append(marked, taskID)
+			// Task 2 finished between the query and the update.
+			return taskID == 1, nil
+		},
+	}
+	taskRepo := &MockTaskRepository{
+		FindDependentTaskIDsFunc: func(taskID uint) ([]uint, error) {
+			resolved = append(resolved, taskID)
+			return nil, nil
+		},
+	}
+	service := newTestTaskService(taskRepo, auditRepo, &MockResultRepository{})
+
+	failed, err := service.FailOverdueTasks()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, []uint{1, 2}, marked)
+	assert.Equal(t, []uint{1}, resolved)
+}
+
+func TestTaskService_ExpireTasks(t *testing.T) {
+	expiresAt := time.Now().Add(-time.Second)
+	var results []*database.Result
+	var resolved []uint
+	auditRepo := &MockTaskAuditRepository{
+		FindExpiredTasksFunc: func(now time.Time) ([]*database.TaskAudit, error) {
+			return []*database.TaskAudit{
+				{TaskID: 1, Status: database.TaskStatusPending, TTLSeconds: 60, ExpiresAt: &expiresAt, Task: database.Task{ID: 1, CreatedBy: 7}},
+				{TaskID: 2, Status: database.TaskStatusPending, TTLSeconds: 60, ExpiresAt: &expiresAt, Task: database.Task{ID: 2, CreatedBy: 7}},
+			}, nil
+		},
+		ExpireTaskFunc: func(taskID uint, errorMsg string) (bool, error) {
+			assert.Contains(t, errorMsg, "TTL of 60 seconds")
+			// Task 2 was claimed between the query and the update.
+			return taskID == 1, nil
+		},
+	}
+	taskRepo := &MockTaskRepository{
+		FindDependentTaskIDsFunc: func(taskID uint) ([]uint, error) {
+			resolved = append(resolved, taskID)
+			return nil, nil
+		},
+	}
+	resultRepo := &MockResultRepository{
+		CreateResultFunc: func(result *database.Result) error {
+			results = append(results, result)
+			return nil
+		},
+	}
+	service := newTestTaskService(taskRepo, auditRepo, resultRepo)
+
+	expired, err := service.ExpireTasks()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, []uint{1}, resolved)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, uint(1), results[0].TaskID)
+		assert.Equal(t, uint(7), results[0].CreatedBy)
+		assert.Equal(t, database.TaskStatusExpired, results[0].Status)
+		assert.Nil(t, results[0].ProcessedBy)
+	}
+}
+
+func TestTaskService_ExpireTasks_ResultFailure(t *testing.T) {
+	var resolved []uint
+	auditRepo := &MockTaskAuditRepository{
+		FindExpiredTasksFunc: func(now time.Time) ([]*database.TaskAudit, error) {
+			return []*database.TaskAudit{
+				{TaskID: 1, Status: database.TaskStatusPending, TTLSeconds: 60, Task: database.Task{ID: 1, CreatedBy: 7}},
+				{TaskID: 2, Status: database.TaskStatusPending, TTLSeconds: 60, Task: database.Task{ID: 2, CreatedBy: 7}},
+			}, nil
+		},
+		ExpireTaskFunc: func(taskID uint, errorMsg string) (bool, error) {
+			return true, nil
+		},
+	}
+	taskRepo := &MockTaskRepository{
+		FindDependentTaskIDsFunc: func(taskID uint) ([]uint, error) {
+			resolved = append(resolved, taskID)
+			return nil, nil
+		},
+	}
+	resultRepo := &MockResultRepository{
+		CreateResultFunc: func(result *database.Result) error {
+			if result.TaskID == 2 {
+				return errors.New("database error")
+			}
+			return nil
+		},
+	}
+	service := newTestTaskService(taskRepo, auditRepo, resultRepo)
+
+	expired, err := service.ExpireTasks()
+
+	assert.ErrorContains(t, err, "failed to expire 1 of 2 tasks")
+	assert.ErrorContains(t, err, "database error")
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, []uint{1}, resolved, "a task whose result was not stored must not resolve its dependents")
+}
+
+func TestTaskService_PublishTask_TTL(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			QueueTTLSeconds: map[string]int{"gpu": 600},
+		},
+	}
+	runAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		task        dto.Task
+		opts        dto.PublishOptions
+		wantTTL     int
+		wantExpires bool
+	}{
+		{
+			name: "no ttl",
+			task: dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}},
+		},
+		{
+			name:        "task ttl",
+			task:        dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}, TTLSeconds: 30},
+			wantTTL:     30,
+			wantExpires: true,
+		},
+		{
+			name:        "queue default",
+			task:        dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}, Queue: "gpu"},
+			wantTTL:     600,
+			wantExpires: true,
+		},
+		{
+			name:        "ttl counts from run_at",
+			task:        dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}, TTLSeconds: 30},
+			opts:        dto.PublishOptions{RunAt: &runAt},
+			wantTTL:     30,
+			wantExpires: true,
+		},
+		{
+			name:        "task ttl overrides queue default",
+			task:        dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}, Queue: "gpu", TTLSeconds: 30},
+			wantTTL:     30,
+			wantExpires: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *database.TaskAudit
+			taskRepo := &MockTaskRepository{
+				CreateTaskFunc: func(task *database.Task) error {
+					task.ID = 42
+					return nil
+				},
+			}
+			auditRepo := &MockTaskAuditRepository{
+				CreateTaskAuditFunc: func(audit *database.TaskAudit) error {
+					created = audit
+					return nil
+				},
+			}
+			service := newTestTaskService(taskRepo, auditRepo, &MockResultRepository{})
+
+			availableAt := time.Now()
+			if tt.opts.RunAt != nil {
+				availableAt = *tt.opts.RunAt
+			}
+			_, err := service.PublishTask(tt.task, 1, tt.opts)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTTL, created.TTLSeconds)
+			if !tt.wantExpires {
+				assert.Nil(t, created.ExpiresAt)
+				return
+			}
+			if assert.NotNil(t, created.ExpiresAt) {
+				assert.WithinDuration(t, availableAt.Add(time.Duration(tt.wantTTL)*time.Second), *created.ExpiresAt, time.Second)
+			}
+		})
+	}
+}
+
+func TestTaskService_PublishTask_RunAt(t *testing.T) {
+	config.App = &config.Config{}
+	runAt := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		opts    dto.PublishOptions
+		wantRun *time.Time
+	}{
+		{
+			name: "immediate",
+		},
+		{
+			name:    "run_at in the future",
+			opts:    dto.PublishOptions{RunAt: &runAt},
+			wantRun: &runAt,
+		},
+		{
+			name: "run_at in the past",
+			opts: dto.PublishOptions{RunAt: &past},
+		},
+		{
+			name:    "delay",
+			opts:    dto.PublishOptions{Delay: time.Hour},
+			wantRun: &runAt,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *database.TaskAudit
+			taskRepo := &MockTaskRepository{
+				CreateTaskFunc: func(task *database.Task) error {
+					task.ID = 42
+					return nil
+				},
+			}
+			auditRepo := &MockTaskAuditRepository{
+				CreateTaskAuditFunc: func(audit *database.TaskAudit) error {
+					created = audit
+					return nil
+				},
+			}
+			service := newTestTaskService(taskRepo, auditRepo, &MockResultRepository{})
+
+			_, err := service.PublishTask(dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}}, 1, tt.opts)
+
+			assert.NoError(t, err)
+			if tt.wantRun == nil {
+				assert.Nil(t, created.NextAttemptAt)
+				assert.Nil(t, created.RunAt)
+				return
+			}
+			if assert.NotNil(t, created.NextAttemptAt) && assert.NotNil(t, created.RunAt) {
+				assert.WithinDuration(t, *tt.wantRun, *created.NextAttemptAt, time.Second)
+				assert.Equal(t, *created.NextAttemptAt, *created.RunAt)
+			}
+		})
+	}
+}
+
+func TestTaskService_CancelTask(t *testing.T) {
+	ownedAudit := func(taskID uint) (*database.TaskAudit, error) {
+		return &database.TaskAudit{
+			TaskID: taskID,
+			Status: database.TaskStatusProcessing,
+			Task: database.Task{
+				ID:        taskID,
+				CreatedBy: 1,
+			},
+		}, nil
+	}
+
+	tests := []struct {
+		name       string
+		userID     uint
+		wantErr    error
+		setupMocks func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository)
+	}{
+		{
+			name:    "task not found",
+			userID:  1,
+			wantErr: ErrTaskNotFound,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return nil, gorm.ErrRecordNotFound
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:    "not the owner",
+			userID:  2,
+			wantErr: ErrTaskAccessDenied,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: ownedAudit,
+					CancelTaskFunc: func(taskID uint) (bool, error) {
+						t.Error("CancelTask should not be called for another user's task")
+						return false, nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:    "already finished",
+			userID:  1,
+			wantErr: ErrTaskNotCancellable,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: ownedAudit,
+					CancelTaskFunc: func(taskID uint) (bool, error) {
+						return false, nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:   "success",
+			userID: 1,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: ownedAudit,
+					CancelTaskFunc: func(taskID uint) (bool, error) {
+						return true, nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskRepo, auditRepo, resultRepo := tt.setupMocks()
+			service := newTestTaskService(taskRepo, auditRepo, resultRepo)
+
+			err := service.CancelTask(123, tt.userID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTaskService_RequeueTask(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{},
+	}
+
+	tests := []struct {
+		name          string
+		userID        uint
+		findErr       error
+		dependencyIDs []uint
+		requeued      bool
+		wantStatus    database.TaskStatus
+		wantErr       error
+	}{
+		{
+			name:    "task not found",
+			userID:  1,
+			findErr: gorm.ErrRecordNotFound,
+			wantErr: ErrTaskNotFound,
+		},
+		{
+			name:    "not the owner",
+			userID:  2,
+			wantErr: ErrTaskAccessDenied,
+		},
+		{
+			name:       "task has not failed",
+			userID:     1,
+			wantStatus: database.TaskStatusPending,
+			wantErr:    ErrTaskNotFailed,
+		},
+		{
+			name:       "requeued as pending",
+			userID:     1,
+			requeued:   true,
+			wantStatus: database.TaskStatusPending,
+		},
+		{
+			name:          "task with dependencies waits on them again",
+			userID:        1,
+			dependencyIDs: []uint{7},
+			requeued:      true,
+			wantStatus:    database.TaskStatusBlocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requeuedStatus database.TaskStatus
+			taskRepo := &MockTaskRepository{
+				FindTaskDependencyIDsFunc: func(taskID uint) ([]uint, error) {
+					return tt.dependencyIDs, nil
+				},
+			}
+			auditRepo := &MockTaskAuditRepository{
+				FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+					if tt.findErr != nil {
+						return nil, tt.findErr
+					}
+					return &database.TaskAudit{
+						TaskID: taskID,
+						Status: database.TaskStatusFailed,
+						Task:   database.Task{ID: taskID, CreatedBy: 1},
+					}, nil
+				},
+				RequeueFailedTaskFunc: func(taskID uint, status database.TaskStatus, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+					requeuedStatus = status
+					return tt.requeued, nil
+				},
+			}
+			service := newTestTaskService(taskRepo, auditRepo, &MockResultRepository{})
+
+			err := service.RequeueTask(123, tt.userID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantStatus, requeuedStatus)
+		})
+	}
+}
+
+func TestTaskService_PublishTask_DependsOn(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{},
+	}
+
+	var dependencies []database.TaskDependency
+	var createdStatus database.TaskStatus
+	taskRepo := &MockTaskRepository{
+		CreateTaskFunc: func(task *database.Task) error {
+			task.ID = 42
+			return nil
+		},
+		CreateTaskDependenciesFunc: func(deps []database.TaskDependency) error {
+			dependencies = deps
+			return nil
+		},
+		FindTaskDependencyIDsFunc: func(taskID uint) ([]uint, error) {
+			return []uint{5, 6}, nil
+		},
+	}
+	auditRepo := &MockTaskAuditRepository{
+		CreateTaskAuditFunc: func(audit *database.TaskAudit) error {
+			createdStatus = audit.Status
+			return nil
+		},
+		FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+			return &database.TaskAudit{TaskID: taskID, Status: createdStatus}, nil
+		},
+		FindTaskAuditsByTaskIDsFunc: func(taskIDs []uint) ([]*database.TaskAudit, error) {
+			return []*database.TaskAudit{
+				{TaskID: 5, Status: database.TaskStatusCompleted},
+				{TaskID: 6, Status: database.TaskStatusProcessing},
+			}, nil
+		},
+		UnblockTaskFunc: func(taskID uint, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+			t.Error("task should stay blocked while a dependency is still running")
+			return false, nil
+		},
+	}
+	service := newTestTaskService(taskRepo, auditRepo, &MockResultRepository{})
+
+	task := dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}}
+	taskID, err := service.PublishTask(task, 1, dto.PublishOptions{DependsOn: []uint{5, 6}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(42), taskID)
+	assert.Equal(t, database.TaskStatusBlocked, createdStatus)
+	assert.Equal(t, []database.TaskDependency{
+		{TaskID: 42, DependsOnTaskID: 5},
+		{TaskID: 42, DependsOnTaskID: 6},
+	}, dependencies)
+}
+
+func TestTaskService_ResolveDependents(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{},
+	}
+
+	tests := []struct {
+		name          string
+		statuses      map[uint]database.TaskStatus
+		dependsOn     map[uint][]uint
+		wantUnblocked []uint
+		wantFailed    []uint
+	}{
+		{
+			name: "completed dependency releases child",
+			statuses: map[uint]database.TaskStatus{
+				1: database.TaskStatusCompleted,
+				2: database.TaskStatusBlocked,
+			},
+			dependsOn:     map[uint][]uint{2: {1}},
+			wantUnblocked: []uint{2},
+		},
+		{
+			name: "child waits for its other dependencies",
+			statuses: map[uint]database.TaskStatus{
+				1: database.TaskStatusCompleted,
+				2: database.TaskStatusBlocked,
+				3: database.TaskStatusPending,
+			},
+			dependsOn: map[uint][]uint{2: {1, 3}},
+		},
+		{
+			name: "failure cascades to all descendants",
+			statuses: map[uint]database.TaskStatus{
+				1: database.TaskStatusFailed,
+				2: database.TaskStatusBlocked,
+				3: database.TaskStatusBlocked,
+			},
+			dependsOn:  map[uint][]uint{2: {1}, 3: {2}},
+			wantFailed: []uint{2, 3},
+		},
+		{
+			name: "expired dependency fails child",
+			statuses: map[uint]database.TaskStatus{
+				1: database.TaskStatusExpired,
+				2: database.TaskStatusBlocked,
+			},
+			dependsOn:  map[uint][]uint{2: {1}},
+			wantFailed: []uint{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var unblocked, failed []uint
+			taskRepo := &MockTaskRepository{
+				FindTaskDependencyIDsFunc: func(taskID uint) ([]uint, error) {
+					return tt.dependsOn[taskID], nil
+				},
+				FindDependentTaskIDsFunc: func(taskID uint) ([]uint, error) {
+					var dependents []uint
+					for child, parents := range tt.dependsOn {
+						for _, parent := range parents {
+							if parent == taskID {
+								dependents = append(dependents, child)
+							}
+						}
+					}
+					return dependents, nil
+				},
+			}
+			auditRepo := &MockTaskAuditRepository{
+				FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+					return &database.TaskAudit{TaskID: taskID, Status: tt.statuses[taskID]}, nil
+				},
+				FindTaskAuditsByTaskIDsFunc: func(taskIDs []uint) ([]*database.TaskAudit, error) {
+					audits := make([]*database.TaskAudit, 0, len(taskIDs))
+					for _, taskID := range taskIDs {
+						audits = append(audits, &database.TaskAudit{TaskID: taskID, Status: tt.statuses[taskID]})
+					}
+					return audits, nil
+				},
+				UnblockTaskFunc: func(taskID uint, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+					unblocked = append(unblocked, taskID)
+					tt.statuses[taskID] = database.TaskStatusPending
+					return true, nil
+				},
+				FailBlockedTaskFunc: func(taskID uint, errorCode string, errorMsg string) (bool, error) {
+					assert.Contains(t, errorMsg, "Dependency task")
+					failed = append(failed, taskID)
+					tt.statuses[taskID] = database.TaskStatusFailed
+					return true, nil
+				},
+			}
+			service := newTestTaskService(taskRepo, auditRepo, &MockResultRepository{}).(*taskService)
+
+			service.resolveDependents(1)
+
+			assert.Equal(t, tt.wantUnblocked, unblocked)
+			assert.Equal(t, tt.wantFailed, failed)
+		})
+	}
+}
+
+func TestTaskService_ResolveBlockedTask_GroupPolicy(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{},
+	}
+
+	reduceArgs := `[{"$from_task":1},{"$from_task":2},{"$from_task":3}]`
+
+	tests := []struct {
+		name          string
+		policy        database.GroupFailurePolicy
+		statuses      map[uint]database.TaskStatus
+		wantUnblocked bool
+		wantFailed    bool
+		wantCancelled []uint
+	}{
+		{
+			name:          "abort fails the reduce task and cancels unfinished members",
+			policy:        database.GroupFailurePolicyAbort,
+			statuses:      map[uint]database.TaskStatus{1: database.TaskStatusCompleted, 2: database.TaskStatusFailed, 3: database.TaskStatusPending},
+			wantFailed:    true,
+			wantCancelled: []uint{3},
+		},
+		{
+			name:     "reduce_successes waits for unfinished members",
+			policy:   database.GroupFailurePolicyReduceSuccesses,
+			statuses: map[uint]database.TaskStatus{1: database.TaskStatusCompleted, 2: database.TaskStatusFailed, 3: database.TaskStatusProcessing},
+		},
+		{
+			name:          "reduce_successes runs once some members completed",
+			policy:        database.GroupFailurePolicyReduceSuccesses,
+			statuses:      map[uint]database.TaskStatus{1: database.TaskStatusCompleted, 2: database.TaskStatusFailed, 3: database.TaskStatusCompleted},
+			wantUnblocked: true,
+		},
+		{
+			name:       "reduce_successes fails when no member completed",
+			policy:     database.GroupFailurePolicyReduceSuccesses,
+			statuses:   map[uint]database.TaskStatus{1: database.TaskStatusFailed, 2: database.TaskStatusCancelled, 3: database.TaskStatusFailed},
+			wantFailed: true,
+		},
+		{
+			name:     "wait_for_retries keeps waiting on a failed member",
+			policy:   database.GroupFailurePolicyWaitForRetries,
+			statuses: map[uint]database.TaskStatus{1: database.TaskStatusCompleted, 2: database.TaskStatusFailed, 3: database.TaskStatusCompleted},
+		},
+		{
+			name:       "wait_for_retries fails on a cancelled member",
+			policy:     database.GroupFailurePolicyWaitForRetries,
+			statuses:   map[uint]database.TaskStatus{1: database.TaskStatusCompleted, 2: database.TaskStatusCancelled, 3: database.TaskStatusPending},
+			wantFailed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groupID := uint(4)
+			tt.statuses[10] = database.TaskStatusBlocked
+			var unblocked, failed bool
+			var cancelled []uint
+			taskRepo := &MockTaskRepository{
+				FindTaskDependencyIDsFunc: func(taskID uint) ([]uint, error) {
+					return []uint{1, 2, 3}, nil
+				},
+				FindDependentTaskIDsFunc: func(taskID uint) ([]uint, error) {
+					return []uint{10}, nil
+				},
+			}
+			auditRepo := &MockTaskAuditRepository{
+				FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+					audit := &database.TaskAudit{
+						TaskID: taskID,
+						Status: tt.statuses[taskID],
+						Task:   database.Task{ID: taskID, CreatedBy: 1},
+					}
+					if taskID == 10 {
+						audit.Task.Args = reduceArgs
+						audit.Task.ReducesGroupID = &groupID
+						audit.Task.ReducesGroup = &database.TaskGroup{ID: groupID, OnFailure: tt.policy}
+					}
+					return audit, nil
+				},
+				FindTaskAuditsByTaskIDsFunc: func(taskIDs []uint) ([]*database.TaskAudit, error) {
+					audits := make([]*database.TaskAudit, 0, len(taskIDs))
+					for _, taskID := range taskIDs {
+						audits = append(audits, &database.TaskAudit{TaskID: taskID, Status: tt.statuses[taskID]})
+					}
+					return audits, nil
+				},
+				UnblockTaskFunc: func(taskID uint, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+					unblocked = true
+					return true, nil
+				},
+				FailBlockedTaskFunc: func(taskID uint, errorCode string, errorMsg string) (bool, error) {
+					failed = true
+					tt.statuses[taskID] = database.TaskStatusFailed
+					return true, nil
+				},
+				CancelTaskFunc: func(taskID uint) (bool, error) {
+					cancelled = append(cancelled, taskID)
+					tt.statuses[taskID] = database.TaskStatusCancelled
+					return true, nil
+				},
+			}
+			service := newTestTaskService(taskRepo, auditRepo, &MockResultRepository{}).(*taskService)
+
+			failedReduce, err := service.resolveBlockedTask(10)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantFailed, failedReduce)
+			assert.Equal(t, tt.wantFailed, failed)
+			assert.Equal(t, tt.wantUnblocked, unblocked)
+			assert.Equal(t, tt.wantCancelled, cancelled)
+		})
+	}
+}
+
+// testSum3WasmModule exports sum3(i32, i32, i32) -> i32.
+const testSum3WasmModule = "AGFzbQEAAAABCAFgA39/fwF/AwIBAAcIAQRzdW0zAAAKDAEKACAAIAFqIAJqCw=="
+
+func TestTaskService_ConsumeTasks_PartialGroup(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			TimeoutSeconds: 300,
+		},
+	}
+
+	const reduceArgs = `[{"$from_task":1},{"$from_task":2}]`
+	groupID := uint(4)
+	statuses := map[uint]database.TaskStatus{
+		1:  database.TaskStatusFailed,
+		2:  database.TaskStatusCompleted,
+		10: database.TaskStatusBlocked,
+	}
+	reduceTask := database.Task{
+		ID:             10,
+		CreatedBy:      1,
+		WasmModule:     testSum3WasmModule,
+		Func:           "sum3",
+		Args:           reduceArgs,
+		ReducesGroupID: &groupID,
+		ReducesGroup:   &database.TaskGroup{ID: groupID, OnFailure: database.GroupFailurePolicyReduceSuccesses},
+	}
+	failed := false
+	taskRepo := &MockTaskRepository{
+		FindTaskDependencyIDsFunc: func(taskID uint) ([]uint, error) {
+			return []uint{1, 2}, nil
+		},
+		FindDependentTaskIDsFunc: func(taskID uint) ([]uint, error) {
+			return []uint{10}, nil
+		},
+	}
+	auditRepo := &MockTaskAuditRepository{
+		FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+			audit := &database.TaskAudit{TaskID: taskID, Status: statuses[taskID], Task: database.Task{ID: taskID, CreatedBy: 1}}
+			if taskID == 10 {
+				audit.Task = reduceTask
+			}
+			return audit, nil
+		},
+		FindTaskAuditsByTaskIDsFunc: func(taskIDs []uint) ([]*database.TaskAudit, error) {
+			audits := make([]*database.TaskAudit, 0, len(taskIDs))
+			for _, taskID := range taskIDs {
+				audits = append(audits, &database.TaskAudit{TaskID: taskID, Status: statuses[taskID]})
+			}
+			return audits, nil
+		},
+		UnblockTaskFunc: func(taskID uint, effectiveAt time.Time, expiresAt *time.Time) (bool, error) {
+			statuses[taskID] = database.TaskStatusPending
+			return true, nil
+		},
+		FindAndClaimPendingTasksFunc: func(queues []string, workerID uint, claimTokens []string, leaseDuration time.Duration) ([]*database.TaskAudit, error) {
+			if statuses[10] != database.TaskStatusPending {
+				return nil, nil
+			}
+			statuses[10] = database.TaskStatusProcessing
+			return []*database.TaskAudit{{TaskID: 10, ClaimToken: claimTokens[0], Task: reduceTask}}, nil
+		},
+		UpdateTaskFailedFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string) error {
+			failed = true
+			return nil
+		},
+	}
+	resultRepo := &MockResultRepository{
+		FindResultByTaskIDFunc: func(taskID uint) (*database.Result, error) {
+			switch taskID {
+			case 1:
+				return &database.Result{TaskID: 1, Result: `5`}, nil
+			case 2:
+				return &database.Result{TaskID: 2, Result: `40`}, nil
+			}
+			return nil, gorm.ErrRecordNotFound
+		},
+	}
+	service := newTestTaskService(taskRepo, auditRepo, resultRepo)
+
+	service.(*taskService).resolveDependents(1)
+	claimed, err := service.ConsumeTasks(2, nil, 1)
+
+	assert.NoError(t, err)
+	assert.False(t, failed)
+	if assert.Len(t, claimed, 1) {
+		assert.Equal(t, uint(10), claimed[0].Task.ID)
+		assert.Equal(t, []interface{}{float64(40), 0, 1}, claimed[0].Task.Args,
+			"completed results come first, then a 0 per other member and the completed count")
+	}
+	assert.JSONEq(t, reduceArgs, reduceTask.Args, "the stored args should be left as they are")
+
+	// Once the failed member has been requeued and completed, a retry of the
+	// reduce task sees both results.
+	statuses[1] = database.TaskStatusCompleted
+	statuses[10] = database.TaskStatusPending
+	claimed, err = service.ConsumeTasks(2, nil, 1)
+
+	assert.NoError(t, err)
+	if assert.Len(t, claimed, 1) {
+		assert.Equal(t, []interface{}{float64(5), float64(40), 2}, claimed[0].Task.Args)
+	}
+}
+
+func TestTaskService_Heartbeat(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			TimeoutSeconds: 300,
+		},
+	}
+
+	auditWithStatus := func(status database.TaskStatus, claimToken string) func(taskID uint) (*database.TaskAudit, error) {
+		return func(taskID uint) (*database.TaskAudit, error) {
+			return &database.TaskAudit{
+				TaskID:     taskID,
+				Status:     status,
+				ClaimToken: claimToken,
+			}, nil
+		}
+	}
+
+	tests := []struct {
+		name       string
+		wantErr    error
+		setupMocks func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository)
+	}{
+		{
+			name:    "task not found",
+			wantErr: ErrTaskNotFound,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return nil, gorm.ErrRecordNotFound
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:    "task cancelled",
+			wantErr: ErrTaskCancelled,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: auditWithStatus(database.TaskStatusCancelled, "claim-token"),
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:    "reclaimed and back in pending",
+			wantErr: ErrTaskNotClaimed,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: auditWithStatus(database.TaskStatusPending, ""),
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:    "claimed again by another worker",
+			wantErr: ErrTaskNotClaimed,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: auditWithStatus(database.TaskStatusProcessing, "token-of-newer-claim"),
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name:    "lost the task during the update",
+			wantErr: ErrTaskNotClaimed,
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				calls := 0
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						calls++
+						if calls == 1 {
+							return auditWithStatus(database.TaskStatusProcessing, "claim-token")(taskID)
+						}
+						return auditWithStatus(database.TaskStatusPending, "")(taskID)
+					},
+					ExtendLeaseFunc: func(taskID uint, claimToken string, leaseExpiresAt time.Time) (bool, error) {
+						return false, nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+		{
+			name: "success",
+			setupMocks: func() (*MockTaskRepository, *MockTaskAuditRepository, *MockResultRepository) {
+				auditRepo := &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: auditWithStatus(database.TaskStatusProcessing, "claim-token"),
+					ExtendLeaseFunc: func(taskID uint, claimToken string, leaseExpiresAt time.Time) (bool, error) {
+						assert.Equal(t, "claim-token", claimToken)
+						return true, nil
+					},
+				}
+				return &MockTaskRepository{}, auditRepo, &MockResultRepository{}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskRepo, auditRepo, resultRepo := tt.setupMocks()
+			service := newTestTaskService(taskRepo, auditRepo, resultRepo)
+
+			leaseExpiresAt, err := service.Heartbeat(123, "claim-token")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.WithinDuration(t, time.Now().Add(300*time.Second), leaseExpiresAt, 5*time.Second)
+			}
+		})
+	}
+}
+
+func TestTaskService_ReleaseTask(t *testing.T) {
+	workerID := uint(2)
+	claimedBy := func(processedBy uint) func(taskID uint) (*database.TaskAudit, error) {
+		return func(taskID uint) (*database.TaskAudit, error) {
+			return &database.TaskAudit{
+				TaskID:      taskID,
+				Status:      database.TaskStatusProcessing,
+				ClaimToken:  "claim-token",
+				ProcessedBy: &processedBy,
+				RetryCount:  1,
+			}, nil
+		}
+	}
+
+	tests := []struct {
+		name          string
+		delay         time.Duration
+		wantErr       error
+		wantNextRunIn time.Duration
+		setupMocks    func() *MockTaskAuditRepository
+	}{
+		{
+			name:    "negative delay",
+			delay:   -time.Second,
+			wantErr: ErrInvalidReleaseDelay,
+			setupMocks: func() *MockTaskAuditRepository {
+				return &MockTaskAuditRepository{}
+			},
+		},
+		{
+			name:    "delay above max",
+			delay:   MaxReleaseDelay + time.Second,
+			wantErr: ErrInvalidReleaseDelay,
+			setupMocks: func() *MockTaskAuditRepository {
+				return &MockTaskAuditRepository{}
+			},
+		},
+		{
+			name:    "task not found",
+			wantErr: ErrTaskNotFound,
+			setupMocks: func() *MockTaskAuditRepository {
+				return &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+						return nil, gorm.ErrRecordNotFound
+					},
+				}
+			},
+		},
+		{
+			name:    "claimed by another worker",
+			wantErr: ErrTaskNotClaimed,
+			setupMocks: func() *MockTaskAuditRepository {
+				return &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: claimedBy(3),
+					ReleaseTaskFunc: func(taskID uint, claimToken string, nextAttemptAt *time.Time, reason string) error {
+						t.Error("ReleaseTask should not be called for another worker's claim")
+						return nil
+					},
+				}
+			},
+		},
+		{
+			name:    "lost the claim during the update",
+			wantErr: ErrInvalidTransition,
+			setupMocks: func() *MockTaskAuditRepository {
+				return &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: claimedBy(workerID),
+					ReleaseTaskFunc: func(taskID uint, claimToken string, nextAttemptAt *time.Time, reason string) error {
+						return &repository.TransitionError{TaskID: taskID, To: database.TaskStatusPending}
+					},
+				}
+			},
+		},
+		{
+			name: "release immediately",
+			setupMocks: func() *MockTaskAuditRepository {
+				return &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: claimedBy(workerID),
+					ReleaseTaskFunc: func(taskID uint, claimToken string, nextAttemptAt *time.Time, reason string) error {
+						assert.Equal(t, "claim-token", claimToken)
+						assert.Nil(t, nextAttemptAt)
+						assert.Equal(t, "shutting down", reason)
+						return nil
+					},
+					ReclaimStaleTaskFunc: func(taskID uint, claimToken string, errorCode string, errorMsg string, nextAttemptAt time.Time) error {
+						t.Error("a release must not count as a retry")
+						return nil
+					},
+				}
+			},
+		},
+		{
+			name:          "release with delay",
+			delay:         time.Minute,
+			wantNextRunIn: time.Minute,
+			setupMocks: func() *MockTaskAuditRepository {
+				return &MockTaskAuditRepository{
+					FindTaskAuditByTaskIDFunc: claimedBy(workerID),
+					ReleaseTaskFunc: func(taskID uint, claimToken string, nextAttemptAt *time.Time, reason string) error {
+						if assert.NotNil(t, nextAttemptAt) {
+							assert.WithinDuration(t, time.Now().Add(time.Minute), *nextAttemptAt, 5*time.Second)
+						}
+						return nil
+					},
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestTaskService(&MockTaskRepository{}, tt.setupMocks(), &MockResultRepository{})
+
+			availableAt, err := service.ReleaseTask(123, workerID, "claim-token", tt.delay, "shutting down")
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.WithinDuration(t, time.Now().Add(tt.wantNextRunIn), availableAt, 5*time.Second)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	seconds := func(n int) *int { return &n }
+
+	tests := []struct {
+		name       string
+		taskConfig config.TaskConfig
+		policy     database.RetryPolicy
+		retryCount int
+		wantMin    time.Duration
+		wantMax    time.Duration
+	}{
+		{
+			name:       "first retry uses base",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 2, RetryBackoffMaxSeconds: 300},
+			retryCount: 0,
+			wantMin:    2 * time.Second,
+			wantMax:    2 * time.Second,
+		},
+		{
+			name:       "grows exponentially",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 2, RetryBackoffMaxSeconds: 300},
+			retryCount: 3,
+			wantMin:    16 * time.Second,
+			wantMax:    16 * time.Second,
+		},
+		{
+			name:       "capped at max",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 2, RetryBackoffMaxSeconds: 60},
+			retryCount: 10,
+			wantMin:    60 * time.Second,
+			wantMax:    60 * time.Second,
+		},
+		{
+			name:       "jitter stays within bounds",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 10, RetryBackoffMaxSeconds: 300, RetryBackoffJitter: 0.5},
+			retryCount: 0,
+			wantMin:    5 * time.Second,
+			wantMax:    15 * time.Second,
+		},
+		{
+			name:       "zero base disables backoff",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 0, RetryBackoffMaxSeconds: 300},
+			retryCount: 2,
+			wantMin:    0,
+			wantMax:    0,
+		},
+		{
+			name:       "fixed policy repeats base",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 2, RetryBackoffMaxSeconds: 300},
+			policy:     database.RetryPolicy{Backoff: database.RetryBackoffFixed, BaseSeconds: seconds(5)},
+			retryCount: 4,
+			wantMin:    5 * time.Second,
+			wantMax:    5 * time.Second,
+		},
+		{
+			name:       "linear policy grows by base",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 2, RetryBackoffMaxSeconds: 300},
+			policy:     database.RetryPolicy{Backoff: database.RetryBackoffLinear},
+			retryCount: 3,
+			wantMin:    8 * time.Second,
+			wantMax:    8 * time.Second,
+		},
+		{
+			name:       "policy max overrides config",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 2, RetryBackoffMaxSeconds: 300},
+			policy:     database.RetryPolicy{MaxSeconds: seconds(10)},
+			retryCount: 5,
+			wantMin:    10 * time.Second,
+			wantMax:    10 * time.Second,
+		},
+		{
+			name:       "policy max of zero uses config max",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 2, RetryBackoffMaxSeconds: 300, RetryBackoffLimitSeconds: 3600},
+			policy:     database.RetryPolicy{MaxSeconds: seconds(0)},
+			retryCount: 1000,
+			wantMin:    300 * time.Second,
+			wantMax:    300 * time.Second,
+		},
+		{
+			name:       "capped at limit when no max is set",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 2, RetryBackoffLimitSeconds: 3600},
+			policy:     database.RetryPolicy{MaxSeconds: seconds(0)},
+			retryCount: 1000,
+			wantMin:    3600 * time.Second,
+			wantMax:    3600 * time.Second,
+		},
+		{
+			name:       "jitter never goes past limit",
+			taskConfig: config.TaskConfig{RetryBackoffBaseSeconds: 2, RetryBackoffMaxSeconds: 3600, RetryBackoffJitter: 1, RetryBackoffLimitSeconds: 3600},
+			retryCount: 64,
+			wantMin:    0,
+			wantMax:    3600 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.App = &config.Config{Task: tt.taskConfig}
+
+			backoff := retryBackoff(tt.policy, tt.retryCount)
+
+			assert.GreaterOrEqual(t, backoff, tt.wantMin)
+			assert.LessOrEqual(t, backoff, tt.wantMax)
+		})
+	}
+}
+
+func TestEffectivePublishTime(t *testing.T) {
+	config.App = &config.Config{
+		Task: config.TaskConfig{
+			PriorityAgingSeconds: 60,
+		},
+	}
+
+	now := time.Now()
+
+	assert.Equal(t, now, effectivePublishTime(now, 0))
+	assert.Equal(t, now.Add(-10*time.Minute), effectivePublishTime(now, 10))
+
+	urgent := effectivePublishTime(now, 10)
+	batchWaitingLong := effectivePublishTime(now.Add(-11*time.Minute), 0)
+	batchJustPublished := effectivePublishTime(now.Add(-5*time.Minute), 0)
+	assert.True(t, batchWaitingLong.Before(urgent), "long-waiting low-priority task should outrank new urgent task")
+	assert.True(t, urgent.Before(batchJustPublished), "urgent task should outrank recently published low-priority task")
 }
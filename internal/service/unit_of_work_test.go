@@ -0,0 +1,27 @@
+package service
+
+import "rainchanel.com/internal/repository"
+
+// MockUnitOfWork hands Repos to fn without a transaction, so nothing is
+// rolled back when fn fails.
+type MockUnitOfWork struct {
+	Repos  repository.Repositories
+	DoFunc func(fn func(repos repository.Repositories) error) error
+}
+
+func (m *MockUnitOfWork) Do(fn func(repos repository.Repositories) error) error {
+	if m.DoFunc != nil {
+		return m.DoFunc(fn)
+	}
+	return fn(m.Repos)
+}
+
+// newTestTaskService builds a task service whose unit of work runs against the
+// given mock repositories.
+func newTestTaskService(taskRepo repository.TaskRepository, auditRepo repository.TaskAuditRepository, resultRepo repository.ResultRepository) TaskService {
+	return NewTaskServiceWithRepos(taskRepo, auditRepo, resultRepo, &MockUnitOfWork{Repos: repository.Repositories{
+		Tasks:   taskRepo,
+		Audits:  auditRepo,
+		Results: resultRepo,
+	}})
+}
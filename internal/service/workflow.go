@@ -0,0 +1,282 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
+	"rainchanel.com/internal/validation"
+)
+
+var ErrWorkflowNotFound = errors.New("workflow not found")
+var ErrWorkflowAccessDenied = errors.New("workflow does not belong to user")
+var ErrInvalidWorkflow = errors.New("invalid workflow")
+
+const MaxWorkflowTasks = 1000
+const maxWorkflowKeyLength = 100
+
+type WorkflowService interface {
+	CreateWorkflow(tasks []dto.WorkflowTask, createdBy uint) (*dto.Workflow, error)
+	GetWorkflow(workflowID uint, userID uint) (*dto.Workflow, error)
+}
+
+type workflowService struct {
+	workflowRepo repository.WorkflowRepository
+	taskService  TaskService
+}
+
+func NewWorkflowService(taskService TaskService) WorkflowService {
+	return &workflowService{
+		workflowRepo: repository.NewWorkflowRepository(),
+		taskService:  taskService,
+	}
+}
+
+func NewWorkflowServiceWithRepo(workflowRepo repository.WorkflowRepository, taskService TaskService) WorkflowService {
+	return &workflowService{
+		workflowRepo: workflowRepo,
+		taskService:  taskService,
+	}
+}
+
+func (s *workflowService) CreateWorkflow(tasks []dto.WorkflowTask, createdBy uint) (*dto.Workflow, error) {
+	for i := range tasks {
+		if err := addWorkflowReferenceDependencies(&tasks[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	order, err := workflowOrder(tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tasks {
+		if err := validateTaskDefinition(&tasks[i].Task); err != nil {
+			return nil, fmt.Errorf("workflow task %q: %w", tasks[i].Key, err)
+		}
+	}
+
+	// The modules were compiled above, so the tasks are only stored from
+	// here on, together with the workflow, in one transaction.
+	dbWorkflow := &database.Workflow{
+		CreatedBy: createdBy,
+	}
+	taskIDs := make(map[string]uint, len(tasks))
+	err = s.taskService.PublishTaskBatch(createdBy, func(repos repository.Repositories, publish TaskPublisher) error {
+		if err := repos.Workflows.CreateWorkflow(dbWorkflow); err != nil {
+			return fmt.Errorf("failed to create workflow in database: %w", err)
+		}
+
+		for _, i := range order {
+			task := tasks[i]
+			dependsOn := make([]uint, 0, len(task.DependsOn))
+			for _, key := range task.DependsOn {
+				dependsOn = append(dependsOn, taskIDs[key])
+			}
+			args, err := walkResultReferences(task.Task.Args, func(reference map[string]interface{}) (interface{}, error) {
+				key, ok := reference[validation.ResultReferenceKey].(string)
+				if !ok {
+					return reference, nil
+				}
+				resolved := make(map[string]interface{}, len(reference))
+				for field, value := range reference {
+					resolved[field] = value
+				}
+				resolved[validation.ResultReferenceKey] = taskIDs[key]
+				return resolved, nil
+			})
+			if err != nil {
+				return err
+			}
+			task.Task.Args = args
+
+			published, err := publish([]dto.Task{task.Task}, []dto.PublishOptions{{
+				WorkflowID:  &dbWorkflow.ID,
+				WorkflowKey: task.Key,
+				DependsOn:   dependsOn,
+			}})
+			if err != nil {
+				return fmt.Errorf("failed to publish workflow task %q: %w", task.Key, err)
+			}
+			taskIDs[task.Key] = published[0]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"workflow_id": dbWorkflow.ID,
+		"user_id":     createdBy,
+		"tasks":       len(tasks),
+	}).Info("Workflow created")
+
+	workflow := &dto.Workflow{
+		ID:        dbWorkflow.ID,
+		Status:    JobStatusRunning,
+		Tasks:     make([]dto.WorkflowTask, 0, len(tasks)),
+		CreatedBy: createdBy,
+		CreatedAt: &dbWorkflow.CreatedAt,
+	}
+	for _, task := range tasks {
+		workflow.Tasks = append(workflow.Tasks, dto.WorkflowTask{
+			Key: task.Key,
+			Task: dto.Task{
+				ID:       taskIDs[task.Key],
+				Func:     task.Task.Func,
+				Queue:    task.Task.Queue,
+				Priority: task.Task.Priority,
+			},
+			DependsOn: task.DependsOn,
+			TaskID:    taskIDs[task.Key],
+		})
+	}
+
+	return workflow, nil
+}
+
+// addWorkflowReferenceDependencies lets a workflow task refer to another
+// task's result by key, as {"$from_task": "key"}, and makes it depend on that
+// task.
+func addWorkflowReferenceDependencies(task *dto.WorkflowTask) error {
+	_, err := walkResultReferences(task.Task.Args, func(reference map[string]interface{}) (interface{}, error) {
+		key, ok := reference[validation.ResultReferenceKey].(string)
+		if ok && !slices.Contains(task.DependsOn, key) {
+			task.DependsOn = append(task.DependsOn, key)
+		}
+		return reference, nil
+	})
+	return err
+}
+
+func (s *workflowService) GetWorkflow(workflowID uint, userID uint) (*dto.Workflow, error) {
+	dbWorkflow, err := s.workflowRepo.FindWorkflowByID(workflowID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWorkflowNotFound
+		}
+		return nil, fmt.Errorf("failed to find workflow: %w", err)
+	}
+
+	if dbWorkflow.CreatedBy != userID {
+		return nil, ErrWorkflowAccessDenied
+	}
+
+	audits, err := s.workflowRepo.FindWorkflowTasks(workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find workflow tasks: %w", err)
+	}
+	dependencies, err := s.workflowRepo.FindWorkflowDependencies(workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find workflow dependencies: %w", err)
+	}
+
+	keys := make(map[uint]string, len(audits))
+	for _, audit := range audits {
+		keys[audit.TaskID] = audit.Task.WorkflowKey
+	}
+	dependsOn := make(map[uint][]string)
+	for _, dependency := range dependencies {
+		dependsOn[dependency.TaskID] = append(dependsOn[dependency.TaskID], keys[dependency.DependsOnTaskID])
+	}
+
+	workflow := &dto.Workflow{
+		ID:        dbWorkflow.ID,
+		Counts:    make(map[string]int),
+		Tasks:     make([]dto.WorkflowTask, 0, len(audits)),
+		CreatedBy: dbWorkflow.CreatedBy,
+		CreatedAt: &dbWorkflow.CreatedAt,
+	}
+	for _, audit := range audits {
+		var args interface{}
+		if audit.Task.Args != "" {
+			if err := json.Unmarshal([]byte(audit.Task.Args), &args); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal task args: %w", err)
+			}
+		}
+
+		workflow.Counts[string(audit.Status)]++
+		workflow.Tasks = append(workflow.Tasks, dto.WorkflowTask{
+			Key: audit.Task.WorkflowKey,
+			Task: dto.Task{
+				ID:       audit.TaskID,
+				Func:     audit.Task.Func,
+				Args:     args,
+				Queue:    audit.Task.Queue,
+				Priority: audit.Task.Priority,
+			},
+			DependsOn: dependsOn[audit.TaskID],
+			TaskID:    audit.TaskID,
+			Status:    string(audit.Status),
+			ErrorMsg:  audit.ErrorMsg,
+		})
+	}
+	workflow.Status = jobStatus(workflow.Counts)
+
+	return workflow, nil
+}
+
+// workflowOrder checks the task graph and returns the task indexes in an order
+// where every task comes after the tasks it depends on.
+func workflowOrder(tasks []dto.WorkflowTask) ([]int, error) {
+	if len(tasks) == 0 || len(tasks) > MaxWorkflowTasks {
+		return nil, fmt.Errorf("%w: must contain between 1 and %d tasks", ErrInvalidWorkflow, MaxWorkflowTasks)
+	}
+
+	index := make(map[string]int, len(tasks))
+	for i, task := range tasks {
+		if task.Key == "" || len(task.Key) > maxWorkflowKeyLength {
+			return nil, fmt.Errorf("%w: task keys must be between 1 and %d characters", ErrInvalidWorkflow, maxWorkflowKeyLength)
+		}
+		if _, exists := index[task.Key]; exists {
+			return nil, fmt.Errorf("%w: duplicate task key %q", ErrInvalidWorkflow, task.Key)
+		}
+		index[task.Key] = i
+	}
+
+	waitingOn := make([]int, len(tasks))
+	dependents := make([][]int, len(tasks))
+	for i, task := range tasks {
+		seen := make(map[string]bool, len(task.DependsOn))
+		for _, key := range task.DependsOn {
+			parent, exists := index[key]
+			if !exists {
+				return nil, fmt.Errorf("%w: task %q depends on unknown task %q", ErrInvalidWorkflow, task.Key, key)
+			}
+			if seen[key] {
+				return nil, fmt.Errorf("%w: task %q lists %q more than once", ErrInvalidWorkflow, task.Key, key)
+			}
+			seen[key] = true
+			waitingOn[i]++
+			dependents[parent] = append(dependents[parent], i)
+		}
+	}
+
+	order := make([]int, 0, len(tasks))
+	for i := range tasks {
+		if waitingOn[i] == 0 {
+			order = append(order, i)
+		}
+	}
+	for next := 0; next < len(order); next++ {
+		for _, child := range dependents[order[next]] {
+			waitingOn[child]--
+			if waitingOn[child] == 0 {
+				order = append(order, child)
+			}
+		}
+	}
+	if len(order) != len(tasks) {
+		return nil, fmt.Errorf("%w: dependencies contain a cycle", ErrInvalidWorkflow)
+	}
+
+	return order, nil
+}
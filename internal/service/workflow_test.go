@@ -0,0 +1,339 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"rainchanel.com/internal/config"
+	"rainchanel.com/internal/database"
+	"rainchanel.com/internal/dto"
+	"rainchanel.com/internal/repository"
+)
+
+func TestWorkflowService_CreateWorkflow_InvalidGraph(t *testing.T) {
+	task := dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}}
+
+	tests := []struct {
+		name  string
+		tasks []dto.WorkflowTask
+	}{
+		{
+			name:  "no tasks",
+			tasks: []dto.WorkflowTask{},
+		},
+		{
+			name: "duplicate key",
+			tasks: []dto.WorkflowTask{
+				{Key: "a", Task: task},
+				{Key: "a", Task: task},
+			},
+		},
+		{
+			name: "unknown dependency",
+			tasks: []dto.WorkflowTask{
+				{Key: "a", Task: task, DependsOn: []string{"missing"}},
+			},
+		},
+		{
+			name: "self dependency",
+			tasks: []dto.WorkflowTask{
+				{Key: "a", Task: task, DependsOn: []string{"a"}},
+			},
+		},
+		{
+			name: "cycle",
+			tasks: []dto.WorkflowTask{
+				{Key: "a", Task: task, DependsOn: []string{"c"}},
+				{Key: "b", Task: task, DependsOn: []string{"a"}},
+				{Key: "c", Task: task, DependsOn: []string{"b"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflowRepo := &MockWorkflowRepository{
+				CreateWorkflowFunc: func(workflow *database.Workflow) error {
+					t.Fatal("workflow should not be created")
+					return nil
+				},
+			}
+			service := NewWorkflowServiceWithRepo(workflowRepo, &MockTaskServiceForStale{})
+
+			workflow, err := service.CreateWorkflow(tt.tasks, 1)
+
+			assert.ErrorIs(t, err, ErrInvalidWorkflow)
+			assert.Nil(t, workflow)
+		})
+	}
+}
+
+func TestWorkflowService_CreateWorkflow(t *testing.T) {
+	task := dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}}
+	tasks := []dto.WorkflowTask{
+		{Key: "report", Task: task, DependsOn: []string{"left", "right"}},
+		{Key: "right", Task: task, DependsOn: []string{"fetch"}},
+		{Key: "left", Task: task, DependsOn: []string{"fetch"}},
+		{Key: "fetch", Task: task},
+	}
+
+	t.Run("publishes parents before children", func(t *testing.T) {
+		workflowRepo := &MockWorkflowRepository{
+			CreateWorkflowFunc: func(workflow *database.Workflow) error {
+				workflow.ID = 7
+				return nil
+			},
+		}
+		published := map[string]dto.PublishOptions{}
+		var order []string
+		taskService := &MockTaskServiceForStale{
+			PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+				assert.Equal(t, uint(7), *opts.WorkflowID)
+				published[opts.WorkflowKey] = opts
+				order = append(order, opts.WorkflowKey)
+				return uint(100 + len(order)), nil
+			},
+			BatchRepos: repository.Repositories{Workflows: workflowRepo},
+		}
+		service := NewWorkflowServiceWithRepo(&MockWorkflowRepository{}, taskService)
+
+		workflow, err := service.CreateWorkflow(tasks, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"fetch", "right", "left", "report"}, order)
+		assert.Empty(t, published["fetch"].DependsOn)
+		assert.Equal(t, []uint{101}, published["left"].DependsOn)
+		assert.Equal(t, []uint{103, 102}, published["report"].DependsOn)
+		assert.Equal(t, uint(7), workflow.ID)
+		assert.Equal(t, JobStatusRunning, workflow.Status)
+		assert.Len(t, workflow.Tasks, 4)
+		assert.Equal(t, "report", workflow.Tasks[0].Key)
+		assert.Equal(t, uint(104), workflow.Tasks[0].TaskID)
+	})
+
+	t.Run("fails the whole workflow when a later publish fails", func(t *testing.T) {
+		workflowRepo := &MockWorkflowRepository{
+			CreateWorkflowFunc: func(workflow *database.Workflow) error {
+				workflow.ID = 7
+				return nil
+			},
+		}
+		publishCount := 0
+		taskService := &MockTaskServiceForStale{
+			PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+				publishCount++
+				if publishCount == 3 {
+					return 0, errors.New("database error")
+				}
+				return uint(100 + publishCount), nil
+			},
+			CancelTaskFunc: func(taskID uint, userID uint) error {
+				t.Error("tasks should be rolled back with the workflow, not cancelled")
+				return nil
+			},
+			BatchRepos: repository.Repositories{Workflows: workflowRepo},
+		}
+		service := NewWorkflowServiceWithRepo(&MockWorkflowRepository{}, taskService)
+
+		workflow, err := service.CreateWorkflow(tasks, 1)
+
+		assert.ErrorContains(t, err, "failed to publish workflow task")
+		assert.Nil(t, workflow)
+	})
+}
+
+func TestWorkflowService_CreateWorkflow_UnitOfWork(t *testing.T) {
+	config.App = &config.Config{}
+
+	tasks := []dto.WorkflowTask{
+		{
+			Key: "double",
+			Task: dto.Task{
+				WasmModule: testWasmModule,
+				Func:       "add",
+				Args:       []interface{}{map[string]interface{}{"$from_task": "fetch"}, float64(2)},
+			},
+		},
+		{Key: "fetch", Task: dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}}},
+	}
+
+	var inTx bool
+	var created []*database.Task
+	var dependencies []database.TaskDependency
+	workflowRepo := &MockWorkflowRepository{
+		CreateWorkflowFunc: func(workflow *database.Workflow) error {
+			assert.True(t, inTx, "the workflow should be created in the unit of work")
+			workflow.ID = 7
+			return nil
+		},
+	}
+	taskRepo := &MockTaskRepository{
+		CreateTasksFunc: func(tasks []*database.Task) error {
+			assert.True(t, inTx, "tasks should be created in the unit of work")
+			for _, task := range tasks {
+				created = append(created, task)
+				task.ID = uint(10 + len(created))
+			}
+			return nil
+		},
+		FindTaskByIDFunc: func(taskID uint) (*database.Task, error) {
+			assert.True(t, inTx, "a reference to a task of the same workflow is only visible in the unit of work")
+			for _, task := range created {
+				if task.ID == taskID {
+					return task, nil
+				}
+			}
+			return nil, gorm.ErrRecordNotFound
+		},
+		CreateTaskDependenciesFunc: func(deps []database.TaskDependency) error {
+			dependencies = append(dependencies, deps...)
+			return nil
+		},
+	}
+	auditRepo := &MockTaskAuditRepository{
+		FindTaskAuditByTaskIDFunc: func(taskID uint) (*database.TaskAudit, error) {
+			return &database.TaskAudit{TaskID: taskID, Status: database.TaskStatusPending}, nil
+		},
+	}
+	uowCalls := 0
+	uow := &MockUnitOfWork{DoFunc: func(fn func(repos repository.Repositories) error) error {
+		uowCalls++
+		inTx = true
+		defer func() { inTx = false }()
+		return fn(repository.Repositories{Tasks: taskRepo, Audits: auditRepo, Workflows: workflowRepo})
+	}}
+	taskService := NewTaskServiceWithRepos(taskRepo, auditRepo, &MockResultRepository{}, uow)
+	service := NewWorkflowServiceWithRepo(&MockWorkflowRepository{}, taskService)
+
+	workflow, err := service.CreateWorkflow(tasks, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, uowCalls)
+	assert.Equal(t, uint(7), workflow.ID)
+	assert.Equal(t, []database.TaskDependency{{TaskID: 12, DependsOnTaskID: 11}}, dependencies)
+}
+
+func TestWorkflowService_CreateWorkflow_ResultReferenceByKey(t *testing.T) {
+	tasks := []dto.WorkflowTask{
+		{
+			Key: "double",
+			Task: dto.Task{
+				WasmModule: testWasmModule,
+				Func:       "add",
+				Args:       []interface{}{map[string]interface{}{"$from_task": "fetch"}, float64(2)},
+			},
+		},
+		{Key: "fetch", Task: dto.Task{WasmModule: testWasmModule, Func: "add", Args: []int{1, 2}}},
+	}
+
+	published := map[string]dto.PublishOptions{}
+	var doubleArgs interface{}
+	taskService := &MockTaskServiceForStale{
+		PublishTaskFunc: func(task dto.Task, createdBy uint, opts dto.PublishOptions) (uint, error) {
+			published[opts.WorkflowKey] = opts
+			if opts.WorkflowKey == "double" {
+				doubleArgs = task.Args
+				return 12, nil
+			}
+			return 11, nil
+		},
+		BatchRepos: repository.Repositories{Workflows: &MockWorkflowRepository{}},
+	}
+	service := NewWorkflowServiceWithRepo(&MockWorkflowRepository{}, taskService)
+
+	workflow, err := service.CreateWorkflow(tasks, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fetch"}, workflow.Tasks[0].DependsOn)
+	assert.Equal(t, []uint{11}, published["double"].DependsOn)
+	assert.Equal(t, []interface{}{map[string]interface{}{"$from_task": uint(11)}, float64(2)}, doubleArgs)
+}
+
+func TestWorkflowService_GetWorkflow(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     uint
+		findErr    error
+		statuses   []database.TaskStatus
+		wantErr    error
+		wantStatus string
+	}{
+		{
+			name:    "not found",
+			userID:  1,
+			findErr: gorm.ErrRecordNotFound,
+			wantErr: ErrWorkflowNotFound,
+		},
+		{
+			name:    "access denied",
+			userID:  2,
+			wantErr: ErrWorkflowAccessDenied,
+		},
+		{
+			name:       "running while a task is blocked",
+			userID:     1,
+			statuses:   []database.TaskStatus{database.TaskStatusCompleted, database.TaskStatusBlocked},
+			wantStatus: JobStatusRunning,
+		},
+		{
+			name:       "completed",
+			userID:     1,
+			statuses:   []database.TaskStatus{database.TaskStatusCompleted, database.TaskStatusCompleted},
+			wantStatus: JobStatusCompleted,
+		},
+		{
+			name:       "failed",
+			userID:     1,
+			statuses:   []database.TaskStatus{database.TaskStatusFailed, database.TaskStatusFailed},
+			wantStatus: JobStatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflowRepo := &MockWorkflowRepository{
+				FindWorkflowByIDFunc: func(workflowID uint) (*database.Workflow, error) {
+					if tt.findErr != nil {
+						return nil, tt.findErr
+					}
+					return &database.Workflow{ID: workflowID, CreatedBy: 1}, nil
+				},
+				FindWorkflowTasksFunc: func(workflowID uint) ([]*database.TaskAudit, error) {
+					audits := make([]*database.TaskAudit, 0, len(tt.statuses))
+					for i, status := range tt.statuses {
+						taskID := uint(10 + i)
+						audits = append(audits, &database.TaskAudit{
+							TaskID: taskID,
+							Status: status,
+							Task:   database.Task{ID: taskID, Func: "add", Args: `[1,2]`, WorkflowKey: string(rune('a' + i))},
+						})
+					}
+					return audits, nil
+				},
+				FindWorkflowDependenciesFunc: func(workflowID uint) ([]database.TaskDependency, error) {
+					return []database.TaskDependency{{TaskID: 11, DependsOnTaskID: 10}}, nil
+				},
+			}
+			service := NewWorkflowServiceWithRepo(workflowRepo, &MockTaskServiceForStale{})
+
+			workflow, err := service.GetWorkflow(3, tt.userID)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, workflow)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, workflow.Status)
+			assert.Len(t, workflow.Tasks, 2)
+			assert.Equal(t, []string{"a"}, workflow.Tasks[1].DependsOn)
+			total := 0
+			for _, count := range workflow.Counts {
+				total += count
+			}
+			assert.Equal(t, len(tt.statuses), total)
+		})
+	}
+}
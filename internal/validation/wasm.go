@@ -20,6 +20,19 @@ var (
 	ErrInvalidBase64Encoding = errors.New("invalid base64 encoding for WASM module")
 )
 
+// ResultReferenceKey marks an argument that the server fills in from another
+// task's result when the task is claimed.
+const ResultReferenceKey = "$from_task"
+
+func IsResultReference(arg interface{}) bool {
+	reference, ok := arg.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = reference[ResultReferenceKey]
+	return ok
+}
+
 func ValidateTask(wasmModuleBase64, functionName string, args interface{}) error {
 	wasmBytes, err := base64.StdEncoding.DecodeString(wasmModuleBase64)
 	if err != nil {
@@ -39,6 +52,28 @@ func ValidateTask(wasmModuleBase64, functionName string, args interface{}) error
 	return nil
 }
 
+// ValidateTasks checks many argument sets against one function, compiling the
+// module only once.
+func ValidateTasks(wasmModuleBase64, functionName string, argSets []interface{}) error {
+	wasmBytes, err := base64.StdEncoding.DecodeString(wasmModuleBase64)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBase64Encoding, err)
+	}
+
+	exportedFunc, _, err := validateWASMModuleAndGetFunction(wasmBytes, functionName)
+	if err != nil {
+		return err
+	}
+
+	for i, args := range argSets {
+		if err := validateFunctionSignature(exportedFunc, args); err != nil {
+			return fmt.Errorf("argument set %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
 func validateWASMModuleAndGetFunction(wasmBytes []byte, functionName string) (api.FunctionDefinition, []string, error) {
 	ctx := context.Background()
 	runtime := wazero.NewRuntime(ctx)
@@ -282,6 +317,9 @@ func validateFunctionSignature(function api.FunctionDefinition, args interface{}
 		}
 
 		arg := argsSlice[i]
+		if IsResultReference(arg) {
+			continue
+		}
 		if err := validateArgType(arg, paramType); err != nil {
 			return fmt.Errorf("%w: parameter %d: %v", ErrInvalidFunctionArgs, i, err)
 		}